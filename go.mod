@@ -1,5 +1,5 @@
 module github.com/minond/bool
 
-go 1.12
+go 1.18
 
 require github.com/davecgh/go-spew v1.1.1
@@ -0,0 +1,102 @@
+package lang
+
+// Every expression in the AST is one of the node types below. Passes over the
+// AST, like evaluation and printing, implement the Visitor interface instead
+// of checking which fields of a node are set.
+type Expr interface {
+	Accept(v Visitor)
+	Span() span
+}
+
+type Visitor interface {
+	VisitBadExpr(e *BadExpr)
+	VisitBinaryExpr(e *BinaryExpr)
+	VisitUnaryExpr(e *UnaryExpr)
+	VisitGroupExpr(e *GroupExpr)
+	VisitCallExpr(e *CallExpr)
+	VisitIdentExpr(e *IdentExpr)
+	VisitLiteralExpr(e *LiteralExpr)
+	VisitSeqExpr(e *SeqExpr)
+	VisitNumberExpr(e *NumberExpr)
+}
+
+// Placeholder for an expression that could not be parsed. The parser reports
+// the error, this just keeps the tree in one piece.
+type BadExpr struct {
+	err error
+}
+
+// lhs op rhs
+type BinaryExpr struct {
+	lhs Expr
+	op  token
+	rhs Expr
+}
+
+// op rhs
+type UnaryExpr struct {
+	op  token
+	rhs Expr
+}
+
+// ( inner )
+type GroupExpr struct {
+	inner Expr
+	span  span
+}
+
+// callee(args...), which is either a gate call or an access into a sequence.
+type CallExpr struct {
+	callee token
+	args   []Expr
+	span   span
+}
+
+type IdentExpr struct {
+	name token
+}
+
+// A boolean literal. The token is kept around so that the expression can be
+// printed the way it was written, but it is empty for literals created while
+// evaluating.
+type LiteralExpr struct {
+	tok   token
+	value bool
+}
+
+// [items...]
+type SeqExpr struct {
+	items []Expr
+	span  span
+}
+
+type NumberExpr struct {
+	tok token
+}
+
+func (e *BadExpr) Accept(v Visitor)     { v.VisitBadExpr(e) }
+func (e *BinaryExpr) Accept(v Visitor)  { v.VisitBinaryExpr(e) }
+func (e *UnaryExpr) Accept(v Visitor)   { v.VisitUnaryExpr(e) }
+func (e *GroupExpr) Accept(v Visitor)   { v.VisitGroupExpr(e) }
+func (e *CallExpr) Accept(v Visitor)    { v.VisitCallExpr(e) }
+func (e *IdentExpr) Accept(v Visitor)   { v.VisitIdentExpr(e) }
+func (e *LiteralExpr) Accept(v Visitor) { v.VisitLiteralExpr(e) }
+func (e *SeqExpr) Accept(v Visitor)     { v.VisitSeqExpr(e) }
+func (e *NumberExpr) Accept(v Visitor)  { v.VisitNumberExpr(e) }
+
+func (e *BadExpr) Span() span {
+	if serr, ok := e.err.(sourceError); ok {
+		return serr.span
+	}
+
+	return span{}
+}
+
+func (e *BinaryExpr) Span() span  { return joinSpans(e.lhs.Span(), e.rhs.Span()) }
+func (e *UnaryExpr) Span() span   { return joinSpans(e.op.span, e.rhs.Span()) }
+func (e *GroupExpr) Span() span   { return e.span }
+func (e *CallExpr) Span() span    { return e.span }
+func (e *IdentExpr) Span() span   { return e.name.span }
+func (e *LiteralExpr) Span() span { return e.tok.span }
+func (e *SeqExpr) Span() span     { return e.span }
+func (e *NumberExpr) Span() span  { return e.tok.span }
@@ -0,0 +1,471 @@
+package lang
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+)
+
+const (
+	// Maximum number of nodes a BDD can have, including the ones that are
+	// no longer used.
+	maxBddNodes = 1 << 22
+
+	// Sifting stops moving a variable in one direction once the diagram
+	// grows past this many times its smallest size.
+	maxSiftGrowth = 2
+)
+
+// Facts about the binary decision diagram of an expression or a gate.
+// Counts has the number of assignments of the inputs that make every output
+// bit true.
+type Diagram struct {
+	Nodes    int
+	Unsifted int
+	Order    []string
+	Counts   []*big.Int
+}
+
+// A node tests variable v and continues to hi when it is true and to lo
+// when it is false. Node 0 is false and node 1 is true.
+type bddNode struct {
+	v, lo, hi int
+}
+
+// A reduced ordered binary decision diagram. Variables are tested in the
+// same order along every path, no node has the same children, and no two
+// nodes are the same, so every function has exactly one node. The order can
+// change, and nodes keep representing the same function when it does.
+type bdd struct {
+	nodes  []bddNode
+	unique map[bddNode]int
+	memo   map[[3]int]int
+	order  []int
+	level  []int
+	full   bool
+}
+
+func newBdd(vars int) *bdd {
+	b := &bdd{
+		nodes:  []bddNode{{v: -1}, {v: -1}},
+		unique: make(map[bddNode]int),
+		memo:   make(map[[3]int]int),
+	}
+
+	for v := 0; v < vars; v++ {
+		b.order = append(b.order, v)
+		b.level = append(b.level, v)
+	}
+
+	return b
+}
+
+// Returns the level of the variable a node tests. Terminals are below every
+// variable.
+func (b *bdd) levelOf(f int) int {
+	if f < 2 {
+		return len(b.order)
+	}
+
+	return b.level[b.nodes[f].v]
+}
+
+// Returns the node that tests v, reusing an existing one when possible.
+func (b *bdd) mk(v, lo, hi int) int {
+	if lo == hi {
+		return lo
+	}
+
+	n := bddNode{v: v, lo: lo, hi: hi}
+
+	if id, ok := b.unique[n]; ok {
+		return id
+	} else if len(b.nodes) >= maxBddNodes {
+		b.full = true
+		return 0
+	}
+
+	b.nodes = append(b.nodes, n)
+	b.unique[n] = len(b.nodes) - 1
+	return len(b.nodes) - 1
+}
+
+func (b *bdd) variable(v int) int {
+	return b.mk(v, 0, 1)
+}
+
+// Returns f with v set to true and to false.
+func (b *bdd) cofactors(f, v int) (int, int) {
+	if f < 2 || b.nodes[f].v != v {
+		return f, f
+	}
+
+	return b.nodes[f].hi, b.nodes[f].lo
+}
+
+// If f then g else h, which every other operation is built on.
+func (b *bdd) ite(f, g, h int) int {
+	switch {
+	case f == 1:
+		return g
+	case f == 0:
+		return h
+	case g == h:
+		return g
+	case g == 1 && h == 0:
+		return f
+	}
+
+	key := [3]int{f, g, h}
+
+	if r, ok := b.memo[key]; ok {
+		return r
+	}
+
+	top := b.levelOf(f)
+
+	if l := b.levelOf(g); l < top {
+		top = l
+	}
+
+	if l := b.levelOf(h); l < top {
+		top = l
+	}
+
+	v := b.order[top]
+	f1, f0 := b.cofactors(f, v)
+	g1, g0 := b.cofactors(g, v)
+	h1, h0 := b.cofactors(h, v)
+	r := b.mk(v, b.ite(f0, g0, h0), b.ite(f1, g1, h1))
+
+	b.memo[key] = r
+	return r
+}
+
+func (b *bdd) not(f int) int    { return b.ite(f, 0, 1) }
+func (b *bdd) and(f, g int) int { return b.ite(f, g, 0) }
+func (b *bdd) or(f, g int) int  { return b.ite(f, 1, g) }
+func (b *bdd) xor(f, g int) int { return b.ite(f, b.not(g), g) }
+
+// Builds the BDD of every output of a circuit. Variables are ordered by when
+// their inputs are first reached going through the circuit from the outputs,
+// which keeps inputs that are combined with each other close together.
+func circuitBdd(c *circuit, outputs []int) (*bdd, []int, error) {
+	b := newBdd(len(c.names))
+	seen := make(map[int]bool)
+	var order []int
+	var visit func(id int)
+
+	visit = func(id int) {
+		if seen[id] {
+			return
+		}
+
+		seen[id] = true
+
+		switch n := c.nodes[id]; n.op {
+		case inputNode:
+			order = append(order, n.a)
+		case notNode:
+			visit(n.a)
+		case andNode, orNode, xorNode:
+			visit(n.a)
+			visit(n.b)
+		}
+	}
+
+	for _, out := range outputs {
+		visit(out)
+	}
+
+	// Inputs that no output depends on go last.
+	for _, name := range c.names {
+		visit(c.inputs[name])
+	}
+
+	for lvl, v := range order {
+		b.order[lvl] = v
+		b.level[v] = lvl
+	}
+
+	fns := make([]int, len(c.nodes))
+
+	for id, n := range c.nodes {
+		if !seen[id] {
+			continue
+		}
+
+		switch n.op {
+		case constNode:
+			fns[id] = n.a
+		case inputNode:
+			fns[id] = b.variable(n.a)
+		case notNode:
+			fns[id] = b.not(fns[n.a])
+		case andNode:
+			fns[id] = b.and(fns[n.a], fns[n.b])
+		case orNode:
+			fns[id] = b.or(fns[n.a], fns[n.b])
+		case xorNode:
+			fns[id] = b.xor(fns[n.a], fns[n.b])
+		}
+
+		if b.full {
+			return nil, nil, fmt.Errorf("The BDD has more than %d nodes.", maxBddNodes)
+		}
+	}
+
+	var roots []int
+
+	for _, out := range outputs {
+		roots = append(roots, fns[out])
+	}
+
+	return b, roots, nil
+}
+
+// Counts the nodes reachable from the roots, including the terminals.
+func (b *bdd) size(roots []int) int {
+	seen := map[int]bool{0: true, 1: true}
+	stack := append([]int{}, roots...)
+
+	for len(stack) > 0 {
+		f := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		if seen[f] {
+			continue
+		}
+
+		seen[f] = true
+		stack = append(stack, b.nodes[f].lo, b.nodes[f].hi)
+	}
+
+	return len(seen)
+}
+
+// Swaps the variables at levels i and i+1. Nodes that test the upper
+// variable and have a child that tests the lower one are rewritten in place
+// to test the lower variable first, so every node still means the same thing.
+func (b *bdd) swap(i int) {
+	x, y := b.order[i], b.order[i+1]
+	var moving []int
+
+	for id := 2; id < len(b.nodes); id++ {
+		n := b.nodes[id]
+
+		if n.v == x && ((n.lo >= 2 && b.nodes[n.lo].v == y) || (n.hi >= 2 && b.nodes[n.hi].v == y)) {
+			moving = append(moving, id)
+		}
+	}
+
+	b.order[i], b.order[i+1] = y, x
+	b.level[x], b.level[y] = i+1, i
+
+	for _, id := range moving {
+		n := b.nodes[id]
+		f11, f10 := b.cofactors(n.hi, y)
+		f01, f00 := b.cofactors(n.lo, y)
+
+		delete(b.unique, n)
+		n = bddNode{v: y, lo: b.mk(x, f00, f10), hi: b.mk(x, f01, f11)}
+		b.nodes[id] = n
+		b.unique[n] = id
+	}
+}
+
+// Reorders the variables to make the diagram smaller by moving every
+// variable, starting with the ones tested by the most nodes, to the level
+// where the diagram is smallest with every other variable left in place.
+func (b *bdd) sift(roots []int) []int {
+	counts := make([]int, len(b.order))
+	seen := make(map[int]bool)
+	var count func(f int)
+
+	count = func(f int) {
+		if f < 2 || seen[f] {
+			return
+		}
+
+		seen[f] = true
+		counts[b.nodes[f].v]++
+		count(b.nodes[f].lo)
+		count(b.nodes[f].hi)
+	}
+
+	for _, root := range roots {
+		count(root)
+	}
+
+	vars := append([]int{}, b.order...)
+
+	sort.SliceStable(vars, func(i, j int) bool {
+		return counts[vars[i]] > counts[vars[j]]
+	})
+
+	last := len(b.order) - 1
+
+	for _, v := range vars {
+		best, bestLevel := b.size(roots), b.level[v]
+
+		for b.level[v] < last && !b.full {
+			b.swap(b.level[v])
+
+			if size := b.size(roots); size < best {
+				best, bestLevel = size, b.level[v]
+			} else if size > best*maxSiftGrowth {
+				break
+			}
+		}
+
+		for b.level[v] > 0 && !b.full {
+			b.swap(b.level[v] - 1)
+
+			if size := b.size(roots); size < best {
+				best, bestLevel = size, b.level[v]
+			} else if size > best*maxSiftGrowth && b.level[v] < bestLevel {
+				break
+			}
+		}
+
+		for b.level[v] < bestLevel {
+			b.swap(b.level[v])
+		}
+
+		for b.level[v] > bestLevel {
+			b.swap(b.level[v] - 1)
+		}
+
+		roots = b.collect(roots)
+	}
+
+	return roots
+}
+
+// Drops the nodes that are not reachable from the roots, which renumbers
+// the nodes, and returns the new roots.
+func (b *bdd) collect(roots []int) []int {
+	fresh := newBdd(0)
+	fresh.order, fresh.level = b.order, b.level
+	ids := map[int]int{0: 0, 1: 1}
+	var copyNode func(f int) int
+
+	copyNode = func(f int) int {
+		if id, ok := ids[f]; ok {
+			return id
+		}
+
+		n := b.nodes[f]
+		id := fresh.mk(n.v, copyNode(n.lo), copyNode(n.hi))
+		ids[f] = id
+		return id
+	}
+
+	var res []int
+
+	for _, root := range roots {
+		res = append(res, copyNode(root))
+	}
+
+	*b = *fresh
+	return res
+}
+
+// Counts the assignments of every variable that make f true.
+func (b *bdd) satCount(f int) *big.Int {
+	memo := make(map[int]*big.Int)
+	var count func(f int) *big.Int
+
+	// Counts assignments of the variables from f's level down.
+	count = func(f int) *big.Int {
+		if f < 2 {
+			return big.NewInt(int64(f))
+		} else if c, ok := memo[f]; ok {
+			return c
+		}
+
+		n := b.nodes[f]
+		lvl := b.levelOf(f)
+		lo := new(big.Int).Lsh(count(n.lo), uint(b.levelOf(n.lo)-lvl-1))
+		hi := new(big.Int).Lsh(count(n.hi), uint(b.levelOf(n.hi)-lvl-1))
+		c := lo.Add(lo, hi)
+
+		memo[f] = c
+		return c
+	}
+
+	return new(big.Int).Lsh(count(f), uint(b.levelOf(f)))
+}
+
+// Returns the probability that f is true when every variable v is true with
+// probability p[v], independently of every other variable.
+func (b *bdd) prob(f int, p []*big.Rat) *big.Rat {
+	memo := make(map[int]*big.Rat)
+	var prob func(f int) *big.Rat
+
+	prob = func(f int) *big.Rat {
+		if f < 2 {
+			return big.NewRat(int64(f), 1)
+		} else if r, ok := memo[f]; ok {
+			return r
+		}
+
+		n := b.nodes[f]
+		hi := new(big.Rat).Mul(p[n.v], prob(n.hi))
+		lo := new(big.Rat).Sub(big.NewRat(1, 1), p[n.v])
+		r := hi.Add(hi, lo.Mul(lo, prob(n.lo)))
+
+		memo[f] = r
+		return r
+	}
+
+	return prob(f)
+}
+
+// Builds the binary decision diagram of src, which is the name of a gate or
+// an expression, and reorders its variables to make it as small as possible.
+func (rt *Runtime) BDD(src string) (Diagram, error) {
+	b, roots, names, err := rt.bdd(src)
+
+	if err != nil {
+		return Diagram{}, err
+	}
+
+	d := Diagram{Unsifted: b.size(roots)}
+	roots = b.sift(roots)
+
+	if b.full {
+		return Diagram{}, &Error{Phase: EvalPhase, Errors: []error{fmt.Errorf(
+			"The BDD has more than %d nodes.", maxBddNodes)}}
+	}
+
+	d.Nodes = b.size(roots)
+
+	for _, v := range b.order {
+		d.Order = append(d.Order, names[v])
+	}
+
+	for _, root := range roots {
+		d.Counts = append(d.Counts, b.satCount(root))
+	}
+
+	return d, nil
+}
+
+// Builds the diagram of every output bit of an expression or a gate, and
+// returns it along with the names of its variables.
+func (rt *Runtime) bdd(src string) (*bdd, []int, []string, error) {
+	c, w, errs := circuitFor(src, &rt.env)
+
+	if len(errs) > 0 {
+		return nil, nil, nil, &Error{Phase: EvalPhase, Errors: errs}
+	}
+
+	b, roots, err := circuitBdd(c, w.bits())
+
+	if err != nil {
+		return nil, nil, nil, &Error{Phase: EvalPhase, Errors: []error{err}}
+	}
+
+	return b, roots, c.names, nil
+}
@@ -0,0 +1,154 @@
+package lang
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+)
+
+// Follows the path through the diagram that an assignment takes.
+func evalBdd(b *bdd, f int, vals []bool) bool {
+	for f >= 2 {
+		if n := b.nodes[f]; vals[n.v] {
+			f = n.hi
+		} else {
+			f = n.lo
+		}
+	}
+
+	return f == 1
+}
+
+func TestBDD(t *testing.T) {
+	rt := newTestRuntime(t,
+		"import \"std/adders.bool\"",
+		"import \"std/comparators.bool\"",
+	)
+
+	tests := []struct {
+		src    string
+		nodes  int
+		counts string
+	}{
+		{"a ∧ b", 4, "1"},
+		{"a ∨ ¬a", 2, "2"},
+		{"a ∧ ¬a", 2, "0"},
+		{"a ⊕ b ⊕ c", 7, "4"},
+		{"comparators.Eq8", 26, "256"},
+		{"adders.FullAdder", 10, "4 4"},
+	}
+
+	for _, test := range tests {
+		d, err := rt.BDD(test.src)
+
+		if err != nil {
+			t.Errorf("BDD(%q) error = %v", test.src, err)
+			continue
+		}
+
+		var counts []string
+
+		for _, c := range d.Counts {
+			counts = append(counts, c.String())
+		}
+
+		if d.Nodes != test.nodes {
+			t.Errorf("BDD(%q) has %d nodes, expected %d", test.src, d.Nodes, test.nodes)
+		} else if strings.Join(counts, " ") != test.counts {
+			t.Errorf("BDD(%q) counts = %v, expected %s", test.src, counts, test.counts)
+		}
+	}
+
+	if _, err := rt.BDD("reg(a)"); err == nil {
+		t.Error("expected BDD to fail on a register")
+	}
+}
+
+func TestBDDMatchesCircuit(t *testing.T) {
+	rt := NewRuntime()
+
+	for _, src := range []string{
+		"(a → b) ≡ (c ⊕ ¬d)",
+		"(a ∧ b) ∨ (c ∧ d) ∨ (e ∧ f)",
+		"a ≥ b ∧ c < d ∨ a ≤ c",
+	} {
+		c, w, errs := circuitFor(src, &rt.env)
+
+		if len(errs) > 0 {
+			t.Fatal(errs)
+		}
+
+		b, roots, err := circuitBdd(c, []int{w.node})
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		roots = b.sift(roots)
+		n := len(c.names)
+		count := 0
+
+		for row := 0; row < 1<<n; row++ {
+			vals := make([]bool, n)
+
+			for i := range vals {
+				vals[i] = row&(1<<i) != 0
+			}
+
+			exp := c.eval(vals)[w.node]
+
+			if exp {
+				count++
+			}
+
+			if got := evalBdd(b, roots[0], vals); got != exp {
+				t.Errorf("BDD for %q returned %v with %v, expected %v", src, got, vals, exp)
+			}
+		}
+
+		if got := b.satCount(roots[0]); got.Cmp(big.NewInt(int64(count))) != 0 {
+			t.Errorf("BDD for %q counted %s assignments, expected %d", src, got, count)
+		}
+	}
+}
+
+// Comparing two 8 bit numbers one bit at a time takes a node per bit when
+// the bits of both numbers are interleaved, but an exponential number of
+// nodes when all of the bits of one number come first.
+func TestBDDSift(t *testing.T) {
+	b := newBdd(16)
+	f := 1
+
+	for i := 0; i < 8; i++ {
+		f = b.and(f, b.not(b.xor(b.variable(i), b.variable(i+8))))
+	}
+
+	roots := []int{f}
+
+	if size := b.size(roots); size < 500 {
+		t.Fatalf("expected a large diagram before sifting, got %d nodes", size)
+	}
+
+	roots = b.sift(roots)
+
+	if size := b.size(roots); size != 26 {
+		t.Errorf("got %d nodes after sifting, expected 26", size)
+	}
+
+	for row := 0; row < 1<<16; row += 7 {
+		vals := make([]bool, 16)
+		exp := true
+
+		for i := range vals {
+			vals[i] = row&(1<<i) != 0
+		}
+
+		for i := 0; i < 8; i++ {
+			exp = exp && vals[i] == vals[i+8]
+		}
+
+		if got := evalBdd(b, roots[0], vals); got != exp {
+			t.Fatalf("sifted diagram returned %v with %v, expected %v", got, vals, exp)
+		}
+	}
+}
@@ -0,0 +1,274 @@
+package lang
+
+import (
+	"strings"
+)
+
+// Reads the models in a BLIF file. Every model becomes a netlist with the
+// inputs and outputs it declares, `.names` tables become sums of products,
+// `.latch`es become registers, and `.subckt`s become calls to other models.
+func parseBLIF(src *source) ([]*netlist, []error) {
+	var nets []*netlist
+	var cur *netlist
+	var errs []error
+
+	lines := blifLines(src)
+
+	for i := 0; i < len(lines); i++ {
+		words := lines[i]
+		cmd := words[0]
+
+		if cmd.text != ".model" && cur == nil {
+			errs = append(errs, errorAt(cmd.span, "Expecting `.model` before `%s`.", cmd.text))
+			continue
+		}
+
+		switch cmd.text {
+		case ".model":
+			if len(words) != 2 {
+				errs = append(errs, errorAt(cmd.span, "Expecting a model name after `.model`."))
+				continue
+			}
+
+			cur = newNetlist(words[1])
+			nets = append(nets, cur)
+
+		case ".inputs", ".outputs":
+			for _, w := range words[1:] {
+				port := netPort{name: w, bits: []string{w.text}}
+
+				if cmd.text == ".inputs" {
+					cur.inputs = append(cur.inputs, port)
+				} else {
+					cur.outputs = append(cur.outputs, port)
+				}
+			}
+
+		case ".names":
+			if len(words) < 2 {
+				errs = append(errs, errorAt(cmd.span, "Expecting an output after `.names`."))
+				continue
+			}
+
+			var rows [][]netWord
+
+			for i+1 < len(lines) && !strings.HasPrefix(lines[i+1][0].text, ".") {
+				i++
+				rows = append(rows, lines[i])
+			}
+
+			ins, out := words[1:len(words)-1], words[len(words)-1]
+			expr, err := blifCover(cur, ins, out, rows)
+
+			if err == nil {
+				err = cur.drive(out, expr)
+			}
+
+			if err != nil {
+				errs = append(errs, err)
+			}
+
+		case ".latch":
+			if err := blifLatch(cur, words); err != nil {
+				errs = append(errs, err)
+			}
+
+		case ".subckt":
+			if err := blifSubckt(cur, words); err != nil {
+				errs = append(errs, err)
+			}
+
+		case ".end":
+			cur = nil
+
+		default:
+			if strings.HasPrefix(cmd.text, ".") {
+				errs = append(errs, errorAt(cmd.span, "Unsupported BLIF construct `%s`.", cmd.text))
+			} else {
+				errs = append(errs, errorAt(cmd.span, "Unexpected `%s`, expecting a "+
+					"BLIF command.", cmd.text))
+			}
+		}
+	}
+
+	return nets, errs
+}
+
+// Splits a BLIF file into lines of words. Comments start with a # and a
+// backslash at the end of a line continues it on the next one.
+func blifLines(src *source) [][]netWord {
+	var lines [][]netWord
+	var words []netWord
+
+	runes := src.runes
+	line, lineStart := 1, 0
+
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+
+		switch {
+		case r == nlRn:
+			if len(words) > 0 {
+				lines = append(lines, words)
+				words = nil
+			}
+
+			line++
+			lineStart = i + 1
+
+		case r == '#':
+			for i+1 < len(runes) && runes[i+1] != nlRn {
+				i++
+			}
+
+		case r == '\\' && i+1 < len(runes) && runes[i+1] == nlRn:
+			i++
+			line++
+			lineStart = i + 1
+
+		case isWhitespace(r):
+			continue
+
+		default:
+			start := i
+
+			for i+1 < len(runes) && !isWhitespace(runes[i+1]) && runes[i+1] != '#' {
+				i++
+			}
+
+			words = append(words, netWord{
+				text: string(runes[start : i+1]),
+				span: span{src: src, offset: start, end: i + 1, line: line, column: start - lineStart + 1},
+			})
+		}
+	}
+
+	if len(words) > 0 {
+		lines = append(lines, words)
+	}
+
+	return lines
+}
+
+// Turns the rows of a `.names` table into a sum of products. Rows list the
+// value of every input, with - for inputs that don't matter, followed by the
+// output. When the rows are for an output of 0 the sum is negated.
+func blifCover(n *netlist, ins []netWord, out netWord, rows [][]netWord) (Expr, error) {
+	var terms []Expr
+	on := true
+
+	for i, row := range rows {
+		plane, val := netWord{}, row[len(row)-1]
+
+		if len(ins) > 0 && len(row) == 2 {
+			plane = row[0]
+		} else if len(ins) > 0 || len(row) != 1 {
+			return nil, errorAt(row[0].span, "Expecting %d input values and an "+
+				"output value for `%s`.", len(ins), out.text)
+		}
+
+		if len([]rune(plane.text)) != len(ins) {
+			return nil, errorAt(plane.span, "Expecting %d input values but found %d.",
+				len(ins), len([]rune(plane.text)))
+		} else if val.text != "0" && val.text != "1" {
+			return nil, errorAt(val.span, "Invalid output value `%s`, expecting 0 or 1.", val.text)
+		} else if i > 0 && (val.text == "1") != on {
+			return nil, errorAt(val.span, "Rows for `%s` must all have the same "+
+				"output value.", out.text)
+		}
+
+		on = val.text == "1"
+		var lits []Expr
+
+		for j, r := range []rune(plane.text) {
+			switch r {
+			case '1':
+				lits = append(lits, n.ref(ins[j]))
+			case '0':
+				lits = append(lits, netNot(n.ref(ins[j]), plane.span))
+			case '-':
+			default:
+				return nil, errorAt(plane.span, "Invalid input value `%c`, expecting "+
+					"0, 1, or -.", r)
+			}
+		}
+
+		if len(lits) == 0 {
+			terms = append(terms, netLiteral(true, val.span))
+		} else {
+			terms = append(terms, netJoin(andTok, andRn, lits, plane.span))
+		}
+	}
+
+	if len(terms) == 0 {
+		return netLiteral(false, out.span), nil
+	}
+
+	sop := netJoin(orTok, orRn, terms, out.span)
+
+	if !on {
+		return netNot(sop, out.span), nil
+	}
+
+	return sop, nil
+}
+
+// `.latch input output [type control] [init]`. Initial values of 2 and 3
+// mean don't care and unknown, which start out as 0 like every register.
+func blifLatch(n *netlist, words []netWord) error {
+	if len(words) < 3 || len(words) > 6 {
+		return errorAt(words[0].span, "Expecting an input, an output, and an "+
+			"optional type, control, and initial value for `.latch`.")
+	}
+
+	args := []Expr{n.ref(words[1])}
+	rest := words[3:]
+
+	if len(rest) == 1 || len(rest) == 3 {
+		switch init := rest[len(rest)-1]; init.text {
+		case "0", "1":
+			args = append(args, netLiteral(init.text == "1", init.span))
+		case "2", "3":
+		default:
+			return errorAt(init.span, "Invalid initial value `%s`, expecting 0, 1, 2, or 3.", init.text)
+		}
+	}
+
+	return n.drive(words[2], &CallExpr{
+		callee: token{id: identTok, lexeme: "reg", span: words[0].span},
+		args:   args,
+		span:   words[0].span,
+	})
+}
+
+// `.subckt model formal=actual ...`
+func blifSubckt(n *netlist, words []netWord) error {
+	if len(words) < 2 {
+		return errorAt(words[0].span, "Expecting a model name after `.subckt`.")
+	}
+
+	inst := netInstance{model: words[1], conns: make(map[string]netWord)}
+
+	for _, w := range words[2:] {
+		eq := strings.Index(w.text, "=")
+
+		if eq <= 0 || eq == len(w.text)-1 {
+			return errorAt(w.span, "Expecting a connection like `formal=actual` but found `%s`.", w.text)
+		}
+
+		formal := w.text[:eq]
+		actual := w.span
+		actual.offset += len([]rune(formal)) + 1
+		actual.column += len([]rune(formal)) + 1
+
+		if _, ok := inst.conns[formal]; ok {
+			return errorAt(w.span, "Port `%s` is connected more than once.", formal)
+		}
+
+		inst.conns[formal] = netWord{text: w.text[eq+1:], span: actual}
+		inst.order = append(inst.order, formal)
+	}
+
+	n.instances = append(n.instances, inst)
+	return nil
+}
@@ -0,0 +1,623 @@
+package lang
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Gate calls nested deeper than this are assumed to be recursive.
+const maxCircuitDepth = 1000
+
+type nodeOp int
+
+const (
+	constNode nodeOp = iota
+	inputNode
+	notNode
+	andNode
+	orNode
+	xorNode
+)
+
+// A node in a circuit. Operands always come before the nodes that use them.
+type node struct {
+	op   nodeOp
+	a, b int
+}
+
+// A boolean circuit made up of inputs, constants, and logic gates. Node 0 is
+// false and node 1 is true. Nodes are shared, so building the same operation
+// on the same operands twice returns the same node.
+type circuit struct {
+	nodes  []node
+	names  []string
+	inputs map[string]int
+	hash   map[node]int
+}
+
+// A value in a circuit: a single node, or a sequence of other values.
+type wire struct {
+	node  int
+	list  bool
+	items []wire
+}
+
+func newCircuit() *circuit {
+	return &circuit{
+		nodes:  []node{{op: constNode, a: 0}, {op: constNode, a: 1}},
+		inputs: make(map[string]int),
+		hash:   make(map[node]int),
+	}
+}
+
+func (c *circuit) add(n node) int {
+	if id, ok := c.hash[n]; ok {
+		return id
+	}
+
+	c.nodes = append(c.nodes, n)
+	c.hash[n] = len(c.nodes) - 1
+	return len(c.nodes) - 1
+}
+
+// Returns the input with the given name, creating it the first time.
+func (c *circuit) input(name string) int {
+	if id, ok := c.inputs[name]; ok {
+		return id
+	}
+
+	id := c.add(node{op: inputNode, a: len(c.names)})
+	c.names = append(c.names, name)
+	c.inputs[name] = id
+	return id
+}
+
+func (c *circuit) constant(b bool) int {
+	if b {
+		return 1
+	}
+
+	return 0
+}
+
+func (c *circuit) not(a int) int {
+	switch {
+	case a < 2:
+		return 1 - a
+	case c.nodes[a].op == notNode:
+		return c.nodes[a].a
+	}
+
+	return c.add(node{op: notNode, a: a})
+}
+
+// Tells whether a and b are known to be negations of each other.
+func (c *circuit) opposite(a, b int) bool {
+	return (c.nodes[a].op == notNode && c.nodes[a].a == b) ||
+		(c.nodes[b].op == notNode && c.nodes[b].a == a)
+}
+
+func (c *circuit) and(a, b int) int {
+	switch {
+	case a == 0 || b == 0 || c.opposite(a, b):
+		return 0
+	case a == 1 || a == b:
+		return b
+	case b == 1:
+		return a
+	case a > b:
+		a, b = b, a
+	}
+
+	return c.add(node{op: andNode, a: a, b: b})
+}
+
+func (c *circuit) or(a, b int) int {
+	switch {
+	case a == 1 || b == 1 || c.opposite(a, b):
+		return 1
+	case a == 0 || a == b:
+		return b
+	case b == 0:
+		return a
+	case a > b:
+		a, b = b, a
+	}
+
+	return c.add(node{op: orNode, a: a, b: b})
+}
+
+func (c *circuit) xor(a, b int) int {
+	switch {
+	case a == b:
+		return 0
+	case c.opposite(a, b):
+		return 1
+	case a == 0:
+		return b
+	case b == 0:
+		return a
+	case a == 1:
+		return c.not(b)
+	case b == 1:
+		return c.not(a)
+	case a > b:
+		a, b = b, a
+	}
+
+	return c.add(node{op: xorNode, a: a, b: b})
+}
+
+// Returns a node that is true when two wires carry the same values. Wires of
+// different shapes are never equal.
+func (c *circuit) equal(a, b wire) int {
+	if a.list != b.list || len(a.items) != len(b.items) {
+		return 0
+	} else if !a.list {
+		return c.not(c.xor(a.node, b.node))
+	}
+
+	eq := 1
+
+	for i := range a.items {
+		eq = c.and(eq, c.equal(a.items[i], b.items[i]))
+	}
+
+	return eq
+}
+
+// Computes the value of every node for the given values of the inputs.
+func (c *circuit) eval(inputs []bool) []bool {
+	vals := make([]bool, len(c.nodes))
+
+	for id, n := range c.nodes {
+		switch n.op {
+		case constNode:
+			vals[id] = n.a == 1
+		case inputNode:
+			vals[id] = inputs[n.a]
+		case notNode:
+			vals[id] = !vals[n.a]
+		case andNode:
+			vals[id] = vals[n.a] && vals[n.b]
+		case orNode:
+			vals[id] = vals[n.a] || vals[n.b]
+		case xorNode:
+			vals[id] = vals[n.a] != vals[n.b]
+		}
+	}
+
+	return vals
+}
+
+// Looks for values of the inputs that make root true, and returns the value
+// of every node when there are some. Every node that root depends on becomes
+// a variable constrained to equal its operation on its operands, which keeps
+// the clauses linear in the size of the circuit.
+func (c *circuit) solve(root int) ([]bool, bool) {
+	used := make([]bool, len(c.nodes))
+	used[root] = true
+
+	for id := root; id >= 0; id-- {
+		if !used[id] {
+			continue
+		}
+
+		switch n := c.nodes[id]; n.op {
+		case notNode:
+			used[n.a] = true
+		case andNode, orNode, xorNode:
+			used[n.a] = true
+			used[n.b] = true
+		}
+	}
+
+	s := newSatSolver(len(c.nodes))
+	s.addClause(posLit(root))
+
+	for id, n := range c.nodes {
+		if !used[id] {
+			continue
+		}
+
+		x, a, b := posLit(id), posLit(n.a), posLit(n.b)
+
+		switch n.op {
+		case constNode:
+			if n.a == 1 {
+				s.addClause(x)
+			} else {
+				s.addClause(x.not())
+			}
+
+		case notNode:
+			s.addClause(x.not(), a.not())
+			s.addClause(x, a)
+
+		case andNode:
+			s.addClause(x.not(), a)
+			s.addClause(x.not(), b)
+			s.addClause(x, a.not(), b.not())
+
+		case orNode:
+			s.addClause(x, a.not())
+			s.addClause(x, b.not())
+			s.addClause(x.not(), a, b)
+
+		case xorNode:
+			s.addClause(x.not(), a, b)
+			s.addClause(x.not(), a.not(), b.not())
+			s.addClause(x, a.not(), b)
+			s.addClause(x, a, b.not())
+		}
+	}
+
+	return s.solve()
+}
+
+// Turns expressions into circuits. The result of the last expression visited
+// is stored in wire and errs.
+type blaster struct {
+	c     *circuit
+	scope *blastScope
+	wire  wire
+	errs  []error
+}
+
+// Bindings visible to an expression being turned into a circuit. Gate
+// arguments are already wires, and bindings are blasted once per scope, just
+// like they are evaluated once per gate call.
+type blastScope struct {
+	env   *environment
+	args  map[string]wire
+	done  map[string]wire
+	busy  map[string]bool
+	depth int
+}
+
+func newBlastScope(env *environment, depth int) *blastScope {
+	return &blastScope{
+		env:   env,
+		args:  make(map[string]wire),
+		done:  make(map[string]wire),
+		busy:  make(map[string]bool),
+		depth: depth,
+	}
+}
+
+// Builds the circuit for an expression evaluated in env, with inputs for the
+// identifiers that are not bound to anything.
+func blast(c *circuit, e Expr, env *environment, inputs map[string]wire) (wire, []error) {
+	scope := newBlastScope(env, 0)
+	scope.args = inputs
+	return (&blaster{c: c}).in(scope, e)
+}
+
+// Creates inputs for the free identifiers in an expression. Identifiers that
+// the expression indexes or passes to a gate expecting a sequence become
+// sequences of inputs.
+func freeInputs(c *circuit, e Expr, env *environment) ([]string, map[string]wire) {
+	names := freeIdentifiers(e, *env)
+	inputs := make(map[string]wire)
+	g := gate{body: e, env: env}
+
+	for _, name := range names {
+		g.args = append(g.args, token{id: identTok, lexeme: name})
+	}
+
+	for i, s := range newShapeInference().params(g) {
+		inputs[names[i]] = shapeWire(c, s, names[i])
+	}
+
+	return names, inputs
+}
+
+func (b *blaster) in(scope *blastScope, e Expr) (wire, []error) {
+	sub := &blaster{c: b.c, scope: scope}
+	e.Accept(sub)
+	return sub.wire, sub.errs
+}
+
+func (b *blaster) fail(errs ...error) {
+	b.wire = wire{}
+	b.errs = errs
+}
+
+// Builds the circuit for a call to a gate with the given arguments.
+func (b *blaster) call(name string, g gate, args []wire, depth int) (wire, []error) {
+	if depth > maxCircuitDepth {
+		return wire{}, []error{fmt.Errorf(
+			"Calls to `%s` are nested too deeply, is it recursive?", name)}
+	}
+
+	scope := newBlastScope(g.env, depth)
+
+	for i, arg := range g.args {
+		scope.args[arg.lexeme] = args[i]
+	}
+
+	return b.in(scope, g.body)
+}
+
+// Looks up and blasts a binding. The last return value is false when there
+// is no binding with that name.
+func (b *blaster) lookup(name string, sp span) (wire, []error, bool) {
+	scope := b.scope
+
+	if w, ok := scope.args[name]; ok {
+		return w, nil, true
+	} else if w, ok := scope.done[name]; ok {
+		return w, nil, true
+	}
+
+	c, ok := scope.env.getClosure(name)
+
+	if !ok {
+		return wire{}, nil, false
+	} else if scope.busy[name] {
+		return wire{}, []error{errorAt(sp,
+			"Detected circular reference in `%s` identifier", name)}, true
+	}
+
+	inner := scope
+
+	if c.env != nil {
+		inner = newBlastScope(c.env, scope.depth)
+	}
+
+	scope.busy[name] = true
+	w, errs := b.in(inner, c.expr)
+	delete(scope.busy, name)
+
+	if len(errs) == 0 {
+		scope.done[name] = w
+	}
+
+	return w, errs, true
+}
+
+func (b *blaster) VisitBadExpr(e *BadExpr) {
+	b.fail(errorAt(e.Span(), "Cannot evaluate expression due to error: %s", e.err))
+}
+
+func (b *blaster) VisitBinaryExpr(e *BinaryExpr) {
+	lhs, lhsErr := b.in(b.scope, e.lhs)
+	rhs, rhsErr := b.in(b.scope, e.rhs)
+
+	if errs := append(lhsErr, rhsErr...); len(errs) > 0 {
+		b.fail(errs...)
+		return
+	}
+
+	name, ok := binaryMethods[e.op.id]
+
+	if !ok {
+		b.fail(errorAt(e.op.span, "Unknown binary operator: %s", e.op.lexeme))
+		return
+	}
+
+	if name == "eq" {
+		if lhs.list != rhs.list {
+			b.fail(errorAt(e.Span(), "Type error, `eq` expects both arguments to be of the same type."))
+			return
+		}
+
+		b.wire = wire{node: b.c.equal(lhs, rhs)}
+		return
+	}
+
+	if lhs.list || rhs.list {
+		b.fail(errorAt(e.Span(), "Type error, `%s` expects single bits but got a sequence instead.", name))
+		return
+	}
+
+	c, l, r := b.c, lhs.node, rhs.node
+
+	switch name {
+	case "and":
+		b.wire = wire{node: c.and(l, r)}
+	case "or":
+		b.wire = wire{node: c.or(l, r)}
+	case "xor":
+		b.wire = wire{node: c.xor(l, r)}
+	case "mi", "le":
+		b.wire = wire{node: c.or(c.not(l), r)}
+	case "ge":
+		b.wire = wire{node: c.or(l, c.not(r))}
+	case "gt":
+		b.wire = wire{node: c.and(l, c.not(r))}
+	case "lt":
+		b.wire = wire{node: c.and(c.not(l), r)}
+	}
+}
+
+func (b *blaster) VisitUnaryExpr(e *UnaryExpr) {
+	w, errs := b.in(b.scope, e.rhs)
+
+	if len(errs) > 0 {
+		b.fail(errs...)
+		return
+	} else if _, ok := unaryMethods[e.op.id]; !ok {
+		b.fail(errorAt(e.op.span, "Unknown unary operator: %s", e.op.lexeme))
+		return
+	} else if w.list {
+		b.fail(errorAt(e.Span(), "Type error, `not` expects a single bit but got a sequence instead."))
+		return
+	}
+
+	b.wire = wire{node: b.c.not(w.node)}
+}
+
+func (b *blaster) VisitGroupExpr(e *GroupExpr) {
+	b.wire, b.errs = b.in(b.scope, e.inner)
+}
+
+func (b *blaster) VisitCallExpr(e *CallExpr) {
+	name := e.callee.lexeme
+
+	if g, ok := b.scope.env.getGate(name); ok {
+		if len(g.args) != len(e.args) {
+			b.fail(errorAt(e.span, "Arity error, `%s` "+
+				"expects %d arguments but got %d instead.",
+				name, len(g.args), len(e.args)))
+			return
+		}
+
+		var args []wire
+
+		for _, arg := range e.args {
+			w, errs := b.in(b.scope, arg)
+
+			if len(errs) > 0 {
+				b.fail(errs...)
+				return
+			}
+
+			args = append(args, w)
+		}
+
+		b.wire, b.errs = b.call(name, g, args, b.scope.depth+1)
+		b.errs = errorsAt(e.span, b.errs)
+	} else if isRegisterCall(e, *b.scope.env) {
+		b.fail(errorAt(e.span, "Cannot turn `%s` into a circuit, registers "+
+			"depend on the clock.", name))
+	} else {
+		b.wire, b.errs = b.index(e)
+	}
+}
+
+// Only constant indexes are supported, since the index picks which wire is
+// used rather than being a signal itself.
+func (b *blaster) index(e *CallExpr) (wire, []error) {
+	name := e.callee.lexeme
+
+	if len(e.args) != 1 {
+		return wire{}, []error{errorAt(e.callee.span, "Undefined gate `%s`", name)}
+	}
+
+	seq, errs, set := b.lookup(name, e.callee.span)
+
+	if !set {
+		return wire{}, []error{errorAt(e.callee.span, "Undefined gate `%s`", name)}
+	} else if len(errs) > 0 {
+		return wire{}, errs
+	}
+
+	idx, ok := constantIndex(e.args[0])
+
+	if !ok {
+		return wire{}, []error{errorAt(e.args[0].Span(),
+			"Expecting a constant index when accessing `%s`.", name)}
+	} else if !seq.list {
+		return wire{}, []error{errorAt(e.callee.span,
+			"Invalid operation, expecting `%s` to be a sequence", name)}
+	} else if idx >= len(seq.items) {
+		return wire{}, []error{errorAt(e.args[0].Span(),
+			"Out of bounds error, max is %d and tried to access %d on `%s` sequence.",
+			len(seq.items)-1, idx, name)}
+	}
+
+	return seq.items[idx], nil
+}
+
+func (b *blaster) VisitIdentExpr(e *IdentExpr) {
+	w, errs, set := b.lookup(e.name.lexeme, e.name.span)
+
+	if !set {
+		b.fail(errorAt(e.name.span, "Undefined identifier `%s`", e.name.lexeme))
+		return
+	}
+
+	b.wire, b.errs = w, errs
+}
+
+func (b *blaster) VisitLiteralExpr(e *LiteralExpr) {
+	b.wire = wire{node: b.c.constant(e.value)}
+}
+
+func (b *blaster) VisitSeqExpr(e *SeqExpr) {
+	w := wire{list: true}
+
+	for _, item := range e.items {
+		iw, errs := b.in(b.scope, item)
+
+		if len(errs) > 0 {
+			b.fail(errs...)
+			return
+		}
+
+		w.items = append(w.items, iw)
+	}
+
+	b.wire = w
+}
+
+func (b *blaster) VisitNumberExpr(e *NumberExpr) {
+	if _, err := strconv.Atoi(e.tok.lexeme); err != nil {
+		b.fail(errorAt(e.tok.span, "Error converting to number: %v", err))
+		return
+	}
+
+	b.fail(errorAt(e.tok.span, "Cannot turn `%s` into a circuit, numbers "+
+		"are only used to access items in a sequence.", e.tok.lexeme))
+}
+
+// Returns the node of every bit in a wire, in order.
+func (w wire) bits() []int {
+	if !w.list {
+		return []int{w.node}
+	}
+
+	var nodes []int
+
+	for _, item := range w.items {
+		nodes = append(nodes, item.bits()...)
+	}
+
+	return nodes
+}
+
+func wireShape(w wire) shape {
+	if !w.list {
+		return bit
+	}
+
+	s := shape{list: true}
+
+	for _, item := range w.items {
+		s.items = append(s.items, wireShape(item))
+	}
+
+	return s
+}
+
+// Returns a wire of the given shape made up of new inputs. Items of a
+// sequence are named after the sequence and their index.
+func shapeWire(c *circuit, s shape, name string) wire {
+	if !s.list {
+		return wire{node: c.input(name)}
+	}
+
+	w := wire{list: true}
+
+	for i, item := range s.items {
+		w.items = append(w.items, shapeWire(c, item, fmt.Sprintf("%s(%d)", name, i)))
+	}
+
+	return w
+}
+
+// Returns an expression with the values a model gives to a wire.
+func wireExpr(w wire, model []bool) Expr {
+	if !w.list {
+		return &LiteralExpr{value: model[w.node]}
+	}
+
+	seq := &SeqExpr{}
+
+	for _, item := range w.items {
+		seq.items = append(seq.items, wireExpr(item, model))
+	}
+
+	return seq
+}
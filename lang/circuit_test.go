@@ -0,0 +1,67 @@
+package lang
+
+import "testing"
+
+func TestBlast(t *testing.T) {
+	rt := newTestRuntime(t,
+		"import \"std/mux.bool\"",
+		"gate Maj (a, b, c) = (a ∧ b) ∨ (a ∧ c) ∨ (b ∧ c)",
+		"gate Pick (x) = x(1)",
+		"ab is a ⊕ b",
+	)
+
+	tests := []string{
+		"a ∧ b ∨ ¬c",
+		"a → b → c",
+		"a ≡ ¬b",
+		"a ≥ b",
+		"a > b",
+		"a ≤ b",
+		"a < b",
+		"a ⊕ b ⊕ c ⊕ a",
+		"ab ∧ c",
+		"Maj(a, b, ¬c)",
+		"mux.Mux(a, b, c)",
+		"mux.Demux(a, b) = [c, c]",
+		"Pick([a, b]) ∨ c",
+		"[a, [b, c]] = [a, [c, b]]",
+		"[a, b] = [a]",
+	}
+
+	for _, src := range tests {
+		expr, errs := parseExpr(src)
+
+		if len(errs) > 0 {
+			t.Fatalf("parse(%q) errors = %v", src, errs)
+		}
+
+		c := newCircuit()
+		names, inputs := freeInputs(c, expr, &rt.env)
+		w, errs := blast(c, expr, &rt.env, inputs)
+
+		if len(errs) > 0 {
+			t.Errorf("blast(%q) errors = %v", src, errs)
+			continue
+		}
+
+		for row := 0; row < 1<<len(names); row++ {
+			scope := newEnvironment(&rt.env)
+			vals := make([]bool, len(names))
+
+			for i, name := range names {
+				vals[i] = row&(1<<i) != 0
+				scope.setBinding(name, &LiteralExpr{value: vals[i]})
+			}
+
+			exp, errs := evaluate(expr, scope)
+
+			if len(errs) > 0 {
+				t.Fatalf("evaluate(%q) errors = %v", src, errs)
+			}
+
+			if got := c.eval(vals)[w.node]; got != exp.boolean.internal {
+				t.Errorf("circuit for %q returned %v with %v, expected %v", src, got, vals, exp.boolean.internal)
+			}
+		}
+	}
+}
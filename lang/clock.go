@@ -0,0 +1,162 @@
+package lang
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Names of the register primitive. `reg(d)` evaluates to the value the
+// register latched on the last clock cycle, which starts out as false or as
+// the optional second argument in `reg(d, init)`, and `d` is only evaluated
+// when the clock ticks. Since reading a register never
+// evaluates its input, feedback through a register is not circular.
+var registerNames = map[string]bool{
+	"dff": true,
+	"reg": true,
+}
+
+// The clock drives every register in a runtime. Registers are identified by
+// the reg call that creates them along with the path of gate calls leading to
+// it, so every instance of a gate gets its own registers.
+type clock struct {
+	cycle int
+	regs  map[string]*register
+	order []string
+}
+
+type register struct {
+	input Expr
+	env   environment
+	val   value
+	seen  bool
+}
+
+func newClock() *clock {
+	return &clock{regs: make(map[string]*register)}
+}
+
+// Checks if a call is to the register primitive, which gates with the same
+// name take precedence over.
+func isRegisterCall(e *CallExpr, env environment) bool {
+	if !registerNames[e.callee.lexeme] {
+		return false
+	}
+
+	_, isGate := env.getGate(e.callee.lexeme)
+	return !isGate
+}
+
+func (ev *evaluator) register(e *CallExpr) (value, []error) {
+	if len(e.args) != 1 && len(e.args) != 2 {
+		return value{}, []error{errorAt(e.span, "Arity error, `%s` "+
+			"expects 1 or 2 arguments but got %d instead.",
+			e.callee.lexeme, len(e.args))}
+	}
+
+	clk := ev.env.clock
+	key := fmt.Sprintf("%s%p", ev.env.path, e)
+	reg, ok := clk.regs[key]
+
+	if !ok {
+		reg = &register{val: value{boolean: &boolean{false}}}
+
+		if len(e.args) == 2 {
+			init, errs := evaluate(e.args[1], ev.env)
+
+			if len(errs) == 0 && init.isSequence() {
+				var snapshot sequence
+				snapshot, errs = init.sequence.freeze(ev.env)
+				init = value{sequence: &snapshot}
+			}
+
+			if len(errs) > 0 {
+				return value{}, errs
+			}
+
+			reg.val = init
+		}
+
+		clk.regs[key] = reg
+		clk.order = append(clk.order, key)
+	}
+
+	// The input is evaluated in the environment the register was last read
+	// from, which is where its inputs are in scope.
+	reg.input = e.args[0]
+	reg.env = ev.env
+	reg.seen = true
+
+	return reg.val, nil
+}
+
+// Advances the clock by one cycle. Registers are found by evaluating every
+// binding in the global scope, and every one of them latches its input at
+// the same time, so registers that feed each other see the values from
+// before the tick. Registers that can no longer be reached are discarded.
+func (rt *Runtime) Step() error {
+	clk := rt.env.clock
+
+	for _, reg := range clk.regs {
+		reg.seen = false
+	}
+
+	var names []string
+
+	for name := range rt.env.bindings {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		val, errs := evaluate(&IdentExpr{name: token{id: identTok, lexeme: name}}, rt.env)
+
+		// Registers in sequences are only read once the items are evaluated.
+		if len(errs) == 0 && val.isSequence() {
+			val.sequence.freeze(rt.env)
+		}
+	}
+
+	var keys []string
+	var next []value
+	var errs []error
+
+	for _, key := range clk.order {
+		reg := clk.regs[key]
+
+		if !reg.seen {
+			delete(clk.regs, key)
+			continue
+		}
+
+		val, verrs := evaluate(reg.input, reg.env)
+
+		if len(verrs) == 0 && val.isSequence() {
+			var snapshot sequence
+			snapshot, verrs = val.sequence.freeze(reg.env)
+			val = value{sequence: &snapshot}
+		}
+
+		errs = append(errs, verrs...)
+		keys = append(keys, key)
+		next = append(next, val)
+	}
+
+	clk.order = keys
+
+	if len(errs) > 0 {
+		return &Error{Phase: EvalPhase, Errors: errs}
+	}
+
+	for i, key := range keys {
+		clk.regs[key].val = next[i]
+	}
+
+	clk.cycle++
+	return nil
+}
+
+// Returns the number of clock cycles the runtime has gone through.
+func (rt *Runtime) Cycle() int {
+	return rt.env.clock.cycle
+}
@@ -0,0 +1,72 @@
+package lang
+
+import "testing"
+
+func TestStep(t *testing.T) {
+	rt := newTestRuntime(t,
+		"gate Counter2 (en) = [hi, lo]",
+		"  where lo is reg(lo ⊕ en)",
+		"    and hi is dff(hi ⊕ (lo ∧ en))",
+		"count is reg(¬count)",
+		"c is Counter2(1)",
+		"d is Counter2(en)",
+		"en is 0",
+		"shift is reg([in, shift(0), shift(1)], [0, 0, 0])",
+		"in is 1",
+	)
+
+	tests := []string{
+		"Seq[4]{0, Seq[2]{0, 0}, Seq[2]{0, 0}, Seq[3]{0, 0, 0}}",
+		"Seq[4]{1, Seq[2]{0, 1}, Seq[2]{0, 0}, Seq[3]{1, 0, 0}}",
+		"Seq[4]{0, Seq[2]{1, 0}, Seq[2]{0, 0}, Seq[3]{1, 1, 0}}",
+		"Seq[4]{1, Seq[2]{1, 1}, Seq[2]{0, 0}, Seq[3]{1, 1, 1}}",
+	}
+
+	for cycle, exp := range tests {
+		if cycle > 0 {
+			if err := rt.Step(); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		val, err := rt.Eval("[count, c, d, shift]")
+
+		if err != nil {
+			t.Fatal(err)
+		} else if val.String() != exp {
+			t.Errorf("cycle %d: got %s, expected %s", cycle, val, exp)
+		}
+	}
+
+	if rt.Cycle() != 3 {
+		t.Errorf("expected 3 cycles but got %d", rt.Cycle())
+	}
+}
+
+func TestStepErrors(t *testing.T) {
+	rt := newTestRuntime(t, "q is reg(a ∧ 1)")
+
+	if err := rt.Step(); err == nil {
+		t.Error("expected an error for the undefined `a` identifier")
+	}
+
+	if rt.Cycle() != 0 {
+		t.Errorf("expected the clock to stay put but it is at %d", rt.Cycle())
+	}
+
+	if _, err := rt.Eval("a is 1"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := rt.Step(); err != nil {
+		t.Fatal(err)
+	}
+
+	if val, _ := rt.Eval("q"); !val.Bool() {
+		t.Errorf("expected the register to latch true but got %s", val)
+	}
+
+	if _, err := rt.Eval("x is ¬x"); err == nil {
+		t.Error("expected a circular reference error without a register")
+	}
+}
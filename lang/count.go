@@ -0,0 +1,102 @@
+package lang
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+)
+
+// The number of assignments of an expression's inputs that make each of its
+// output bits true. Items of a sequence are separate inputs, named like
+// `x(0)`.
+type ModelCount struct {
+	Inputs []string
+	Counts []*big.Int
+}
+
+// Counts the assignments of the free identifiers of src, which is the name of
+// a gate or an expression, that make it true. The count is taken from the
+// expression's binary decision diagram instead of by evaluating it for every
+// assignment.
+func (rt *Runtime) Count(src string) (ModelCount, error) {
+	b, roots, names, err := rt.bdd(src)
+
+	if err != nil {
+		return ModelCount{}, err
+	}
+
+	m := ModelCount{Inputs: names}
+
+	for _, root := range roots {
+		m.Counts = append(m.Counts, b.satCount(root))
+	}
+
+	return m, nil
+}
+
+// Returns the probability that each output bit of src is true when its inputs
+// are independent and true with the probabilities in probs. A probability can
+// be given for an item of a sequence, such as `x(0)`, or for the whole
+// sequence. Inputs without one are true half of the time.
+func (rt *Runtime) Prob(src string, probs map[string]*big.Rat) ([]*big.Rat, error) {
+	b, roots, names, err := rt.bdd(src)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []string
+
+	for name := range probs {
+		keys = append(keys, name)
+	}
+
+	sort.Strings(keys)
+
+	var errs []error
+	used := make(map[string]bool)
+	p := make([]*big.Rat, len(names))
+
+	for _, name := range keys {
+		if r := probs[name]; r.Sign() < 0 || r.Cmp(big.NewRat(1, 1)) > 0 {
+			errs = append(errs, fmt.Errorf(
+				"The probability of `%s` must be between 0 and 1 but got %s.", name, r.RatString()))
+		}
+	}
+
+	for v, name := range names {
+		base := name
+
+		if i := strings.IndexByte(name, '('); i >= 0 {
+			base = name[:i]
+		}
+
+		if r, ok := probs[name]; ok {
+			p[v], used[name] = r, true
+		} else if r, ok := probs[base]; ok {
+			p[v], used[base] = r, true
+		} else {
+			p[v] = big.NewRat(1, 2)
+		}
+	}
+
+	for _, name := range keys {
+		if !used[name] {
+			errs = append(errs, fmt.Errorf(
+				"Cannot set the probability of `%s`, it is not an input of the expression.", name))
+		}
+	}
+
+	if len(errs) > 0 {
+		return nil, &Error{Phase: EvalPhase, Errors: errs}
+	}
+
+	var res []*big.Rat
+
+	for _, root := range roots {
+		res = append(res, b.prob(root, p))
+	}
+
+	return res, nil
+}
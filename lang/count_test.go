@@ -0,0 +1,112 @@
+package lang
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+)
+
+func TestCount(t *testing.T) {
+	rt := newTestRuntime(t,
+		"import \"std/adders.bool\"",
+		"import \"std/comparators.bool\"",
+		"t is 1",
+	)
+
+	tests := []struct {
+		src    string
+		inputs string
+		counts string
+	}{
+		{"a ∧ b ∨ c", "a b c", "5"},
+		{"a ∧ ¬a", "a", "0"},
+		{"t", "", "1"},
+		{"x(0) ⊕ x(2)", "x(0) x(1) x(2)", "4"},
+		{"comparators.Gt8", "x(0) x(1) x(2) x(3) x(4) x(5) x(6) x(7) y(0) y(1) y(2) y(3) y(4) y(5) y(6) y(7)", "32640"},
+		{"adders.FullAdder", "a b c", "4 4"},
+	}
+
+	for _, test := range tests {
+		m, err := rt.Count(test.src)
+
+		if err != nil {
+			t.Errorf("Count(%q) error = %v", test.src, err)
+			continue
+		}
+
+		var counts []string
+
+		for _, c := range m.Counts {
+			counts = append(counts, c.String())
+		}
+
+		if got := strings.Join(m.Inputs, " "); got != test.inputs {
+			t.Errorf("Count(%q) inputs = %s, expected %s", test.src, got, test.inputs)
+		} else if got := strings.Join(counts, " "); got != test.counts {
+			t.Errorf("Count(%q) = %s, expected %s", test.src, got, test.counts)
+		}
+	}
+
+	if _, err := rt.Count("reg(a)"); err == nil {
+		t.Error("expected Count to fail on a register")
+	}
+}
+
+func TestProb(t *testing.T) {
+	rt := newTestRuntime(t, "import \"std/adders.bool\"")
+
+	rat := func(s string) *big.Rat {
+		r, _ := new(big.Rat).SetString(s)
+		return r
+	}
+
+	tests := []struct {
+		src   string
+		probs map[string]*big.Rat
+		exp   string
+	}{
+		{"a ∧ b", nil, "1/4"},
+		{"a ∧ b", map[string]*big.Rat{"a": rat("0.3"), "b": rat("0.9")}, "27/100"},
+		{"a ∨ b", map[string]*big.Rat{"a": rat("0.3"), "b": rat("0.9")}, "93/100"},
+		{"a ⊕ b", map[string]*big.Rat{"a": rat("1/3")}, "1/2"},
+		{"a → b", map[string]*big.Rat{"a": rat("1"), "b": rat("0")}, "0"},
+		{"x(0) ∧ x(1)", map[string]*big.Rat{"x": rat("0.5"), "x(1)": rat("0.1")}, "1/20"},
+		{"adders.FullAdder", map[string]*big.Rat{"c": rat("0")}, "1/2 1/4"},
+	}
+
+	for _, test := range tests {
+		res, err := rt.Prob(test.src, test.probs)
+
+		if err != nil {
+			t.Errorf("Prob(%q) error = %v", test.src, err)
+			continue
+		}
+
+		var got []string
+
+		for _, r := range res {
+			got = append(got, r.RatString())
+		}
+
+		if strings.Join(got, " ") != test.exp {
+			t.Errorf("Prob(%q) = %v, expected %s", test.src, got, test.exp)
+		}
+	}
+
+	errTests := []struct {
+		probs map[string]*big.Rat
+		err   string
+	}{
+		{map[string]*big.Rat{"a": rat("3/2")}, "The probability of `a` must be between 0 and 1 but got 3/2."},
+		{map[string]*big.Rat{"a": rat("-0.1")}, "The probability of `a` must be between 0 and 1 but got -1/10."},
+		{map[string]*big.Rat{"z": rat("0.5")}, "Cannot set the probability of `z`, it is not an input of the expression."},
+	}
+
+	for _, test := range errTests {
+		_, err := rt.Prob("a ∧ b", test.probs)
+
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("Prob(%v) error = %v, expected %q", test.probs, err, test.err)
+		}
+	}
+}
@@ -0,0 +1,398 @@
+package lang
+
+import (
+	"fmt"
+	"strings"
+)
+
+// How operators are drawn. Graphviz doesn't have logic gate shapes, so these
+// are the closest ones it has: a house for ∧, a triangle for ∨ with a second
+// outline for ⊕, an inverter bubble for ¬, and diamonds for comparisons.
+var dotOperators = map[tokenId]struct {
+	label string
+	attrs string
+}{
+	andTok: {string(andRn), "shape=invhouse"},
+	eqTok:  {string(eqRn), "shape=diamond"},
+	geTok:  {string(geRn), "shape=diamond"},
+	gtTok:  {string(gtRn), "shape=diamond"},
+	leTok:  {string(leRn), "shape=diamond"},
+	ltTok:  {string(ltRn), "shape=diamond"},
+	miTok:  {string(miRn), "shape=diamond"},
+	notTok: {string(notRn), "shape=circle, width=0.3, fixedsize=true"},
+	orTok:  {string(orRn), "shape=invtriangle"},
+	xorTok: {string(xorRn), "shape=invtriangle, peripheries=2"},
+}
+
+// One end of a wire. Wires that come out of a local binding are labeled with
+// its name, and wires that select an item in a sequence are labeled with the
+// item's index. Locals that are still being drawn when they are used, which
+// only happens when they feed back into a register, are looked up once the
+// whole graph is drawn.
+type dotPort struct {
+	node  string
+	label string
+	frame *dotFrame
+	local string
+}
+
+type dotEdge struct {
+	from dotPort
+	to   string
+}
+
+// A gate being drawn, either the gate the graph is for or a gate that was
+// inlined into it.
+type dotFrame struct {
+	g      gate
+	args   map[string]dotPort
+	locals map[string]Expr
+	ports  map[string]dotPort
+	busy   map[string]bool
+}
+
+// Draws a gate as a Graphviz graph with a node for every input, operator,
+// register, and gate call, and an edge for every wire between them.
+type dotWriter struct {
+	inline bool
+	lines  []string
+	depth  int
+	edges  []dotEdge
+	count  int
+	boxes  int
+	free   map[string]string
+	frames []*dotFrame
+
+	// Result of the last expression visited.
+	port dotPort
+}
+
+func (d *dotWriter) frame() *dotFrame {
+	return d.frames[len(d.frames)-1]
+}
+
+func (d *dotWriter) line(format string, args ...interface{}) {
+	d.lines = append(d.lines, strings.Repeat("    ", d.depth)+fmt.Sprintf(format, args...))
+}
+
+func (d *dotWriter) node(label, attrs string) string {
+	id := fmt.Sprintf("n%d", d.count)
+	d.count++
+
+	if attrs != "" {
+		attrs = ", " + attrs
+	}
+
+	d.line("%s [label=%s%s];", id, dotQuote(label), attrs)
+	return id
+}
+
+func (d *dotWriter) edge(from dotPort, to string) {
+	d.edges = append(d.edges, dotEdge{from: from, to: to})
+}
+
+func (d *dotWriter) draw(e Expr) dotPort {
+	e.Accept(d)
+	return d.port
+}
+
+// Draws a gate's body and locals with its arguments connected to the given
+// ports.
+func (d *dotWriter) gate(g gate, args []dotPort) dotPort {
+	f := &dotFrame{
+		g:      g,
+		args:   make(map[string]dotPort),
+		locals: make(map[string]Expr),
+		ports:  make(map[string]dotPort),
+		busy:   make(map[string]bool),
+	}
+
+	for i, arg := range g.args {
+		f.args[arg.lexeme] = args[i]
+	}
+
+	for _, local := range g.locals {
+		if _, isArg := f.args[local.label.lexeme]; !isArg {
+			f.locals[local.label.lexeme] = local.value
+		}
+	}
+
+	d.frames = append(d.frames, f)
+	defer func() { d.frames = d.frames[:len(d.frames)-1] }()
+
+	for _, local := range g.locals {
+		if _, ok := f.locals[local.label.lexeme]; ok {
+			d.local(local.label.lexeme)
+		}
+	}
+
+	return d.draw(g.body)
+}
+
+func (d *dotWriter) local(name string) dotPort {
+	f := d.frame()
+
+	if port, ok := f.ports[name]; ok {
+		return port
+	} else if f.busy[name] {
+		return dotPort{label: name, frame: f, local: name}
+	}
+
+	f.busy[name] = true
+	port := d.draw(f.locals[name])
+	port.label = name
+	f.ports[name] = port
+	return port
+}
+
+func (d *dotWriter) VisitBadExpr(e *BadExpr) {
+	d.port = dotPort{node: d.node(fmt.Sprintf("error: %s", e.err), "shape=octagon")}
+}
+
+func (d *dotWriter) VisitBinaryExpr(e *BinaryExpr) {
+	lhs := d.draw(e.lhs)
+	rhs := d.draw(e.rhs)
+	op := dotOperators[e.op.id]
+	id := d.node(op.label, op.attrs)
+
+	d.edge(lhs, id)
+	d.edge(rhs, id)
+	d.port = dotPort{node: id}
+}
+
+func (d *dotWriter) VisitUnaryExpr(e *UnaryExpr) {
+	rhs := d.draw(e.rhs)
+	op := dotOperators[e.op.id]
+	id := d.node(op.label, op.attrs)
+
+	d.edge(rhs, id)
+	d.port = dotPort{node: id}
+}
+
+func (d *dotWriter) VisitGroupExpr(e *GroupExpr) {
+	d.draw(e.inner)
+}
+
+func (d *dotWriter) VisitCallExpr(e *CallExpr) {
+	env := d.frame().g.env
+
+	if g, ok := env.getGate(e.callee.lexeme); ok {
+		d.call(g, e)
+	} else if isRegisterCall(e, *env) {
+		d.register(e)
+	} else {
+		d.index(e)
+	}
+}
+
+// Gate calls are drawn as a box with a port for every argument, or, when
+// inlining, as a cluster with the gate's own circuit in it. Gates that call
+// themselves are always drawn as boxes.
+func (d *dotWriter) call(g gate, e *CallExpr) {
+	var args []dotPort
+
+	for _, arg := range e.args {
+		args = append(args, d.draw(arg))
+	}
+
+	recursive := false
+
+	for _, f := range d.frames {
+		recursive = recursive || f.g.body == g.body
+	}
+
+	if d.inline && !recursive && len(args) == len(g.args) {
+		d.line("subgraph cluster_%d {", d.boxes)
+		d.boxes++
+		d.depth++
+		d.line("label=%s;", dotQuote(e.callee.lexeme))
+		d.port = d.gate(g, args)
+		d.depth--
+		d.line("}")
+		return
+	}
+
+	var fields []string
+
+	for i, arg := range g.args {
+		fields = append(fields, fmt.Sprintf("<p%d> %s", i, dotEscape(arg.lexeme)))
+	}
+
+	label := fmt.Sprintf("{{%s}|%s}", strings.Join(fields, "|"), dotEscape(e.callee.lexeme))
+	id := d.node(label, "shape=record")
+
+	for i, arg := range args {
+		if i < len(g.args) {
+			d.edge(arg, fmt.Sprintf("%s:p%d", id, i))
+		} else {
+			d.edge(arg, id)
+		}
+	}
+
+	d.port = dotPort{node: id}
+}
+
+// Registers are drawn as a box with their input and initial value wired into
+// it. The register's node exists before its input is drawn so that a local
+// bound to it can feed back into it.
+func (d *dotWriter) register(e *CallExpr) {
+	id := d.node(e.callee.lexeme, "shape=box, style=bold")
+	f := d.frame()
+
+	for name, local := range f.locals {
+		if f.busy[name] && unwrapGroup(local) == Expr(e) {
+			f.ports[name] = dotPort{node: id, label: name}
+		}
+	}
+
+	for _, arg := range e.args {
+		d.edge(d.draw(arg), id)
+	}
+
+	d.port = dotPort{node: id}
+}
+
+// Selecting an item in a sequence with a constant index is just a wire from
+// the sequence, while other indexes are drawn as a selector with the index
+// wired into it.
+func (d *dotWriter) index(e *CallExpr) {
+	seq := d.ident(e.callee)
+
+	if len(e.args) == 1 {
+		if idx, ok := constantIndex(e.args[0]); ok {
+			if seq.label != "" {
+				seq.label = fmt.Sprintf("%s(%d)", seq.label, idx)
+			} else {
+				seq.label = fmt.Sprintf("%s(%d)", e.callee.lexeme, idx)
+			}
+
+			d.port = seq
+			return
+		}
+	}
+
+	id := d.node(e.callee.lexeme+"(…)", "shape=trapezium")
+	d.edge(seq, id)
+
+	for _, arg := range e.args {
+		d.edge(d.draw(arg), id)
+	}
+
+	d.port = dotPort{node: id}
+}
+
+// Arguments are inputs to the graph, or wires from the caller when the gate
+// is inlined. Any other name that isn't a local is drawn as an input as well.
+func (d *dotWriter) ident(name token) dotPort {
+	f := d.frame()
+
+	if port, isArg := f.args[name.lexeme]; isArg {
+		return port
+	} else if _, isLocal := f.locals[name.lexeme]; isLocal {
+		return d.local(name.lexeme)
+	} else if id, ok := d.free[name.lexeme]; ok {
+		return dotPort{node: id}
+	}
+
+	id := d.node(name.lexeme, "shape=plaintext")
+	d.free[name.lexeme] = id
+	return dotPort{node: id}
+}
+
+func (d *dotWriter) VisitIdentExpr(e *IdentExpr) {
+	d.port = d.ident(e.name)
+}
+
+func (d *dotWriter) VisitLiteralExpr(e *LiteralExpr) {
+	if e.value {
+		d.port = dotPort{node: d.node("1", "shape=square")}
+	} else {
+		d.port = dotPort{node: d.node("0", "shape=square")}
+	}
+}
+
+// Sequences are drawn as a record with a field for every item.
+func (d *dotWriter) VisitSeqExpr(e *SeqExpr) {
+	var items []dotPort
+	var fields []string
+
+	for i, item := range e.items {
+		items = append(items, d.draw(item))
+		fields = append(fields, fmt.Sprintf("<i%d> %d", i, i))
+	}
+
+	id := d.node(strings.Join(fields, "|"), "shape=record")
+
+	for i, item := range items {
+		d.edge(item, fmt.Sprintf("%s:i%d", id, i))
+	}
+
+	d.port = dotPort{node: id}
+}
+
+func (d *dotWriter) VisitNumberExpr(e *NumberExpr) {
+	d.port = dotPort{node: d.node(e.tok.lexeme, "shape=plaintext")}
+}
+
+func dotQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// Escapes the characters that have a special meaning in record labels.
+func dotEscape(s string) string {
+	return strings.NewReplacer(`{`, `\{`, `}`, `\}`, `|`, `\|`, `<`, `\<`,
+		`>`, `\>`, ` `, `\ `).Replace(s)
+}
+
+// Draws a gate as a Graphviz DOT graph, with its arguments as inputs on the
+// left and its value as the output on the right. Gates it calls are drawn as
+// boxes, or inlined as clusters containing their own circuits.
+func (rt *Runtime) Dot(name string, inline bool) (string, error) {
+	g, ok := rt.env.getGate(name)
+
+	if !ok {
+		return "", &Error{Phase: EvalPhase, Errors: []error{
+			fmt.Errorf("Undefined gate `%s`", name)}}
+	}
+
+	d := &dotWriter{
+		inline: inline,
+		depth:  1,
+		free:   make(map[string]string),
+	}
+
+	d.line("rankdir=LR;")
+
+	var args []dotPort
+
+	for _, arg := range g.args {
+		args = append(args, dotPort{node: d.node(arg.lexeme, "shape=plaintext")})
+	}
+
+	d.edge(d.gate(g, args), d.node(name, "shape=doublecircle"))
+
+	var buff strings.Builder
+
+	fmt.Fprintf(&buff, "digraph %s {\n", dotQuote(name))
+
+	for _, line := range d.lines {
+		fmt.Fprintf(&buff, "%s\n", line)
+	}
+
+	for _, edge := range d.edges {
+		from := edge.from
+
+		if from.frame != nil {
+			from.node = from.frame.ports[from.local].node
+		}
+
+		if from.label != "" {
+			fmt.Fprintf(&buff, "    %s -> %s [label=%s];\n", from.node, edge.to, dotQuote(from.label))
+		} else {
+			fmt.Fprintf(&buff, "    %s -> %s;\n", from.node, edge.to)
+		}
+	}
+
+	buff.WriteString("}\n")
+	return buff.String(), nil
+}
@@ -0,0 +1,91 @@
+package lang
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDot(t *testing.T) {
+	rt := newTestRuntime(t,
+		"gate Not (a) = ¬a",
+		"gate Pick (x, i) = Not(x(1)) ∨ x(i)",
+		"gate Toggle (en) = t",
+		"  where t is reg(t ⊕ en)",
+	)
+
+	tests := []struct {
+		gate   string
+		inline bool
+		exp    []string
+	}{
+		{"Pick", false, []string{
+			`digraph "Pick" {`,
+			`    rankdir=LR;`,
+			`    n0 [label="x", shape=plaintext];`,
+			`    n1 [label="i", shape=plaintext];`,
+			`    n2 [label="{{<p0> a}|Not}", shape=record];`,
+			`    n3 [label="x(…)", shape=trapezium];`,
+			`    n4 [label="∨", shape=invtriangle];`,
+			`    n5 [label="Pick", shape=doublecircle];`,
+			`    n0 -> n2:p0 [label="x(1)"];`,
+			`    n0 -> n3;`,
+			`    n1 -> n3;`,
+			`    n2 -> n4;`,
+			`    n3 -> n4;`,
+			`    n4 -> n5;`,
+			`}`,
+		}},
+		{"Pick", true, []string{
+			`digraph "Pick" {`,
+			`    rankdir=LR;`,
+			`    n0 [label="x", shape=plaintext];`,
+			`    n1 [label="i", shape=plaintext];`,
+			`    subgraph cluster_0 {`,
+			`        label="Not";`,
+			`        n2 [label="¬", shape=circle, width=0.3, fixedsize=true];`,
+			`    }`,
+			`    n3 [label="x(…)", shape=trapezium];`,
+			`    n4 [label="∨", shape=invtriangle];`,
+			`    n5 [label="Pick", shape=doublecircle];`,
+			`    n0 -> n2 [label="x(1)"];`,
+			`    n0 -> n3;`,
+			`    n1 -> n3;`,
+			`    n2 -> n4;`,
+			`    n3 -> n4;`,
+			`    n4 -> n5;`,
+			`}`,
+		}},
+		{"Toggle", false, []string{
+			`digraph "Toggle" {`,
+			`    rankdir=LR;`,
+			`    n0 [label="en", shape=plaintext];`,
+			`    n1 [label="reg", shape=box, style=bold];`,
+			`    n2 [label="⊕", shape=invtriangle, peripheries=2];`,
+			`    n3 [label="Toggle", shape=doublecircle];`,
+			`    n1 -> n2 [label="t"];`,
+			`    n0 -> n2;`,
+			`    n2 -> n1;`,
+			`    n1 -> n3 [label="t"];`,
+			`}`,
+		}},
+	}
+
+	for _, test := range tests {
+		out, err := rt.Dot(test.gate, test.inline)
+
+		if err != nil {
+			t.Errorf("Dot(%q, %t) error = %v", test.gate, test.inline, err)
+			continue
+		}
+
+		exp := strings.Join(test.exp, "\n") + "\n"
+
+		if out != exp {
+			t.Errorf("Dot(%q, %t) got:\n%s\nexpected:\n%s", test.gate, test.inline, out, exp)
+		}
+	}
+
+	if _, err := rt.Dot("Nope", false); err == nil {
+		t.Errorf("expected an error for an undefined gate")
+	}
+}
@@ -0,0 +1,303 @@
+package lang
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// Maximum number of input bits two gates can have for them to be
+	// compared by trying every combination of inputs. Larger gates are
+	// compared with a SAT solver instead.
+	maxEquivInputs = 8
+
+	// Gates that cannot be turned into a circuit, such as gates with
+	// registers or indexes that are not constant, are still compared by
+	// trying every combination of inputs up to this many input bits.
+	maxExhaustiveInputs = 16
+)
+
+// The result of comparing two gates. Gates that are not equivalent come with
+// a counterexample: arguments they disagree on, along with what each of them
+// returned for them.
+type Equivalence struct {
+	Equivalent bool
+	Names      []string
+	Args       []Value
+	Left       Value
+	Right      Value
+
+	a, b string
+}
+
+// Checks that two gates return the same value for every possible input. The
+// gates need to take the same number of arguments, and arguments that either
+// gate uses as a sequence are sequences for both of them.
+func (rt *Runtime) Equiv(a, b string) (Equivalence, error) {
+	ga, gb, errs := rt.comparable(a, b)
+
+	if len(errs) > 0 {
+		return Equivalence{}, &Error{Phase: EvalPhase, Errors: errs}
+	}
+
+	params := commonParams(ga, gb)
+	width := 0
+
+	for _, param := range params {
+		width += param.width()
+	}
+
+	var names []string
+
+	for _, arg := range ga.args {
+		names = append(names, arg.lexeme)
+	}
+
+	if width > maxEquivInputs {
+		eq, blasted, err := rt.equivSat(a, b, ga, gb, params, names)
+
+		if blasted || width > maxExhaustiveInputs {
+			return eq, err
+		}
+	}
+
+	for row := 0; row < 1<<width; row++ {
+		var args []Expr
+		pos := width
+
+		for _, param := range params {
+			pos -= param.width()
+			args = append(args, shapeExpr(param, row, pos))
+		}
+
+		left, right, errs := rt.callBoth(a, b, args)
+
+		if len(errs) > 0 {
+			return Equivalence{}, &Error{Phase: EvalPhase, Errors: errs}
+		}
+
+		if row == 0 {
+			if ls, rs := valueShape(left), valueShape(right); !shapesEqual(ls, rs) {
+				return Equivalence{}, shapeMismatch(a, b, ls, rs)
+			}
+		}
+
+		if !left.val.equals(right.val, rt.env) {
+			return rt.counterexample(a, b, names, args, left, right), nil
+		}
+	}
+
+	return Equivalence{Equivalent: true, Names: names, a: a, b: b}, nil
+}
+
+// Compares gates with too many inputs to try every combination of them. Both
+// gates are turned into a single circuit that is true only when their outputs
+// differ, and a SAT solver looks for inputs that make it true. Reports
+// whether the gates could be turned into a circuit.
+func (rt *Runtime) equivSat(a, b string, ga, gb gate, params []shape, names []string) (Equivalence, bool, error) {
+	c := newCircuit()
+	bl := &blaster{c: c}
+	var inputs []wire
+
+	for i, param := range params {
+		inputs = append(inputs, shapeWire(c, param, names[i]))
+	}
+
+	left, errs := bl.call(a, ga, inputs, 1)
+
+	if len(errs) > 0 {
+		return Equivalence{}, false, &Error{Phase: EvalPhase, Errors: errs}
+	}
+
+	right, errs := bl.call(b, gb, inputs, 1)
+
+	if len(errs) > 0 {
+		return Equivalence{}, false, &Error{Phase: EvalPhase, Errors: errs}
+	}
+
+	if ls, rs := wireShape(left), wireShape(right); !shapesEqual(ls, rs) {
+		return Equivalence{}, true, shapeMismatch(a, b, ls, rs)
+	}
+
+	model, differ := c.solve(c.not(c.equal(left, right)))
+
+	if !differ {
+		return Equivalence{Equivalent: true, Names: names, a: a, b: b}, true, nil
+	}
+
+	var args []Expr
+
+	for _, input := range inputs {
+		args = append(args, wireExpr(input, model))
+	}
+
+	lv, rv, errs := rt.callBoth(a, b, args)
+
+	if len(errs) > 0 {
+		return Equivalence{}, true, &Error{Phase: EvalPhase, Errors: errs}
+	}
+
+	return rt.counterexample(a, b, names, args, lv, rv), true, nil
+}
+
+func (rt *Runtime) counterexample(a, b string, names []string, args []Expr, left, right Value) Equivalence {
+	eq := Equivalence{Names: names, Left: left, Right: right, a: a, b: b}
+
+	for _, arg := range args {
+		val, _ := evaluate(arg, rt.env)
+		v, _ := newValue(val, rt.env)
+		eq.Args = append(eq.Args, v)
+	}
+
+	return eq
+}
+
+func shapeMismatch(a, b string, ls, rs shape) error {
+	return &Error{Phase: EvalPhase, Errors: []error{fmt.Errorf(
+		"`%s` returns %s but `%s` returns %s.", a, describeShape(ls), b, describeShape(rs))}}
+}
+
+// Looks up two gates that are going to be compared, making sure they take the
+// same number of arguments.
+func (rt *Runtime) comparable(a, b string) (gate, gate, []error) {
+	var errs []error
+	ga, okA := rt.env.getGate(a)
+	gb, okB := rt.env.getGate(b)
+
+	if !okA {
+		errs = append(errs, fmt.Errorf("Undefined gate `%s`", a))
+	}
+
+	if !okB {
+		errs = append(errs, fmt.Errorf("Undefined gate `%s`", b))
+	}
+
+	if len(errs) == 0 && len(ga.args) != len(gb.args) {
+		errs = append(errs, fmt.Errorf("`%s` takes %d arguments but `%s` takes %d.",
+			a, len(ga.args), b, len(gb.args)))
+	}
+
+	return ga, gb, errs
+}
+
+// Returns the shape of the arguments of two gates, which is whatever either
+// of them needs.
+func commonParams(a, b gate) []shape {
+	si := newShapeInference()
+	pa, pb := si.params(a), si.params(b)
+	params := make([]shape, len(pa))
+
+	for i := range pa {
+		params[i] = mergeShapes(pa[i], pb[i])
+	}
+
+	return params
+}
+
+func (rt *Runtime) callBoth(a, b string, args []Expr) (Value, Value, []error) {
+	var vals []Value
+
+	for _, name := range []string{a, b} {
+		call := &CallExpr{callee: token{id: identTok, lexeme: name}, args: args}
+		val, errs := evaluate(call, rt.env)
+
+		if len(errs) > 0 {
+			return Value{}, Value{}, errs
+		}
+
+		v, errs := newValue(val, rt.env)
+
+		if len(errs) > 0 {
+			return Value{}, Value{}, errs
+		}
+
+		vals = append(vals, v)
+	}
+
+	return vals[0], vals[1], nil
+}
+
+// Returns an expression with the given shape, made up of the bits of n that
+// start at pos, counting from the least significant bit. The first item of a
+// sequence is its most significant bit.
+func shapeExpr(s shape, n, pos int) Expr {
+	if !s.list {
+		return &LiteralExpr{value: n&(1<<pos) != 0}
+	}
+
+	seq := &SeqExpr{}
+	pos += s.width()
+
+	for _, item := range s.items {
+		pos -= item.width()
+		seq.items = append(seq.items, shapeExpr(item, n, pos))
+	}
+
+	return seq
+}
+
+func valueShape(v Value) shape {
+	if v.Kind() != Sequence {
+		return bit
+	}
+
+	s := shape{list: true}
+
+	for _, item := range v.Items() {
+		s.items = append(s.items, valueShape(item))
+	}
+
+	return s
+}
+
+func shapesEqual(a, b shape) bool {
+	if a.list != b.list || len(a.items) != len(b.items) {
+		return false
+	}
+
+	for i := range a.items {
+		if !shapesEqual(a.items[i], b.items[i]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func describeShape(s shape) string {
+	if !s.list {
+		return "a single bit"
+	}
+
+	for _, item := range s.items {
+		if item.list {
+			return fmt.Sprintf("a nested sequence of %d items", len(s.items))
+		}
+	}
+
+	return fmt.Sprintf("a sequence of %d bits", len(s.items))
+}
+
+// Describes the result of a comparison in a few lines, naming the inputs of
+// the counterexample when there is one.
+func (e Equivalence) Lines() []string {
+	a, b := e.a, e.b
+
+	if e.Equivalent {
+		return []string{fmt.Sprintf("%s and %s are equivalent", a, b)}
+	}
+
+	var inputs []string
+
+	for i, arg := range e.Args {
+		inputs = append(inputs, fmt.Sprintf("%s = %s", e.Names[i], arg))
+	}
+
+	call := strings.Join(e.Names, ", ")
+
+	return []string{
+		fmt.Sprintf("%s and %s are not equivalent, they differ when %s:", a, b, strings.Join(inputs, ", ")),
+		fmt.Sprintf("  %s(%s) = %s", a, call, e.Left),
+		fmt.Sprintf("  %s(%s) = %s", b, call, e.Right),
+	}
+}
@@ -0,0 +1,113 @@
+package lang
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEquiv(t *testing.T) {
+	rt := newTestRuntime(t,
+		"import \"std/adders.bool\"",
+		"gate Mux (a, b, s) = (a ∧ ¬s) ∨ (b ∧ s)",
+		"gate Mux2 (a, b, s) = (s → b) ∧ (¬s → a)",
+		"gate BadMux (a, b, s) = (a ∧ s) ∨ (b ∧ ¬s)",
+		"gate Add4 (x, y) = adders.Add4(y, x)",
+		"gate Or4 (x, y) = [x(0) ∨ y(0), x(1) ∨ y(1), x(2) ∨ y(2), x(3) ∨ y(3)]",
+		"gate Pair (a, b, s) = [a, b]",
+		"gate Wide (x) = x(16)",
+		"gate Add8c (x, y, c) = adders.Add8c(y, x, c)",
+		"gate Id (x) = x",
+		"gate Sel (x, i) = x(8) ∧ x(i)",
+		"gate Sel2 (x, i) = x(i) ∧ x(8)",
+		"gate BadSel (x, i) = x(i) ∨ x(8)",
+	)
+
+	tests := []struct {
+		a, b  string
+		lines []string
+	}{
+		{"Mux", "Mux2", []string{"Mux and Mux2 are equivalent"}},
+		{"Add4", "adders.Add4", []string{"Add4 and adders.Add4 are equivalent"}},
+		{"Mux", "BadMux", []string{
+			"Mux and BadMux are not equivalent, they differ when a = false, b = true, s = false:",
+			"  Mux(a, b, s) = false",
+			"  BadMux(a, b, s) = true",
+		}},
+		{"Add4", "Or4", []string{
+			"Add4 and Or4 are not equivalent, they differ when x = Seq[4]{0, 0, 0, 1}, y = Seq[4]{0, 0, 0, 1}:",
+			"  Add4(x, y) = Seq[4]{0, 0, 1, 0}",
+			"  Or4(x, y) = Seq[4]{0, 0, 0, 1}",
+		}},
+		{"Wide", "Wide", []string{"Wide and Wide are equivalent"}},
+		{"Add8c", "adders.Add8c", []string{"Add8c and adders.Add8c are equivalent"}},
+		{"Sel", "Sel2", []string{"Sel and Sel2 are equivalent"}},
+		{"Sel", "BadSel", []string{
+			"Sel and BadSel are not equivalent, they differ when x = Seq[9]{0, 0, 0, 0, 0, 0, 0, 0, 1}, i = false:",
+			"  Sel(x, i) = false",
+			"  BadSel(x, i) = true",
+		}},
+	}
+
+	for _, test := range tests {
+		eq, err := rt.Equiv(test.a, test.b)
+
+		if err != nil {
+			t.Errorf("Equiv(%q, %q) error = %v", test.a, test.b, err)
+		} else if got := strings.Join(eq.Lines(), "\n"); got != strings.Join(test.lines, "\n") {
+			t.Errorf("Equiv(%q, %q) got:\n%s\nexpected:\n%s", test.a, test.b, got, strings.Join(test.lines, "\n"))
+		}
+	}
+
+	errTests := []struct {
+		a, b string
+		err  string
+	}{
+		{"Mux", "Nope", "Undefined gate `Nope`"},
+		{"Mux", "Id", "`Mux` takes 3 arguments but `Id` takes 1."},
+		{"Mux", "Pair", "`Mux` returns a single bit but `Pair` returns a sequence of 2 bits."},
+	}
+
+	for _, test := range errTests {
+		_, err := rt.Equiv(test.a, test.b)
+
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("Equiv(%q, %q) error = %v, expected %q", test.a, test.b, err, test.err)
+		}
+	}
+}
+
+func TestEquivSat(t *testing.T) {
+	rt := newTestRuntime(t,
+		"import \"std/adders.bool\"",
+		"gate Add8n (x, y, c) = adders.Add8c(x, y, 0)",
+		"gate Wide (x) = x(16)",
+		"gate Reg (x) = reg(x(16))",
+		"gate Loop (x) = Loop(x)",
+	)
+
+	eq, err := rt.Equiv("adders.Add8c", "Add8n")
+
+	if err != nil {
+		t.Fatal(err)
+	} else if eq.Equivalent {
+		t.Fatal("expected adders.Add8c and Add8n to differ")
+	} else if eq.Args[2].String() != "true" || eq.Left.String() == eq.Right.String() {
+		t.Errorf("got counterexample %v", eq.Lines())
+	}
+
+	errTests := []struct {
+		a, b string
+		err  string
+	}{
+		{"Wide", "Reg", "Cannot turn `reg` into a circuit, registers depend on the clock."},
+		{"Wide", "Loop", "Calls to `Loop` are nested too deeply, is it recursive?"},
+	}
+
+	for _, test := range errTests {
+		_, err := rt.Equiv(test.a, test.b)
+
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("Equiv(%q, %q) error = %v, expected %q", test.a, test.b, err, test.err)
+		}
+	}
+}
@@ -0,0 +1,862 @@
+package lang
+
+import (
+	"fmt"
+	"strconv"
+)
+
+type typeId string
+type method func(env environment, args ...value) (value, []error)
+
+type environment struct {
+	bindings map[string]closure
+	methods  map[string]method
+	gates    map[string]gate
+	parent   *environment
+
+	// Every register in the runtime, and the path of gate calls that lead
+	// to this environment, which tells instances of a gate apart.
+	clock *clock
+	path  string
+
+	// Values of the bindings already looked up during a gate call. Nothing
+	// can change while a call is running, so each binding only has to be
+	// evaluated once per call. Nil outside of gate calls.
+	memo map[string]value
+}
+
+// An expression along with the environment it should be evaluated in. Most
+// bindings have no environment and are evaluated in whichever environment
+// they are looked up from, which is what makes them reactive. Gate arguments
+// on the other hand have to be evaluated in the environment of the caller.
+type closure struct {
+	expr Expr
+	env  *environment
+}
+
+type value struct {
+	boolean  *boolean
+	sequence *sequence
+	number   int
+}
+
+type boolean struct {
+	internal bool
+}
+
+type sequence struct {
+	internal []Expr
+}
+
+type binding struct {
+	label token
+	value Expr
+}
+
+// A gate declaration. Local bindings come from the `where` and `and` clauses
+// that follow the gate's body and are only visible inside of the gate.
+type gate struct {
+	label  token
+	args   []token
+	body   Expr
+	locals []binding
+	env    *environment
+}
+
+// A statement made up of a single expression.
+type exprStmt struct {
+	expr Expr
+}
+
+type evaluates interface {
+	eval(env environment) (value, []error)
+}
+
+// Evaluates expressions in a given environment. The result of the last
+// expression visited is stored in val and errs.
+type evaluator struct {
+	env  environment
+	val  value
+	errs []error
+}
+
+const (
+	typeInvalid  typeId = "invalid"
+	typeBoolean  typeId = "boolean"
+	typeSequence typeId = "sequence"
+	typeNumber   typeId = "number"
+)
+
+var (
+	binaryMethods = map[tokenId]string{
+		andTok: "and",
+		eqTok:  "eq",
+		geTok:  "ge",
+		gtTok:  "gt",
+		leTok:  "le",
+		ltTok:  "lt",
+		miTok:  "mi",
+		orTok:  "or",
+		xorTok: "xor",
+	}
+
+	unaryMethods = map[tokenId]string{
+		notTok: "not",
+	}
+)
+
+func (b binding) eval(env environment) (value, []error) {
+	for _, id := range identifiers(b.value, env) {
+		if id.lexeme == b.label.lexeme {
+			return value{}, []error{errorAt(b.label.span,
+				"Detected circular reference in `%s` identifier",
+				b.label.lexeme)}
+		}
+	}
+
+	env.setBinding(b.label.lexeme, b.value)
+	return value{}, nil
+}
+
+func (g *gate) eval(env environment) (value, []error) {
+	scope := newEnvironment(&env)
+	g.env = &scope
+
+	for _, local := range g.locals {
+		if _, errs := local.eval(scope); len(errs) > 0 {
+			return value{}, errs
+		}
+	}
+
+	env.setGate(g.label.lexeme, *g)
+	return value{}, nil
+}
+
+func (s exprStmt) eval(env environment) (value, []error) {
+	return evaluate(s.expr, env)
+}
+
+func evaluate(e Expr, env environment) (value, []error) {
+	ev := &evaluator{env: env}
+	e.Accept(ev)
+	return ev.val, ev.errs
+}
+
+func (ev *evaluator) fail(errs ...error) {
+	ev.val = value{}
+	ev.errs = errs
+}
+
+func (ev *evaluator) VisitBadExpr(e *BadExpr) {
+	ev.fail(errorAt(e.Span(), "Cannot evaluate expression due to error: %s", e.err))
+}
+
+func (ev *evaluator) VisitBinaryExpr(e *BinaryExpr) {
+	lhs, lhsErr := evaluate(e.lhs, ev.env)
+	rhs, rhsErr := evaluate(e.rhs, ev.env)
+
+	if errs := append(lhsErr, rhsErr...); len(errs) > 0 {
+		ev.fail(errs...)
+		return
+	}
+
+	name, ok := binaryMethods[e.op.id]
+
+	if !ok {
+		ev.fail(errorAt(e.op.span, "Unknown binary operator: %s", e.op.lexeme))
+		return
+	}
+
+	fn, ok := ev.env.getMethod(name)
+
+	if !ok {
+		ev.fail(errorAt(e.op.span, "Unknown binary operator: %s", e.op.lexeme))
+		return
+	}
+
+	val, errs := fn(ev.env, lhs, rhs)
+	ev.val, ev.errs = val, errorsAt(e.Span(), errs)
+}
+
+func (ev *evaluator) VisitUnaryExpr(e *UnaryExpr) {
+	val, errs := evaluate(e.rhs, ev.env)
+
+	if len(errs) > 0 {
+		ev.fail(errs...)
+		return
+	}
+
+	name, ok := unaryMethods[e.op.id]
+
+	if !ok {
+		ev.fail(errorAt(e.op.span, "Unknown unary operator: %s", e.op.lexeme))
+		return
+	}
+
+	fn, ok := ev.env.getMethod(name)
+
+	if !ok {
+		ev.fail(errorAt(e.op.span, "Unknown unary operator: %s", e.op.lexeme))
+		return
+	}
+
+	val, errs = fn(ev.env, val)
+	ev.val, ev.errs = val, errorsAt(e.Span(), errs)
+}
+
+func (ev *evaluator) VisitGroupExpr(e *GroupExpr) {
+	ev.val, ev.errs = evaluate(e.inner, ev.env)
+}
+
+func (ev *evaluator) VisitCallExpr(e *CallExpr) {
+	if g, ok := ev.env.getGate(e.callee.lexeme); ok {
+		ev.val, ev.errs = ev.call(g, e)
+	} else if isRegisterCall(e, ev.env) {
+		ev.val, ev.errs = ev.register(e)
+	} else {
+		ev.val, ev.errs = ev.index(e)
+	}
+}
+
+func (ev *evaluator) call(g gate, e *CallExpr) (value, []error) {
+	if len(g.args) != len(e.args) {
+		return value{}, []error{errorAt(e.span, "Arity error, `%s` "+
+			"expects %d arguments but got %d instead.",
+			e.callee.lexeme, len(g.args), len(e.args))}
+	}
+
+	subEnv := callScope(g, e, ev.env)
+	res, errs := evaluate(g.body, subEnv)
+
+	if len(errs) > 0 {
+		return value{}, errs
+	}
+
+	// Sequence items are evaluated lazily, so they have to be evaluated
+	// while the arguments are still in scope.
+	if res.isSequence() {
+		snapshot, errs := res.sequence.freeze(subEnv)
+		return value{sequence: &snapshot}, errs
+	}
+
+	return res, nil
+}
+
+// Returns the environment a call to g from env is evaluated in, with the
+// arguments of the call bound in it. Calls from the same place share their
+// registers.
+func callScope(g gate, e *CallExpr, env environment) environment {
+	scope := newEnvironment(g.env)
+	scope.memo = make(map[string]value)
+	scope.clock = env.clock
+	scope.path = fmt.Sprintf("%s%p/", env.path, e)
+
+	for i, arg := range g.args {
+		scope.setClosure(arg.lexeme, e.args[i], &env)
+	}
+
+	return scope
+}
+
+// Accessing an item in a sequence looks just like a gate call with a single
+// argument, the index of the item.
+func (ev *evaluator) index(e *CallExpr) (value, []error) {
+	name := e.callee.lexeme
+
+	if len(e.args) != 1 {
+		return value{}, []error{errorAt(e.callee.span, "Undefined gate `%s`", name)}
+	}
+
+	seq, errs, set := ev.lookup(name)
+
+	if !set {
+		return value{}, []error{errorAt(e.callee.span, "Undefined gate `%s`", name)}
+	}
+
+	if len(errs) > 0 {
+		return value{}, errs
+	}
+
+	idx, errs := evaluate(e.args[0], ev.env)
+
+	if len(errs) > 0 {
+		return value{}, append(errs, errorAt(e.args[0].Span(),
+			"Invalid operation, expecting a digit when accessing `%s`", name))
+	}
+
+	// Zero and one are scanned as booleans, but they are just as valid as
+	// any other index.
+	idx = numberCast(idx)
+
+	if !seq.isSequence() {
+		return value{}, []error{errorAt(e.callee.span,
+			"Invalid operation, expecting `%s` to be a sequence", name)}
+	} else if !idx.isNumber() {
+		return value{}, []error{errorAt(e.args[0].Span(),
+			"Expecting a digic when accessing `%s` gate.", name)}
+	} else if idx.number >= len(seq.sequence.internal) {
+		return value{}, []error{errorAt(e.args[0].Span(),
+			"Out of bounds error, max is %d and tried to access %d on `%s` sequence.",
+			len(seq.sequence.internal)-1, idx.number, name)}
+	}
+
+	return evaluate(seq.sequence.internal[idx.number], ev.env)
+}
+
+func (ev *evaluator) VisitIdentExpr(e *IdentExpr) {
+	val, errs, set := ev.lookup(e.name.lexeme)
+
+	if !set {
+		ev.fail(errorAt(e.name.span, "Undefined identifier `%s`", e.name.lexeme))
+		return
+	}
+
+	ev.val, ev.errs = val, errs
+}
+
+// Looks up and evaluates a binding. The last return value is false when
+// there is no binding with that name.
+func (ev *evaluator) lookup(name string) (value, []error, bool) {
+	if val, ok := ev.env.memo[name]; ok {
+		return val, nil, true
+	}
+
+	c, set := ev.env.getClosure(name)
+
+	if !set {
+		return value{}, nil, false
+	}
+
+	val, errs := c.eval(ev.env)
+
+	if ev.env.memo != nil && len(errs) == 0 {
+		ev.env.memo[name] = val
+	}
+
+	return val, errs, true
+}
+
+func (ev *evaluator) VisitLiteralExpr(e *LiteralExpr) {
+	ev.val = value{boolean: &boolean{e.value}}
+}
+
+func (ev *evaluator) VisitSeqExpr(e *SeqExpr) {
+	ev.val = value{sequence: &sequence{internal: e.items}}
+}
+
+func (ev *evaluator) VisitNumberExpr(e *NumberExpr) {
+	num, err := strconv.Atoi(e.tok.lexeme)
+
+	if err != nil {
+		ev.fail(errorAt(e.tok.span, "Error converting to number: %v", err))
+		return
+	}
+
+	ev.val = value{number: num}
+}
+
+// Evaluates the closure in its own environment, or in env if it has none.
+func (c closure) eval(env environment) (value, []error) {
+	if c.env == nil {
+		return evaluate(c.expr, env)
+	}
+
+	val, errs := evaluate(c.expr, *c.env)
+
+	// Sequence items are evaluated lazily, so they are evaluated now while
+	// the closure's environment is still the one they are looked up in.
+	if len(errs) == 0 && val.isSequence() {
+		snapshot, errs := val.sequence.freeze(*c.env)
+		return value{sequence: &snapshot}, errs
+	}
+
+	return val, errs
+}
+
+func (e *environment) getClosure(label string) (closure, bool) {
+	val, ok := e.bindings[label]
+
+	if !ok && e.parent != nil {
+		return e.parent.getClosure(label)
+	} else {
+		return val, ok
+	}
+}
+
+func (e *environment) getBinding(label string) (Expr, bool) {
+	c, ok := e.getClosure(label)
+	return c.expr, ok
+}
+
+func (e *environment) getMethod(label string) (method, bool) {
+	val, ok := e.methods[label]
+
+	if !ok && e.parent != nil {
+		return e.parent.getMethod(label)
+	} else {
+		return val, ok
+	}
+}
+
+func (e *environment) setBinding(label string, expr Expr) *environment {
+	e.bindings[label] = closure{expr: expr}
+	return e
+}
+
+func (e *environment) setClosure(label string, expr Expr, env *environment) *environment {
+	e.bindings[label] = closure{expr: expr, env: env}
+	return e
+}
+
+func (e *environment) getGate(label string) (gate, bool) {
+	val, ok := e.gates[label]
+
+	if !ok && e.parent != nil {
+		return e.parent.getGate(label)
+	} else {
+		return val, ok
+	}
+}
+
+func (e *environment) setGate(label string, g gate) *environment {
+	e.gates[label] = g
+	return e
+}
+
+func newEnvironment(parent *environment) environment {
+	env := environment{
+		bindings: make(map[string]closure),
+		methods:  getBuiltins(),
+		gates:    make(map[string]gate),
+		parent:   parent,
+	}
+
+	if parent != nil {
+		env.clock = parent.clock
+		env.path = parent.path
+	} else {
+		env.clock = newClock()
+	}
+
+	return env
+}
+
+// Collects every identifier in an expression, including the ones referenced
+// by the bindings it uses.
+type identifierCollector struct {
+	env    environment
+	tokens []token
+}
+
+func identifiers(e Expr, env environment) []token {
+	c := &identifierCollector{env: env}
+	e.Accept(c)
+	return c.tokens
+}
+
+func (c *identifierCollector) VisitBadExpr(e *BadExpr) {}
+
+func (c *identifierCollector) VisitBinaryExpr(e *BinaryExpr) {
+	e.lhs.Accept(c)
+	e.rhs.Accept(c)
+}
+
+func (c *identifierCollector) VisitUnaryExpr(e *UnaryExpr) {
+	e.rhs.Accept(c)
+}
+
+func (c *identifierCollector) VisitGroupExpr(e *GroupExpr) {
+	e.inner.Accept(c)
+}
+
+func (c *identifierCollector) VisitCallExpr(e *CallExpr) {
+	// Reading a register does not depend on its input.
+	if isRegisterCall(e, c.env) {
+		return
+	}
+
+	c.visitName(e.callee)
+
+	for _, arg := range e.args {
+		arg.Accept(c)
+	}
+}
+
+func (c *identifierCollector) VisitIdentExpr(e *IdentExpr) {
+	c.visitName(e.name)
+}
+
+func (c *identifierCollector) VisitLiteralExpr(e *LiteralExpr) {}
+
+func (c *identifierCollector) VisitSeqExpr(e *SeqExpr) {
+	for _, item := range e.items {
+		item.Accept(c)
+	}
+}
+
+func (c *identifierCollector) VisitNumberExpr(e *NumberExpr) {}
+
+func (c *identifierCollector) visitName(name token) {
+	c.tokens = append(c.tokens, name)
+
+	if expr, ok := c.env.getBinding(name.lexeme); ok {
+		expr.Accept(c)
+	}
+}
+
+// Evaluates every item in a sequence, returning a new sequence made up of
+// only literals.
+func (s sequence) freeze(env environment) (sequence, []error) {
+	var errs []error
+	snapshot := sequence{}
+
+	for _, expr := range s.internal {
+		val, err := evaluate(expr, env)
+		errs = append(errs, err...)
+
+		if val.isBoolean() {
+			snapshot.internal = append(snapshot.internal, &LiteralExpr{
+				value: val.boolean.internal,
+			})
+		} else if val.isSequence() {
+			inner, err := val.sequence.freeze(env)
+			errs = append(errs, err...)
+			snapshot.internal = append(snapshot.internal, &SeqExpr{
+				items: inner.internal,
+			})
+		} else if val.isNumber() {
+			snapshot.internal = append(snapshot.internal, &NumberExpr{
+				tok: token{
+					id:     numTok,
+					lexeme: strconv.FormatInt(int64(val.number), 10),
+				},
+			})
+		}
+	}
+
+	return snapshot, errs
+}
+
+func (v value) isBoolean() bool {
+	return v.boolean != nil
+}
+
+func (v value) isSequence() bool {
+	return v.sequence != nil
+}
+
+func (v value) isNumber() bool {
+	return !v.isBoolean() && !v.isSequence()
+}
+
+func (v value) getTypeId() typeId {
+	switch {
+	case v.isBoolean():
+		return typeBoolean
+
+	case v.isSequence():
+		return typeSequence
+
+	case v.isNumber():
+		return typeNumber
+
+	default:
+		return typeInvalid
+	}
+}
+
+func (v value) equals(other value, env environment) bool {
+	if v.getTypeId() != other.getTypeId() {
+		return false
+	}
+
+	switch {
+	case v.isBoolean():
+		return v.boolean.internal == other.boolean.internal
+
+	case v.isNumber():
+		return v.number == other.number
+
+	case v.isSequence():
+		if len(v.sequence.internal) != len(other.sequence.internal) {
+			return false
+		}
+
+		for i := range v.sequence.internal {
+			e1, errs := evaluate(v.sequence.internal[i], env)
+
+			if len(errs) > 0 {
+				return false
+			}
+
+			e2, errs := evaluate(other.sequence.internal[i], env)
+
+			if len(errs) > 0 {
+				return false
+			}
+
+			if e1.equals(e2, env) == false {
+				return false
+			}
+		}
+
+		return true
+	}
+
+	return false
+}
+
+func getBuiltins() map[string]method {
+	return map[string]method{
+		"and": andBuiltin,
+		"eq":  eqBuiltin,
+		"ge":  geBuiltin,
+		"gt":  gtBuiltin,
+		"le":  leBuiltin,
+		"lt":  ltBuiltin,
+		"mi":  miBuiltin,
+		"not": notBuiltin,
+		"or":  orBuiltin,
+		"xor": xorBuiltin,
+	}
+}
+
+func andBuiltin(env environment, args ...value) (value, []error) {
+	if err := strictArityCheck("and", 2, args...); err != nil {
+		return value{}, []error{err}
+	}
+
+	if err := strictTypeCheck("and", 1, args[0], typeBoolean); err != nil {
+		return value{}, []error{err}
+	}
+
+	if err := strictTypeCheck("and", 2, args[1], typeBoolean); err != nil {
+		return value{}, []error{err}
+	}
+
+	return value{
+		boolean: &boolean{
+			args[0].boolean.internal && args[1].boolean.internal,
+		},
+	}, nil
+}
+
+func orBuiltin(env environment, args ...value) (value, []error) {
+	if err := strictArityCheck("or", 2, args...); err != nil {
+		return value{}, []error{err}
+	}
+
+	if err := strictTypeCheck("or", 1, args[0], typeBoolean); err != nil {
+		return value{}, []error{err}
+	}
+
+	if err := strictTypeCheck("or", 2, args[1], typeBoolean); err != nil {
+		return value{}, []error{err}
+	}
+
+	return value{
+		boolean: &boolean{
+			args[0].boolean.internal || args[1].boolean.internal,
+		},
+	}, nil
+}
+
+func miBuiltin(env environment, args ...value) (value, []error) {
+	if err := strictArityCheck("mi", 2, args...); err != nil {
+		return value{}, []error{err}
+	}
+
+	if err := strictTypeCheck("mi", 1, args[0], typeBoolean); err != nil {
+		return value{}, []error{err}
+	}
+
+	if err := strictTypeCheck("mi", 2, args[1], typeBoolean); err != nil {
+		return value{}, []error{err}
+	}
+
+	return value{
+		boolean: &boolean{
+			!args[0].boolean.internal || args[1].boolean.internal,
+		},
+	}, nil
+}
+
+func xorBuiltin(env environment, args ...value) (value, []error) {
+	if err := strictArityCheck("xor", 2, args...); err != nil {
+		return value{}, []error{err}
+	}
+
+	if err := strictTypeCheck("xor", 1, args[0], typeBoolean); err != nil {
+		return value{}, []error{err}
+	}
+
+	if err := strictTypeCheck("xor", 2, args[1], typeBoolean); err != nil {
+		return value{}, []error{err}
+	}
+
+	return value{
+		boolean: &boolean{
+			(args[0].boolean.internal || args[1].boolean.internal) &&
+				!(args[0].boolean.internal && args[1].boolean.internal),
+		},
+	}, nil
+}
+
+func eqBuiltin(env environment, args ...value) (value, []error) {
+	if err := strictArityCheck("eq", 2, args...); err != nil {
+		return value{}, []error{err}
+	}
+
+	if args[0].getTypeId() != args[1].getTypeId() {
+		return value{}, []error{fmt.Errorf("Type error, `eq` expects both arguments to be of the same type but got `%s` and `%s` instead.",
+			args[0].getTypeId(), args[1].getTypeId())}
+	}
+
+	return value{
+		boolean: &boolean{
+			args[0].equals(args[1], env),
+		},
+	}, nil
+}
+
+func geBuiltin(env environment, args ...value) (value, []error) {
+	if err := strictArityCheck("ge", 2, args...); err != nil {
+		return value{}, []error{err}
+	}
+
+	if err := strictOneOfTypeCheck("ge", 1, args[0], typeBoolean, typeNumber); err != nil {
+		return value{}, []error{err}
+	}
+
+	if err := strictOneOfTypeCheck("ge", 2, args[1], typeBoolean, typeNumber); err != nil {
+		return value{}, []error{err}
+	}
+
+	return value{
+		boolean: &boolean{
+			numberCast(args[0]).number >= numberCast(args[1]).number,
+		},
+	}, nil
+}
+
+func gtBuiltin(env environment, args ...value) (value, []error) {
+	if err := strictArityCheck("gt", 2, args...); err != nil {
+		return value{}, []error{err}
+	}
+
+	if err := strictOneOfTypeCheck("gt", 1, args[0], typeBoolean, typeNumber); err != nil {
+		return value{}, []error{err}
+	}
+
+	if err := strictOneOfTypeCheck("gt", 2, args[1], typeBoolean, typeNumber); err != nil {
+		return value{}, []error{err}
+	}
+
+	return value{
+		boolean: &boolean{
+			numberCast(args[0]).number > numberCast(args[1]).number,
+		},
+	}, nil
+}
+
+func leBuiltin(env environment, args ...value) (value, []error) {
+	if err := strictArityCheck("le", 2, args...); err != nil {
+		return value{}, []error{err}
+	}
+
+	if err := strictOneOfTypeCheck("le", 1, args[0], typeBoolean, typeNumber); err != nil {
+		return value{}, []error{err}
+	}
+
+	if err := strictOneOfTypeCheck("le", 2, args[1], typeBoolean, typeNumber); err != nil {
+		return value{}, []error{err}
+	}
+
+	return value{
+		boolean: &boolean{
+			numberCast(args[0]).number <= numberCast(args[1]).number,
+		},
+	}, nil
+}
+
+func ltBuiltin(env environment, args ...value) (value, []error) {
+	if err := strictArityCheck("lt", 2, args...); err != nil {
+		return value{}, []error{err}
+	}
+
+	if err := strictOneOfTypeCheck("lt", 1, args[0], typeBoolean, typeNumber); err != nil {
+		return value{}, []error{err}
+	}
+
+	if err := strictOneOfTypeCheck("lt", 2, args[1], typeBoolean, typeNumber); err != nil {
+		return value{}, []error{err}
+	}
+
+	return value{
+		boolean: &boolean{
+			numberCast(args[0]).number < numberCast(args[1]).number,
+		},
+	}, nil
+}
+
+func notBuiltin(env environment, args ...value) (value, []error) {
+	if err := strictArityCheck("not", 1, args...); err != nil {
+		return value{}, []error{err}
+	}
+
+	if err := strictTypeCheck("not", 1, args[0], typeBoolean); err != nil {
+		return value{}, []error{err}
+	}
+
+	return value{boolean: &boolean{!args[0].boolean.internal}}, []error{}
+}
+
+func strictArityCheck(label string, expected int, args ...value) error {
+	if expected != len(args) {
+		return fmt.Errorf("Arity error, `%s` expects %d arguments but got %d instead.",
+			label, expected, len(args))
+	}
+
+	return nil
+}
+
+func strictTypeCheck(label string, pos int, got value, expId typeId) error {
+	gotId := got.getTypeId()
+
+	if expId != gotId {
+		return fmt.Errorf("Type error, `%s` expects a `%s` in position %d but got `%s` instead.",
+			label, expId, pos, gotId)
+	}
+
+	return nil
+}
+
+func strictOneOfTypeCheck(label string, pos int, got value, expIds ...typeId) error {
+	gotId := got.getTypeId()
+
+	for _, t := range expIds {
+		if t == gotId {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("Type error, `%s` expects one of %v in position %d but got `%s` instead.",
+		label, expIds, pos, gotId)
+}
+
+// Converts booleans into number and keeps everything else the same.
+func numberCast(v value) value {
+	if v.getTypeId() == typeBoolean {
+		num := 0
+
+		if v.boolean.internal {
+			num = 1
+		}
+
+		return value{
+			number: num,
+		}
+	}
+
+	return v
+}
@@ -0,0 +1,177 @@
+package lang
+
+import (
+	"strings"
+	"testing"
+)
+
+func boolValue(b bool) value {
+	return value{boolean: &boolean{b}}
+}
+
+func numValue(n int) value {
+	return value{number: n}
+}
+
+func seqValue(items ...bool) value {
+	seq := &sequence{}
+
+	for _, item := range items {
+		seq.internal = append(seq.internal, &LiteralExpr{value: item})
+	}
+
+	return value{sequence: seq}
+}
+
+func TestBuiltins(t *testing.T) {
+	T, F := boolValue(true), boolValue(false)
+
+	tests := []struct {
+		name string
+		args []value
+		exp  value
+		err  string
+	}{
+		{"and", []value{F, F}, F, ""},
+		{"and", []value{F, T}, F, ""},
+		{"and", []value{T, F}, F, ""},
+		{"and", []value{T, T}, T, ""},
+		{"and", []value{T}, value{}, "Arity error, `and` expects 2 arguments but got 1 instead."},
+		{"and", []value{T, numValue(2)}, value{}, "Type error, `and` expects a `boolean` in position 2 but got `number` instead."},
+
+		{"or", []value{F, F}, F, ""},
+		{"or", []value{F, T}, T, ""},
+		{"or", []value{T, F}, T, ""},
+		{"or", []value{T, T}, T, ""},
+		{"or", []value{seqValue(), T}, value{}, "Type error, `or` expects a `boolean` in position 1 but got `sequence` instead."},
+
+		{"xor", []value{F, F}, F, ""},
+		{"xor", []value{F, T}, T, ""},
+		{"xor", []value{T, F}, T, ""},
+		{"xor", []value{T, T}, F, ""},
+		{"xor", []value{T, T, T}, value{}, "Arity error, `xor` expects 2 arguments but got 3 instead."},
+
+		{"mi", []value{F, F}, T, ""},
+		{"mi", []value{F, T}, T, ""},
+		{"mi", []value{T, F}, F, ""},
+		{"mi", []value{T, T}, T, ""},
+		{"mi", []value{numValue(0), T}, value{}, "Type error, `mi` expects a `boolean` in position 1 but got `number` instead."},
+
+		{"not", []value{F}, T, ""},
+		{"not", []value{T}, F, ""},
+		{"not", []value{T, T}, value{}, "Arity error, `not` expects 1 arguments but got 2 instead."},
+		{"not", []value{numValue(1)}, value{}, "Type error, `not` expects a `boolean` in position 1 but got `number` instead."},
+
+		{"eq", []value{F, F}, T, ""},
+		{"eq", []value{F, T}, F, ""},
+		{"eq", []value{numValue(3), numValue(3)}, T, ""},
+		{"eq", []value{numValue(3), numValue(4)}, F, ""},
+		{"eq", []value{seqValue(true, false), seqValue(true, false)}, T, ""},
+		{"eq", []value{seqValue(true, false), seqValue(true, true)}, F, ""},
+		{"eq", []value{seqValue(true), seqValue(true, true)}, F, ""},
+		{"eq", []value{T, numValue(1)}, value{}, "Type error, `eq` expects both arguments to be of the same type but got `boolean` and `number` instead."},
+
+		{"gt", []value{numValue(2), numValue(1)}, T, ""},
+		{"gt", []value{numValue(1), numValue(1)}, F, ""},
+		{"gt", []value{T, F}, T, ""},
+		{"gt", []value{T, numValue(0)}, T, ""},
+		{"gt", []value{seqValue(), T}, value{}, "Type error, `gt` expects one of [boolean number] in position 1 but got `sequence` instead."},
+
+		{"ge", []value{numValue(1), numValue(1)}, T, ""},
+		{"ge", []value{numValue(0), numValue(1)}, F, ""},
+		{"ge", []value{F, T}, F, ""},
+
+		{"lt", []value{numValue(1), numValue(2)}, T, ""},
+		{"lt", []value{numValue(1), numValue(1)}, F, ""},
+		{"lt", []value{F, T}, T, ""},
+
+		{"le", []value{numValue(1), numValue(1)}, T, ""},
+		{"le", []value{numValue(2), numValue(1)}, F, ""},
+		{"le", []value{T, seqValue()}, value{}, "Type error, `le` expects one of [boolean number] in position 2 but got `sequence` instead."},
+	}
+
+	env := newEnvironment(nil)
+	tested := make(map[string]bool)
+
+	for _, test := range tests {
+		tested[test.name] = true
+		fn, ok := env.getMethod(test.name)
+
+		if !ok {
+			t.Errorf("missing builtin `%s`", test.name)
+			continue
+		}
+
+		got, errs := fn(env, test.args...)
+
+		if test.err != "" {
+			if len(errs) != 1 || errs[0].Error() != test.err {
+				t.Errorf("%s%v: expected error %q but got %v", test.name,
+					test.args, test.err, errs)
+			}
+		} else if len(errs) > 0 {
+			t.Errorf("%s%v: unexpected errors %v", test.name, test.args, errs)
+		} else if !got.equals(test.exp, env) {
+			t.Errorf("%s%v = %s, expected %s", test.name, test.args,
+				print(got, env), print(test.exp, env))
+		}
+	}
+
+	for name := range getBuiltins() {
+		if !tested[name] {
+			t.Errorf("builtin `%s` is not tested", name)
+		}
+	}
+}
+
+func TestEval(t *testing.T) {
+	tests := []struct {
+		stmts []string
+		exp   string
+		err   string
+	}{
+		{[]string{"1 ∧ 0"}, "false", ""},
+		{[]string{"1 ∨ 0 ∧ 0"}, "true", ""},
+		{[]string{"(1 ∨ 0) ∧ 0"}, "false", ""},
+		{[]string{"0 → 0 → 0"}, "true", ""},
+		{[]string{"¬1 ⊕ 1"}, "true", ""},
+		{[]string{"3 > 2 = 1"}, "true", ""},
+		{[]string{"[1, 0 ∨ 1, 12]"}, "Seq[3]{1, 1, 12}", ""},
+		{[]string{"x is y", "y is 1", "x"}, "true", ""},
+		{[]string{"x is y", "x"}, "", "Undefined identifier `y`"},
+		{[]string{"x is ¬x"}, "", "Detected circular reference in `x` identifier"},
+		{[]string{"x is [1, 0, 1]", "x(2) ∧ x(1)"}, "false", ""},
+		{[]string{"x is [1, 0, 1]", "x(3)"}, "", "Out of bounds error, max is 2 and tried to access 3 on `x` sequence."},
+		{[]string{"x is 1", "x(0)"}, "", "Invalid operation, expecting `x` to be a sequence"},
+		{[]string{"gate F (a) = ¬a", "F(1)"}, "false", ""},
+		{[]string{"gate F (a) = ¬a", "F(1, 0)"}, "", "Arity error, `F` expects 1 arguments but got 2 instead."},
+		{[]string{"a is 1", "gate F (a) = ¬a", "F(a)"}, "false", ""},
+		{[]string{"gate F (a) = ¬a", "gate G (a) = F(F(F(a)))", "G(0)"}, "true", ""},
+		{[]string{"gate F (a, b) = [b, a]", "F(1, 0)"}, "Seq[2]{0, 1}", ""},
+		{[]string{"gate F (a) = b", "where b is ¬a", "F(0)"}, "true", ""},
+		{[]string{"gate F (a) = b where b is ¬a", "F(0)"}, "true", ""},
+		{[]string{"gate F (a) = b", "where b is ¬a", "b"}, "", "Undefined identifier `b`"},
+		{[]string{"a is 1", "where b is ¬a"}, "", "Binding continuation used outside of gate declaration."},
+		{[]string{"F(1)"}, "", "Undefined gate `F`"},
+	}
+
+	for _, test := range tests {
+		var errs []error
+
+		ret, err := NewRuntime().Eval(strings.Join(test.stmts, "\n"))
+
+		if err != nil {
+			errs = err.(*Error).Errors
+		}
+
+		if test.err != "" {
+			if len(errs) == 0 || errs[0].Error() != test.err {
+				t.Errorf("%q: expected error %q but got %v", test.stmts, test.err, errs)
+			}
+		} else if len(errs) > 0 {
+			t.Errorf("%q: unexpected errors %v", test.stmts, errs)
+		} else if got := ret.String(); got != test.exp {
+			t.Errorf("%q = %s, expected %s", test.stmts, got, test.exp)
+		}
+	}
+}
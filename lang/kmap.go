@@ -0,0 +1,166 @@
+package lang
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	minKmapInputs = 2
+	maxKmapInputs = 6
+)
+
+// Names given to the groups of a Karnaugh map, in the order the simplifier
+// returns them. A function of 6 inputs needs at most 32 groups.
+const kmapGroups = "ABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+// Returns the lines of a Karnaugh map of src, which is the name of a gate or
+// an expression. Rows and columns are in Gray code order, so neighboring
+// cells differ in a single input, and every cell is marked with the groups
+// of the smallest sum of products that cover it. Gates that return sequences
+// get a map for every item.
+func (rt *Runtime) Kmap(src string) ([]string, error) {
+	c, w, errs := circuitFor(src, &rt.env)
+
+	if len(errs) > 0 {
+		return nil, &Error{Phase: EvalPhase, Errors: errs}
+	} else if n := len(c.names); n < minKmapInputs || n > maxKmapInputs {
+		return nil, &Error{Phase: EvalPhase, Errors: []error{fmt.Errorf(
+			"Karnaugh maps can only be drawn for %d to %d inputs but got %d.",
+			minKmapInputs, maxKmapInputs, n)}}
+	}
+
+	fns := c.truthFuncs(w)
+	outputs := w.bits()
+	var lines []string
+
+	for i, out := range outputs {
+		if len(outputs) > 1 {
+			lines = append(lines, fmt.Sprintf("item %d:", i))
+		}
+
+		lines = append(lines, kmap(c.names, fns[out], minimize(fns[out]))...)
+	}
+
+	return lines, nil
+}
+
+// Draws the map of a function and its groups. The first half of the inputs
+// select the row and the rest select the column.
+func kmap(names []string, f truthFunc, groups []cube) []string {
+	rowBits := f.inputs / 2
+	colBits := f.inputs - rowBits
+	gray := func(i, bits int) string {
+		return fmt.Sprintf("%0*b", bits, i^(i>>1))
+	}
+
+	header := []string{axisLabel(names[:rowBits]) + "\\" + axisLabel(names[rowBits:])}
+
+	for col := 0; col < 1<<colBits; col++ {
+		header = append(header, gray(col, colBits))
+	}
+
+	var rows [][]string
+
+	for r := 0; r < 1<<rowBits; r++ {
+		cells := []string{gray(r, rowBits)}
+
+		for col := 0; col < 1<<colBits; col++ {
+			row := uint32((r^(r>>1))<<colBits | (col ^ (col >> 1)))
+			cell := "0"
+
+			if f.on[row] {
+				cell = "1"
+			} else if f.dc[row] {
+				cell = "-"
+			}
+
+			var marks []byte
+
+			for i, g := range groups {
+				if g.covers(row) {
+					marks = append(marks, kmapGroups[i])
+				}
+			}
+
+			if len(marks) > 0 {
+				cell += " " + string(marks)
+			}
+
+			cells = append(cells, cell)
+		}
+
+		rows = append(rows, cells)
+	}
+
+	// Every column other than the first has the same width, which keeps the
+	// map square.
+	width := 0
+
+	for _, cells := range append([][]string{header}, rows...) {
+		for _, cell := range cells[1:] {
+			if l := utf8.RuneCountInString(cell); l > width {
+				width = l
+			}
+		}
+	}
+
+	widths := []int{utf8.RuneCountInString(header[0])}
+
+	for _, cells := range rows {
+		if l := utf8.RuneCountInString(cells[0]); l > widths[0] {
+			widths[0] = l
+		}
+	}
+
+	for range header[1:] {
+		widths = append(widths, width)
+	}
+
+	rule := func(left, mid, right string) string {
+		parts := make([]string, len(widths))
+
+		for i, w := range widths {
+			parts[i] = strings.Repeat("─", w+2)
+		}
+
+		return left + strings.Join(parts, mid) + right
+	}
+
+	format := func(cells []string) string {
+		padded := make([]string, len(cells))
+
+		for i, cell := range cells {
+			padded[i] = " " + cell + strings.Repeat(" ", widths[i]-utf8.RuneCountInString(cell)) + " "
+		}
+
+		return "│" + strings.Join(padded, "│") + "│"
+	}
+
+	lines := []string{rule("┌", "┬", "┐"), format(header)}
+
+	for _, cells := range rows {
+		lines = append(lines, rule("├", "┼", "┤"), format(cells))
+	}
+
+	lines = append(lines, rule("└", "┴", "┘"))
+
+	for i, g := range groups {
+		lines = append(lines, fmt.Sprintf("%c = %s", kmapGroups[i], sop{names: names, cubes: []cube{g}}))
+	}
+
+	return lines
+}
+
+// Names the inputs along one side of a map, without separators when every
+// name is a single letter.
+func axisLabel(names []string) string {
+	for _, name := range names {
+		if utf8.RuneCountInString(name) > 1 {
+			return strings.Join(names, ",")
+		}
+	}
+
+	return strings.Join(names, "")
+}
@@ -0,0 +1,105 @@
+package lang
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestKmap(t *testing.T) {
+	rt := newTestRuntime(t,
+		"import \"std/adders.bool\"",
+		"gate Both (a, b) = a ∧ b",
+	)
+
+	tests := []struct {
+		src string
+		exp []string
+	}{
+		{"Both", []string{
+			"┌─────┬─────┬─────┐",
+			"│ a\\b │ 0   │ 1   │",
+			"├─────┼─────┼─────┤",
+			"│ 0   │ 0   │ 0   │",
+			"├─────┼─────┼─────┤",
+			"│ 1   │ 0   │ 1 A │",
+			"└─────┴─────┴─────┘",
+			"A = a ∧ b",
+		}},
+		{"a ∧ b ∨ c", []string{
+			"┌──────┬──────┬──────┬──────┬──────┐",
+			"│ a\\bc │ 00   │ 01   │ 11   │ 10   │",
+			"├──────┼──────┼──────┼──────┼──────┤",
+			"│ 0    │ 0    │ 1 B  │ 1 B  │ 0    │",
+			"├──────┼──────┼──────┼──────┼──────┤",
+			"│ 1    │ 0    │ 1 B  │ 1 AB │ 1 A  │",
+			"└──────┴──────┴──────┴──────┴──────┘",
+			"A = a ∧ b",
+			"B = c",
+		}},
+		{"a ∧ ¬a ∧ b", []string{
+			"┌─────┬───┬───┐",
+			"│ a\\b │ 0 │ 1 │",
+			"├─────┼───┼───┤",
+			"│ 0   │ 0 │ 0 │",
+			"├─────┼───┼───┤",
+			"│ 1   │ 0 │ 0 │",
+			"└─────┴───┴───┘",
+		}},
+		{"x(0) ∨ y", []string{
+			"┌────────┬──────┬──────┐",
+			"│ x(0)\\y │ 0    │ 1    │",
+			"├────────┼──────┼──────┤",
+			"│ 0      │ 0    │ 1 B  │",
+			"├────────┼──────┼──────┤",
+			"│ 1      │ 1 A  │ 1 AB │",
+			"└────────┴──────┴──────┘",
+			"A = x(0)",
+			"B = y",
+		}},
+	}
+
+	for _, test := range tests {
+		lines, err := rt.Kmap(test.src)
+
+		if err != nil {
+			t.Errorf("Kmap(%q) error = %v", test.src, err)
+		} else if got, exp := strings.Join(lines, "\n"), strings.Join(test.exp, "\n"); got != exp {
+			t.Errorf("Kmap(%q) =\n%s\nexpected\n%s", test.src, got, exp)
+		}
+	}
+
+	// Rows and columns are in Gray code order, so the only 1 of a product of
+	// every input is where its row and column labels meet.
+	lines, err := rt.Kmap("a ∧ ¬b ∧ c ∧ ¬d ∧ e")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if exp := "│ 10     │ 0   │ 0   │ 0   │ 0   │ 0   │ 0   │ 1 A │ 0   │"; lines[9] != exp {
+		t.Errorf("Kmap row 10 = %s, expected %s", lines[9], exp)
+	}
+
+	if lines, err := rt.Kmap("adders.FullAdder"); err != nil {
+		t.Error(err)
+	} else if lines[0] != "item 0:" || lines[12] != "item 1:" {
+		t.Errorf("expected a map for every item, got\n%s", strings.Join(lines, "\n"))
+	}
+
+	errTests := []struct {
+		src string
+		err string
+	}{
+		{"a", "Karnaugh maps can only be drawn for 2 to 6 inputs but got 1."},
+		{"a ∧ b ∧ c ∧ d ∧ e ∧ f ∧ g", "Karnaugh maps can only be drawn for 2 to 6 inputs but got 7."},
+		{"reg(a) ∧ b", "Cannot turn `reg` into a circuit, registers depend on the clock."},
+	}
+
+	for _, test := range errTests {
+		_, err := rt.Kmap(test.src)
+
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("Kmap(%q) error = %v, expected %q", test.src, err, test.err)
+		}
+	}
+}
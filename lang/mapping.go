@@ -0,0 +1,311 @@
+package lang
+
+import (
+	"fmt"
+	"strings"
+)
+
+// A gate that every other gate can be built from.
+type Universal string
+
+const (
+	// Not and: true unless both inputs are true, written ¬(x ∧ y).
+	NAND Universal = "nand"
+	// Not or: true when both inputs are false, written ¬(x ∨ y).
+	NOR Universal = "nor"
+)
+
+// Universals lists every valid Universal.
+var Universals = []Universal{NAND, NOR}
+
+// A gate rewritten to only use one kind of universal gate, along with the
+// number of universal gates it takes and the most of them any input goes
+// through to reach an output.
+type Mapping struct {
+	Decl  string
+	Gates int
+	Depth int
+}
+
+// A cell in a circuit of universal gates. Leaves are the inputs and the
+// constants of the circuit the gates are mapped from, and every other cell
+// is a gate with inputs a and b, which are the same for an inverter.
+type mapCell struct {
+	leaf bool
+	node int
+	a, b int
+}
+
+// Maps the nodes of a circuit onto universal gates. Gates are shared like
+// the nodes of a circuit, and inverting an inverter returns its input.
+type mapper struct {
+	c      *circuit
+	nor    bool
+	cells  []mapCell
+	hash   map[mapCell]int
+	mapped map[int]int
+}
+
+// Rewrites a gate into one that only uses the universal gate to. The new gate
+// is declared with the name as, unless it is empty. Gates it calls are inlined, and
+// ⊕, →, ≡, and the comparison operators are replaced by universal gates like
+// every other operator.
+func (rt *Runtime) MapTo(name string, to Universal, as string) (Mapping, error) {
+	if to != NAND && to != NOR {
+		return Mapping{}, &Error{Phase: EvalPhase, Errors: []error{fmt.Errorf(
+			"Unknown universal gate `%s`, expecting nand or nor.", to)}}
+	}
+
+	g, ok := rt.env.getGate(name)
+
+	if !ok {
+		return Mapping{}, &Error{Phase: EvalPhase, Errors: []error{
+			fmt.Errorf("Undefined gate `%s`", name)}}
+	}
+
+	label := as
+
+	if label == "" {
+		label = name[strings.LastIndexByte(name, '.')+1:] +
+			strings.ToUpper(string(to[:1])) + string(to[1:])
+	}
+
+	labelTok, err := identifierToken(label)
+
+	if err != nil {
+		return Mapping{}, &Error{Phase: EvalPhase, Errors: []error{err}}
+	}
+
+	c := newCircuit()
+	mapped := &gate{label: labelTok, args: g.args}
+	taken := make(map[string]bool)
+	leaves := make(map[int]Expr)
+
+	for _, arg := range g.args {
+		taken[arg.lexeme] = true
+	}
+
+	var inputs []wire
+
+	for i, param := range newShapeInference().params(g) {
+		w := shapeWire(c, param, g.args[i].lexeme)
+		inputs = append(inputs, w)
+		mapped.locals = append(mapped.locals, argLeaves(w, g.args[i], leaves, taken)...)
+	}
+
+	w, errs := (&blaster{c: c}).call(name, g, inputs, 1)
+
+	if len(errs) > 0 {
+		return Mapping{}, &Error{Phase: EvalPhase, Errors: errs}
+	}
+
+	m := &mapper{c: c, nor: to == NOR, hash: make(map[mapCell]int), mapped: make(map[int]int)}
+	var outputs []int
+
+	for _, node := range w.bits() {
+		outputs = append(outputs, m.node(node))
+	}
+
+	gates, depth, body, locals := m.exprs(w, outputs, leaves, taken)
+	mapped.body = body
+	mapped.locals = append(mapped.locals, locals...)
+
+	if as != "" {
+		if _, errs := mapped.eval(rt.env); len(errs) > 0 {
+			return Mapping{}, &Error{Phase: EvalPhase, Errors: errs}
+		}
+	}
+
+	return Mapping{Decl: mapped.String(), Gates: gates, Depth: depth}, nil
+}
+
+// Finds the expression that reads every input of an argument. Items of a
+// sequence are read with a call, and items that are sequences themselves
+// are bound to a local first, which is returned.
+func argLeaves(w wire, arg token, leaves map[int]Expr, taken map[string]bool) []binding {
+	if !w.list {
+		leaves[w.node] = &IdentExpr{name: arg}
+		return nil
+	}
+
+	var locals []binding
+
+	for i, item := range w.items {
+		read := &CallExpr{callee: arg, args: []Expr{netNumber(i, arg.span)}, span: arg.span}
+
+		if !item.list {
+			leaves[item.node] = read
+			continue
+		}
+
+		tok := token{id: identTok, lexeme: netName(fmt.Sprintf("%s_%d", arg.lexeme, i), taken), span: arg.span}
+		locals = append(locals, binding{label: tok, value: read})
+		locals = append(locals, argLeaves(item, tok, leaves, taken)...)
+	}
+
+	return locals
+}
+
+func (m *mapper) leaf(node int) int {
+	return m.add(mapCell{leaf: true, node: node})
+}
+
+func (m *mapper) add(s mapCell) int {
+	if id, ok := m.hash[s]; ok {
+		return id
+	}
+
+	m.cells = append(m.cells, s)
+	m.hash[s] = len(m.cells) - 1
+	return len(m.cells) - 1
+}
+
+func (m *mapper) gate(a, b int) int {
+	if id, ok := m.hash[mapCell{a: b, b: a}]; ok {
+		return id
+	}
+
+	return m.add(mapCell{a: a, b: b})
+}
+
+func (m *mapper) inv(a int) int {
+	if s := m.cells[a]; !s.leaf && s.a == s.b {
+		return s.a
+	}
+
+	return m.gate(a, a)
+}
+
+// Returns the cell for a node of the circuit. For NAND gates, a ∧ b is an
+// inverted gate and a ∨ b is a gate of inverted inputs, and the other way
+// around for NOR gates. a ⊕ b takes four gates, which compute a ⊕ b with
+// NAND gates and ¬(a ⊕ b) with NOR gates.
+func (m *mapper) node(id int) int {
+	if s, ok := m.mapped[id]; ok {
+		return s
+	}
+
+	var s int
+
+	switch n := m.c.nodes[id]; n.op {
+	case constNode, inputNode:
+		s = m.leaf(id)
+	case notNode:
+		s = m.inv(m.node(n.a))
+	case andNode, orNode:
+		a, b := m.node(n.a), m.node(n.b)
+
+		if (n.op == andNode) != m.nor {
+			s = m.inv(m.gate(a, b))
+		} else {
+			s = m.gate(m.inv(a), m.inv(b))
+		}
+	case xorNode:
+		a, b := m.node(n.a), m.node(n.b)
+		t := m.gate(a, b)
+		s = m.gate(m.gate(a, t), m.gate(b, t))
+
+		if m.nor {
+			s = m.inv(s)
+		}
+	}
+
+	m.mapped[id] = s
+	return s
+}
+
+// Writes the gates that the outputs depend on as expressions. Gates that are
+// used more than once are bound to locals, in the order they are computed,
+// and the rest are written where they are used. Returns the number of gates,
+// the depth of the circuit, the expression for w, and the locals.
+func (m *mapper) exprs(w wire, outputs []int, leaves map[int]Expr, taken map[string]bool) (int, int, Expr, []binding) {
+	uses := make(map[int]int)
+	depths := make(map[int]int)
+	var order []int
+	var visit func(s int)
+
+	visit = func(s int) {
+		uses[s]++
+
+		if uses[s] > 1 || m.cells[s].leaf {
+			return
+		}
+
+		// The input of an inverter is used twice, so it is bound to a local
+		// instead of being written twice.
+		sig := m.cells[s]
+		visit(sig.a)
+		visit(sig.b)
+
+		depths[s] = 1 + depths[sig.a]
+
+		if d := 1 + depths[sig.b]; d > depths[s] {
+			depths[s] = d
+		}
+
+		order = append(order, s)
+	}
+
+	depth := 0
+
+	for _, out := range outputs {
+		visit(out)
+
+		if depths[out] > depth {
+			depth = depths[out]
+		}
+	}
+
+	op, r := andTok, andRn
+
+	if m.nor {
+		op, r = orTok, orRn
+	}
+
+	exprs := make(map[int]Expr)
+	var locals []binding
+
+	expr := func(s int) Expr {
+		if sig := m.cells[s]; sig.leaf && sig.node < 2 {
+			return netLiteral(sig.node == 1, span{})
+		} else if sig.leaf {
+			return leaves[sig.node]
+		}
+
+		return exprs[s]
+	}
+
+	for _, s := range order {
+		sig := m.cells[s]
+		e := netNot(netJoin(op, r, []Expr{expr(sig.a), expr(sig.b)}, span{}), span{})
+
+		if uses[s] == 1 {
+			exprs[s] = e
+			continue
+		}
+
+		tok := token{id: identTok, lexeme: netName(fmt.Sprintf("n%d", len(locals)+1), taken)}
+		locals = append(locals, binding{label: tok, value: e})
+		exprs[s] = &IdentExpr{name: tok}
+	}
+
+	var build func(w wire) Expr
+	next := 0
+
+	build = func(w wire) Expr {
+		if !w.list {
+			next++
+			return expr(outputs[next-1])
+		}
+
+		var items []Expr
+
+		for _, item := range w.items {
+			items = append(items, build(item))
+		}
+
+		return &SeqExpr{items: items}
+	}
+
+	return len(order), depth, build(w), locals
+}
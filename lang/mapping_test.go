@@ -0,0 +1,100 @@
+package lang
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMapTo(t *testing.T) {
+	rt := newTestRuntime(t,
+		"import \"std/adders.bool\"",
+		"import \"std/comparators.bool\"",
+		"gate Xor (a, b) = a ⊕ b",
+		"gate Ops (a, b, c) = (a → b) ≡ (b ∨ ¬c)",
+		"gate Nested (x, y) = Xor(x(0), y) ∧ x(1)",
+		"gate Always (a) = a ∨ ¬a",
+	)
+
+	tests := []struct {
+		gate  string
+		to    Universal
+		gates int
+		depth int
+	}{
+		{"Xor", NAND, 4, 3},
+		{"Xor", NOR, 5, 4},
+		{"Ops", NAND, 0, 0},
+		{"Ops", NOR, 0, 0},
+		{"Nested", NAND, 0, 0},
+		{"Nested", NOR, 0, 0},
+		{"adders.FullAdder", NAND, 9, 6},
+		{"adders.Add4", NAND, 0, 0},
+		{"comparators.Lt4", NOR, 0, 0},
+		{"Always", NAND, 0, 0},
+	}
+
+	for _, test := range tests {
+		as := strings.ReplaceAll(test.gate, ".", "") + "As" + string(test.to)
+		m, err := rt.MapTo(test.gate, test.to, as)
+
+		if err != nil {
+			t.Errorf("MapTo(%q, %s) error = %v", test.gate, test.to, err)
+			continue
+		}
+
+		if test.gates != 0 && (m.Gates != test.gates || m.Depth != test.depth) {
+			t.Errorf("MapTo(%q, %s) has %d gates and depth %d, expected %d and %d",
+				test.gate, test.to, m.Gates, m.Depth, test.gates, test.depth)
+		}
+
+		// Every operator is a single universal gate.
+		other := "∨"
+
+		if test.to == NOR {
+			other = "∧"
+		}
+
+		for _, op := range []string{other, "⊕", "→", "≡", "Xor("} {
+			if strings.Contains(m.Decl, op) {
+				t.Errorf("MapTo(%q, %s) = %s, which uses %s", test.gate, test.to, m.Decl, op)
+			}
+		}
+
+		if eq, err := rt.Equiv(test.gate, as); err != nil {
+			t.Errorf("Equiv(%q, %q) error = %v", test.gate, as, err)
+		} else if !eq.Equivalent {
+			t.Errorf("MapTo(%q, %s) = %s, which is not equivalent", test.gate, test.to, m.Decl)
+		}
+	}
+
+	m, err := rt.MapTo("Xor", NAND, "")
+
+	if err != nil {
+		t.Fatal(err)
+	} else if exp := "gate XorNand (a, b) = ¬(¬(a ∧ n1) ∧ ¬(b ∧ n1)) where n1 is ¬(a ∧ b)"; m.Decl != exp {
+		t.Errorf("MapTo(Xor) = %s, expected %s", m.Decl, exp)
+	}
+
+	if _, err := rt.Eval("XorNand(1, 0)"); err == nil {
+		t.Error("expected MapTo to only declare the gate when it is given a name")
+	}
+
+	errTests := []struct {
+		gate string
+		to   Universal
+		as   string
+		err  string
+	}{
+		{"Nope", NAND, "", "Undefined gate `Nope`"},
+		{"Xor", "xor", "", "Unknown universal gate `xor`, expecting nand or nor."},
+		{"Xor", NAND, "2x", "Invalid identifier `2x`"},
+	}
+
+	for _, test := range errTests {
+		_, err := rt.MapTo(test.gate, test.to, test.as)
+
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("MapTo(%q, %s, %q) error = %v, expected %q", test.gate, test.to, test.as, err, test.err)
+		}
+	}
+}
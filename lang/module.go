@@ -0,0 +1,131 @@
+package lang
+
+import (
+	"embed"
+	"io/fs"
+	"io/ioutil"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// The standard library is written in Bool and bundled with every runtime.
+// Its modules are imported like any other, e.g. `import "std/adders.bool"`.
+//
+//go:embed std/*.bool
+var stdlib embed.FS
+
+// An import statement, which loads a module and makes its bindings and gates
+// available under the module's name.
+type importStmt struct {
+	path token
+}
+
+// Loading a module requires a runtime, so imports are handled by the runtime
+// instead of being evaluated like other statements.
+func (i importStmt) eval(env environment) (value, []error) {
+	return value{}, []error{errorAt(i.path.span,
+		"Imports are not supported in this context.")}
+}
+
+// Returns the imported path without the quotes around it.
+func (i importStmt) file() string {
+	return strings.Trim(i.path.lexeme, `"`)
+}
+
+// Returns the name the module's bindings and gates are prefixed with, which
+// is its file name without the extension, so `import "lib/alu.bool"` is used
+// as `alu.Add8(x, y)`.
+func (i importStmt) namespace() string {
+	base := path.Base(filepath.ToSlash(i.file()))
+	return strings.TrimSuffix(base, path.Ext(base))
+}
+
+// Evaluates a module in its own environment and copies everything it
+// declares into the runtime's global scope under the module's namespace.
+// Names the module itself imported are not copied. Netlists are imported
+// the same way, with a gate for every circuit in them.
+func (rt *Runtime) load(i importStmt) []error {
+	name, src, ok := rt.resolve(i.file(), i.path.span.src)
+
+	if !ok {
+		return []error{errorAt(i.path.span, "Cannot find module `%s`.", i.file())}
+	} else if rt.loading[name] {
+		return []error{errorAt(i.path.span, "Import cycle detected, `%s` is "+
+			"already being imported.", i.file())}
+	}
+
+	rt.loading[name] = true
+	defer delete(rt.loading, name)
+
+	mod := &Runtime{
+		Settings: rt.Settings,
+		env:      newEnvironment(nil),
+		loading:  rt.loading,
+	}
+
+	mod.env.clock = rt.env.clock
+
+	if _, isNetlist := netlistFormats[strings.ToLower(path.Ext(name))]; isNetlist {
+		if _, err := mod.Netlist(name, string(src)); err != nil {
+			return err.(*Error).Errors
+		}
+	} else {
+		for _, res := range mod.run(newSource(name, string(src))) {
+			if err, ok := res.Err.(*Error); ok {
+				return err.Errors
+			}
+		}
+	}
+
+	ns := i.namespace()
+
+	for label, c := range mod.env.bindings {
+		if strings.Contains(label, ".") {
+			continue
+		} else if c.env == nil {
+			c.env = &mod.env
+		}
+
+		rt.env.setClosure(ns+"."+label, c.expr, c.env)
+	}
+
+	for label, g := range mod.env.gates {
+		if !strings.Contains(label, ".") {
+			rt.env.setGate(ns+"."+label, g)
+		}
+	}
+
+	return nil
+}
+
+// Finds and reads a module. Relative paths are looked up in the directory of
+// the source doing the import, then in every directory in the runtime's
+// path, and finally in the standard library.
+func (rt *Runtime) resolve(file string, from *source) (string, []byte, bool) {
+	var dirs []string
+
+	if filepath.IsAbs(file) {
+		dirs = []string{""}
+	} else {
+		if from != nil {
+			dirs = append(dirs, filepath.Dir(from.name))
+		}
+
+		dirs = append(dirs, rt.Settings.Path...)
+	}
+
+	for _, dir := range dirs {
+		name := filepath.Join(dir, file)
+
+		if src, err := ioutil.ReadFile(name); err == nil {
+			return name, src, true
+		}
+	}
+
+	if src, err := fs.ReadFile(stdlib, filepath.ToSlash(file)); err == nil {
+		return filepath.ToSlash(file), src, true
+	}
+
+	return "", nil, false
+}
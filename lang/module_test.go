@@ -0,0 +1,131 @@
+package lang
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeModules(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+
+	for name, src := range files {
+		name = filepath.Join(dir, name)
+
+		if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := ioutil.WriteFile(name, []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return dir
+}
+
+func TestImport(t *testing.T) {
+	dir := writeModules(t, map[string]string{
+		"main.bool":      "import \"lib/alu.bool\"\nalu.Inc(alu.one)\n",
+		"lib/alu.bool":   "import \"bits.bool\"\none is 1\ngate Inc (a) = bits.Not(a)\n",
+		"lib/bits.bool":  "gate Not (a) = ¬a\n",
+		"path/misc.bool": "gate Id (a) = a\n",
+		"cycle/a.bool":   "import \"b.bool\"\n",
+		"cycle/b.bool":   "import \"a.bool\"\n",
+		"broken.bool":    "x is\n",
+	})
+
+	var out strings.Builder
+	rt := NewRuntime()
+	rt.Settings.Path = []string{filepath.Join(dir, "path")}
+	name := filepath.Join(dir, "main.bool")
+	src, _ := ioutil.ReadFile(name)
+
+	if err := rt.Run(name, string(src), &out); err != nil {
+		t.Fatal(err)
+	}
+
+	if out.String() != "false\n" {
+		t.Errorf("got %q, expected %q", out.String(), "false\n")
+	}
+
+	if _, ok := rt.env.getGate("alu.bits.Not"); ok {
+		t.Error("names imported by a module should not be exported by it")
+	}
+
+	tests := []struct {
+		src string
+		exp string
+		err string
+	}{
+		{"import \"misc.bool\"\nmisc.Id(1)", "true", ""},
+		{"import \"std/mux.bool\"\nmux.Mux(1, 0, 1)", "false", ""},
+		{"import \"nope.bool\"", "", "Cannot find module `nope.bool`."},
+		{"import \"cycle/a.bool\"", "", "Import cycle detected, `a.bool` is already being imported."},
+		{"import \"broken.bool\"", "", "Unexpected end of line."},
+		{"import alu", "", "Expecting a module path but found ID(alu) instead."},
+	}
+
+	for _, test := range tests {
+		out.Reset()
+		err := rt.Run(filepath.Join(dir, "test.bool"), test.src, &out)
+		got := strings.TrimSpace(out.String())
+
+		if test.err == "" && err != nil {
+			t.Errorf("run(%q) returned errors: %v", test.src, err)
+		} else if test.err != "" && (err == nil || !strings.Contains(err.Error(), test.err)) {
+			t.Errorf("run(%q) error = %v, expected %q", test.src, err, test.err)
+		} else if got != test.exp {
+			t.Errorf("run(%q) = %q, expected %q", test.src, got, test.exp)
+		}
+	}
+}
+
+func TestStdlib(t *testing.T) {
+	rt := newTestRuntime(t,
+		"import \"std/adders.bool\"",
+		"import \"std/mux.bool\"",
+		"import \"std/decoders.bool\"",
+		"import \"std/comparators.bool\"",
+	)
+
+	tests := []struct {
+		src string
+		exp string
+	}{
+		{"adders.HalfAdder(1, 1)", "Seq[2]{0, 1}"},
+		{"adders.FullAdder(1, 1, 1)", "Seq[2]{1, 1}"},
+		{"adders.Add4([0, 1, 1, 1], [0, 0, 1, 1])", "Seq[4]{1, 0, 1, 0}"},
+		{"adders.Add4c([1, 1, 1, 1], [0, 0, 0, 1], 0)", "Seq[5]{0, 0, 0, 0, 1}"},
+		{"adders.Add8([0, 0, 0, 0, 0, 0, 1, 1], [0, 0, 0, 1, 1, 0, 0, 1])", "Seq[8]{0, 0, 0, 1, 1, 1, 0, 0}"},
+		{"adders.Add8c([1, 1, 1, 1, 1, 1, 1, 1], [0, 0, 0, 0, 0, 0, 0, 1], 0)", "Seq[9]{0, 0, 0, 0, 0, 0, 0, 0, 1}"},
+		{"mux.Mux(0, 1, 1)", "true"},
+		{"mux.Mux4(0, 0, 1, 0, 1, 0)", "true"},
+		{"mux.Demux(1, 0)", "Seq[2]{1, 0}"},
+		{"mux.Demux4(1, 1, 0)", "Seq[4]{0, 0, 1, 0}"},
+		{"decoders.Decoder2(0, 1)", "Seq[4]{0, 1, 0, 0}"},
+		{"decoders.Decoder3(1, 0, 1)", "Seq[8]{0, 0, 0, 0, 0, 1, 0, 0}"},
+		{"decoders.Encoder4(0, 0, 1, 0)", "Seq[2]{1, 0}"},
+		{"comparators.Eq(1, 1)", "true"},
+		{"comparators.Gt(0, 1)", "false"},
+		{"comparators.Lt(0, 1)", "true"},
+		{"comparators.Eq4([0, 1, 1, 0], [0, 1, 1, 0])", "true"},
+		{"comparators.Gt4([0, 1, 1, 0], [0, 1, 0, 1])", "true"},
+		{"comparators.Lt4([0, 1, 1, 0], [0, 1, 0, 1])", "false"},
+		{"comparators.Eq8([0, 1, 1, 0, 0, 1, 1, 0], [0, 1, 1, 0, 0, 1, 1, 1])", "false"},
+		{"comparators.Gt8([1, 0, 0, 0, 0, 0, 0, 0], [0, 1, 1, 1, 1, 1, 1, 1])", "true"},
+		{"comparators.Lt8([0, 1, 1, 0, 0, 1, 1, 0], [0, 1, 1, 0, 0, 1, 1, 1])", "true"},
+	}
+
+	for _, test := range tests {
+		val, err := rt.Eval(test.src)
+
+		if err != nil {
+			t.Errorf("eval(%q) returned errors: %v", test.src, err)
+		} else if val.String() != test.exp {
+			t.Errorf("eval(%q) = %s, expected %s", test.src, val, test.exp)
+		}
+	}
+}
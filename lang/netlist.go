@@ -0,0 +1,387 @@
+package lang
+
+import (
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Netlist formats that can be imported, by file extension.
+var netlistFormats = map[string]func(*source) ([]*netlist, []error){
+	".blif": parseBLIF,
+	".v":    parseStructural,
+}
+
+// A circuit read from a netlist file, which becomes a gate. Nets are the
+// wires in the circuit and are referred to by the names they have in the
+// file. Every net is either an input or is driven by exactly one expression.
+type netlist struct {
+	name      netWord
+	inputs    []netPort
+	outputs   []netPort
+	drivers   []netDriver
+	driven    map[string]bool
+	instances []netInstance
+	refs      []*IdentExpr
+	selects   []*CallExpr
+	calls     []*CallExpr
+}
+
+// A word in a netlist along with where it is in the source.
+type netWord struct {
+	text string
+	span span
+}
+
+// An input or output of a netlist, which is a single net or a vector of
+// them. The bits of a vector are listed from the most significant one.
+type netPort struct {
+	name   netWord
+	bits   []string
+	vector bool
+}
+
+type netDriver struct {
+	net  netWord
+	expr Expr
+}
+
+// An instance of another circuit in the same file, with the nets connected
+// to each of its ports.
+type netInstance struct {
+	model netWord
+	conns map[string]netWord
+	order []string
+}
+
+func newNetlist(name netWord) *netlist {
+	return &netlist{name: name, driven: make(map[string]bool)}
+}
+
+// Returns an expression that reads a net. Nets are renamed once the whole
+// netlist is read, since a net can be used before it is declared.
+func (n *netlist) ref(w netWord) Expr {
+	id := &IdentExpr{name: token{id: identTok, lexeme: w.text, span: w.span}}
+	n.refs = append(n.refs, id)
+	return id
+}
+
+func (n *netlist) drive(w netWord, e Expr) error {
+	if n.driven[w.text] {
+		return errorAt(w.span, "Net `%s` is driven more than once.", w.text)
+	}
+
+	n.driven[w.text] = true
+	n.drivers = append(n.drivers, netDriver{net: w, expr: e})
+	return nil
+}
+
+// Connects every instance to the circuit it is an instance of. A call to the
+// instance's gate drives a net of its own, and the nets connected to its
+// outputs are driven by the items of that call's value.
+func (n *netlist) connect(models map[string]*netlist) []error {
+	var errs []error
+
+	for i, inst := range n.instances {
+		sub, ok := models[inst.model.text]
+
+		if !ok {
+			errs = append(errs, errorAt(inst.model.span, "Undefined model `%s`.", inst.model.text))
+			continue
+		}
+
+		ports := make(map[string]bool)
+		var args []Expr
+
+		for _, port := range sub.inputs {
+			ports[port.name.text] = true
+			actual, ok := inst.conns[port.name.text]
+
+			if !ok {
+				errs = append(errs, errorAt(inst.model.span, "Input `%s` of `%s` "+
+					"is not connected.", port.name.text, inst.model.text))
+				continue
+			}
+
+			args = append(args, n.ref(actual))
+		}
+
+		for _, port := range sub.outputs {
+			ports[port.name.text] = true
+		}
+
+		for _, formal := range inst.order {
+			if !ports[formal] {
+				errs = append(errs, errorAt(inst.conns[formal].span, "`%s` has no "+
+					"port named `%s`.", inst.model.text, formal))
+			}
+		}
+
+		if len(errs) > 0 {
+			continue
+		}
+
+		// Names with a # in them can't come from a netlist, since that's
+		// where comments start.
+		net := netWord{text: fmt.Sprintf("%s#%d", inst.model.text, i), span: inst.model.span}
+		call := &CallExpr{
+			callee: token{id: identTok, lexeme: inst.model.text, span: inst.model.span},
+			args:   args,
+			span:   inst.model.span,
+		}
+
+		n.calls = append(n.calls, call)
+		n.drive(net, call)
+
+		for j, port := range sub.outputs {
+			actual, ok := inst.conns[port.name.text]
+
+			if !ok {
+				continue
+			}
+
+			var out Expr = n.ref(netWord{text: net.text, span: actual.span})
+
+			if len(sub.outputs) > 1 {
+				sel := &CallExpr{
+					callee: token{id: identTok, lexeme: net.text, span: actual.span},
+					args:   []Expr{netNumber(j, actual.span)},
+					span:   actual.span,
+				}
+
+				n.selects = append(n.selects, sel)
+				out = sel
+			}
+
+			if err := n.drive(actual, out); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+
+	return errs
+}
+
+// Builds the gate for a netlist. Inputs become arguments, every other net
+// becomes a local binding, and the outputs make up the body, as a sequence
+// when there is more than one of them. Bits of input vectors are bound to
+// locals of their own so the rest of the circuit can treat every net the same.
+func (n *netlist) gate(gates map[string]string) (*gate, []error) {
+	taken := make(map[string]bool)
+	names := make(map[string]string)
+	g := &gate{label: token{id: identTok, lexeme: gates[n.name.text], span: n.name.span}}
+	var errs []error
+
+	for _, port := range n.inputs {
+		arg := token{id: identTok, lexeme: netName(port.name.text, taken), span: port.name.span}
+		g.args = append(g.args, arg)
+
+		if !port.vector {
+			names[port.bits[0]] = arg.lexeme
+			continue
+		}
+
+		for i, bit := range port.bits {
+			names[bit] = netName(bit, taken)
+			g.locals = append(g.locals, binding{
+				label: token{id: identTok, lexeme: names[bit], span: port.name.span},
+				value: &CallExpr{
+					callee: arg,
+					args:   []Expr{netNumber(i, port.name.span)},
+					span:   port.name.span,
+				},
+			})
+		}
+	}
+
+	for _, d := range n.drivers {
+		if _, isInput := names[d.net.text]; isInput {
+			errs = append(errs, errorAt(d.net.span, "Input `%s` cannot be driven.", d.net.text))
+			continue
+		}
+
+		names[d.net.text] = netName(d.net.text, taken)
+		g.locals = append(g.locals, binding{
+			label: token{id: identTok, lexeme: names[d.net.text], span: d.net.span},
+			value: d.expr,
+		})
+	}
+
+	for _, ref := range n.refs {
+		name, ok := names[ref.name.lexeme]
+
+		if !ok {
+			errs = append(errs, errorAt(ref.name.span, "Undriven net `%s`.", ref.name.lexeme))
+			continue
+		}
+
+		ref.name.lexeme = name
+	}
+
+	for _, sel := range n.selects {
+		sel.callee.lexeme = names[sel.callee.lexeme]
+	}
+
+	for _, call := range n.calls {
+		call.callee.lexeme = gates[call.callee.lexeme]
+	}
+
+	var outs []Expr
+
+	for _, port := range n.outputs {
+		var bits []Expr
+
+		for _, bit := range port.bits {
+			name, ok := names[bit]
+
+			if !ok {
+				errs = append(errs, errorAt(port.name.span, "Output `%s` is never driven.", bit))
+				continue
+			}
+
+			bits = append(bits, &IdentExpr{name: token{id: identTok, lexeme: name, span: port.name.span}})
+		}
+
+		if port.vector {
+			outs = append(outs, &SeqExpr{items: bits, span: port.name.span})
+		} else if len(bits) == 1 {
+			outs = append(outs, bits[0])
+		}
+	}
+
+	if len(n.outputs) == 0 {
+		errs = append(errs, errorAt(n.name.span, "`%s` has no outputs.", n.name.text))
+	}
+
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	if len(outs) == 1 {
+		g.body = outs[0]
+	} else {
+		g.body = &SeqExpr{items: outs, span: n.name.span}
+	}
+
+	return g, nil
+}
+
+// Returns a Bool identifier for a name in a netlist, which has to be unique
+// within taken. Characters that can't be in an identifier are replaced with
+// underscores, so bit `3` of vector `x` is `x_3`.
+func netName(raw string, taken map[string]bool) string {
+	name := strings.Trim(strings.Join(strings.FieldsFunc(raw, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_'
+	}), "_"), "_")
+
+	if name == "" || unicode.IsDigit([]rune(name)[0]) {
+		name = "n" + name
+	}
+
+	if stringIsKeyword(name) || stringIsOp(name) || stringIsBoolean(name) || name == string(orAsciiRn) {
+		name += "_"
+	}
+
+	unique := name
+
+	for i := 2; taken[unique]; i++ {
+		unique = fmt.Sprintf("%s_%d", name, i)
+	}
+
+	taken[unique] = true
+	return unique
+}
+
+func netNumber(n int, sp span) Expr {
+	return &NumberExpr{tok: token{id: numTok, lexeme: strconv.Itoa(n), span: sp}}
+}
+
+func netLiteral(value bool, sp span) Expr {
+	if value {
+		return &LiteralExpr{tok: token{id: trueTok, lexeme: "1", span: sp}, value: true}
+	}
+
+	return &LiteralExpr{tok: token{id: falseTok, lexeme: "0", span: sp}, value: false}
+}
+
+func netNot(e Expr, sp span) Expr {
+	return &UnaryExpr{op: token{id: notTok, lexeme: string(notRn), span: sp}, rhs: e}
+}
+
+// Joins expressions with a binary operator, grouping them from the left.
+func netJoin(id tokenId, r rune, exprs []Expr, sp span) Expr {
+	e := exprs[0]
+
+	for _, rhs := range exprs[1:] {
+		e = &BinaryExpr{lhs: e, op: token{id: id, lexeme: string(r), span: sp}, rhs: rhs}
+	}
+
+	return e
+}
+
+// Declares every gate in a netlist in the global scope and returns their
+// names. The format is picked by the extension of the netlist's name: `.blif`
+// for BLIF, or `.v` for structural Verilog. Gates in the same netlist can
+// call each other.
+func (rt *Runtime) Netlist(name, src string) ([]string, error) {
+	parse, ok := netlistFormats[strings.ToLower(path.Ext(name))]
+
+	if !ok {
+		return nil, &Error{Phase: ParsePhase, Errors: []error{fmt.Errorf(
+			"Unknown netlist format `%s`, expecting a .blif or .v file.", path.Ext(name))}}
+	}
+
+	nets, errs := parse(newSource(name, src))
+
+	if len(errs) > 0 {
+		return nil, &Error{Phase: ParsePhase, Errors: errs}
+	}
+
+	taken := make(map[string]bool)
+	gates := make(map[string]string)
+	models := make(map[string]*netlist)
+
+	for _, n := range nets {
+		if _, ok := models[n.name.text]; ok {
+			errs = append(errs, errorAt(n.name.span, "`%s` is declared more than once.", n.name.text))
+			continue
+		}
+
+		models[n.name.text] = n
+		gates[n.name.text] = netName(n.name.text, taken)
+	}
+
+	for _, n := range nets {
+		errs = append(errs, n.connect(models)...)
+	}
+
+	if len(errs) > 0 {
+		return nil, &Error{Phase: ParsePhase, Errors: errs}
+	}
+
+	var declared []*gate
+
+	for _, n := range nets {
+		g, gerrs := n.gate(gates)
+		errs = append(errs, gerrs...)
+		declared = append(declared, g)
+	}
+
+	if len(errs) > 0 {
+		return nil, &Error{Phase: ParsePhase, Errors: errs}
+	}
+
+	var names []string
+
+	for _, g := range declared {
+		if _, errs := g.eval(rt.env); len(errs) > 0 {
+			return nil, &Error{Phase: EvalPhase, Errors: errs}
+		}
+
+		names = append(names, g.label.lexeme)
+	}
+
+	return names, nil
+}
@@ -0,0 +1,205 @@
+package lang
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testBLIF = `# a full adder and a two bit adder built out of it
+.model FullAdder
+.inputs a b cin
+.outputs sum cout
+.names a b t
+10 1
+01 1
+.names t cin sum
+10 1
+01 1
+.names a b cin cout
+11- 1
+1-1 1
+-11 1
+.end
+
+.model Add2
+.inputs x1 x0 y1 y0
+.outputs s1 s0 c
+.names zero
+.subckt FullAdder a=x0 b=y0 cin=zero sum=s0 cout=c0
+.subckt FullAdder a=x1 b=y1 \
+  cin=c0 sum=s1 cout=c
+.end
+
+.model Nor
+.inputs a b
+.outputs y
+.names a b y
+00 1
+.end
+
+.model Nand
+.inputs a b
+.outputs y
+.names a b y
+11 0
+.end
+
+.model Toggle
+.inputs en
+.outputs q
+.names q en d
+10 1
+01 1
+.latch d q re clk 0
+.end
+`
+
+const testStructural = `// a multiplexer and a comparator
+module Mux2 (input [1:0] d, input s, output y);
+  wire t0, t1;
+  assign t0 = d[0] & ~s, t1 = d[1] & s;
+  or g (y, t0, t1);
+endmodule
+
+/* ports declared in the body */
+module Cmp (a, b, y);
+  input a, b;
+  output [1:0] y;
+  assign y[1] = a ~^ b;
+  assign y[0] = a & !b | 1'b0;
+endmodule
+
+module Prims (input a, b, output [0:2] y);
+  nand (y[0], a, b);
+  xnor (y[1], a, b);
+  not (y[2], a);
+endmodule
+`
+
+func TestNetlist(t *testing.T) {
+	rt := NewRuntime()
+	names, err := rt.Netlist("adders.blif", testBLIF)
+
+	if err != nil {
+		t.Fatal(err)
+	} else if strings.Join(names, " ") != "FullAdder Add2 Nor Nand Toggle" {
+		t.Errorf("got gates %v", names)
+	}
+
+	names, err = rt.Netlist("mux.v", testStructural)
+
+	if err != nil {
+		t.Fatal(err)
+	} else if strings.Join(names, " ") != "Mux2 Cmp Prims" {
+		t.Errorf("got gates %v", names)
+	}
+
+	tests := []struct {
+		src string
+		exp string
+	}{
+		{"FullAdder(1, 0, 1)", "Seq[2]{0, 1}"},
+		{"FullAdder(1, 0, 0)", "Seq[2]{1, 0}"},
+		{"Add2(1, 0, 1, 1)", "Seq[3]{0, 1, 1}"},
+		{"Add2(0, 1, 0, 1)", "Seq[3]{1, 0, 0}"},
+		{"Nor(0, 0)", "true"},
+		{"Nor(0, 1)", "false"},
+		{"Nand(1, 1)", "false"},
+		{"Nand(1, 0)", "true"},
+		{"Toggle(1)", "false"},
+		{"Mux2([1, 0], 0)", "false"},
+		{"Mux2([1, 0], 1)", "true"},
+		{"Cmp(1, 1)", "Seq[2]{1, 0}"},
+		{"Cmp(1, 0)", "Seq[2]{0, 1}"},
+		{"Prims(1, 0)", "Seq[3]{1, 0, 0}"},
+	}
+
+	for _, test := range tests {
+		val, err := rt.Eval(test.src)
+
+		if err != nil {
+			t.Errorf("eval(%q) returned errors: %v", test.src, err)
+		} else if val.String() != test.exp {
+			t.Errorf("eval(%q) = %s, expected %s", test.src, val, test.exp)
+		}
+	}
+}
+
+func TestNetlistErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		err  string
+	}{
+		{"x.txt", "", "Unknown netlist format `.txt`, expecting a .blif or .v file."},
+		{"x.blif", ".inputs a", "Expecting `.model` before `.inputs`."},
+		{"x.blif", ".model m\n.gate nand2 A=a B=b O=y", "Unsupported BLIF construct `.gate`."},
+		{"x.blif", ".model m\n.inputs a\n.outputs y\n.names a y\n11 1", "Expecting 1 input values but found 2."},
+		{"x.blif", ".model m\n.inputs a\n.outputs y\n.names a y\n1 1\n0 0", "Rows for `y` must all have the same output value."},
+		{"x.blif", ".model m\n.inputs a\n.outputs y\n.names a y\n2 1", "Invalid input value `2`, expecting 0, 1, or -."},
+		{"x.blif", ".model m\n.inputs a\n.outputs y\n.names b y\n1 1", "Undriven net `b`."},
+		{"x.blif", ".model m\n.inputs a\n.outputs y z\n.names a y\n1 1", "Output `z` is never driven."},
+		{"x.blif", ".model m\n.inputs a\n.outputs y\n.names a y\n1 1\n.names a y\n0 1", "Net `y` is driven more than once."},
+		{"x.blif", ".model m\n.inputs a\n.outputs y\n.subckt n a=a y=y", "Undefined model `n`."},
+		{"x.blif", ".model m\n.inputs a\n.outputs y\n.subckt m b=a y=y", "`m` has no port named `b`."},
+		{"x.v", "module m (input a, output y);\n  assign y = a + a;\nendmodule", "Expecting `;` but found `+` instead."},
+		{"x.v", "module m (input [1:0] a, output y);\n  assign y = a;\nendmodule", "Vector `a` is used without selecting a bit."},
+		{"x.v", "module m (input [1:0] a, output y);\n  assign y = a[2];\nendmodule", "Bit 2 is out of range for `a`."},
+		{"x.v", "module m (input a, output [1:0] y);\n  assign y = a;\nendmodule", "Cannot assign all of vector `y` at once, assign each bit instead."},
+		{"x.v", "module m (input a, output y);\n  always @(a) y = a;\nendmodule", "Unsupported Verilog construct `always`."},
+		{"x.v", "module m (input a, output y);\n  assign y = 2'b10;\nendmodule", "Only single bit constants are supported but found `2'b10`."},
+		{"x.v", "module m (a, y);\n  input a;\n  assign y = a;\nendmodule", "Port `y` is not declared as an input or an output."},
+		{"x.v", "module m (input a, output y);\n  assign y = a;\n", "Expecting `endmodule` but found the end of the file instead."},
+	}
+
+	for _, test := range tests {
+		_, err := NewRuntime().Netlist(test.name, test.src)
+
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("Netlist(%q, %q) error = %v, expected %q", test.name, test.src, err, test.err)
+		}
+	}
+}
+
+func TestNetlistImport(t *testing.T) {
+	dir := writeModules(t, map[string]string{
+		"adders.blif": testBLIF,
+		"mux.v":       testStructural,
+		"main.bool":   "import \"adders.blif\"\nimport \"mux.v\"\n[adders.Nor(0, 0), mux.Mux2([0, 1], 0)]\n",
+	})
+
+	var out strings.Builder
+	name := filepath.Join(dir, "main.bool")
+	src, _ := ioutil.ReadFile(name)
+
+	if err := NewRuntime().Run(name, string(src), &out); err != nil {
+		t.Fatal(err)
+	} else if out.String() != "Seq[2]{1, 1}\n" {
+		t.Errorf("got %q, expected %q", out.String(), "Seq[2]{1, 1}\n")
+	}
+}
+
+func TestNetName(t *testing.T) {
+	taken := map[string]bool{}
+	tests := []struct {
+		raw string
+		exp string
+	}{
+		{"a", "a"},
+		{"x[3]", "x_3"},
+		{"3x", "n3x"},
+		{"$abc$12", "abc_12"},
+		{"where", "where_"},
+		{"v", "v_"},
+		{"a", "a_2"},
+		{"a.b", "a_b"},
+	}
+
+	for _, test := range tests {
+		if got := netName(test.raw, taken); got != test.exp {
+			t.Errorf("netName(%q) = %q, expected %q", test.raw, got, test.exp)
+		}
+	}
+}
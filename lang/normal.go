@@ -0,0 +1,608 @@
+package lang
+
+import (
+	"fmt"
+	"math/bits"
+	"sort"
+)
+
+// A normal form that expressions can be rewritten into.
+type Form string
+
+const (
+	// Conjunctive normal form: a conjunction of disjunctions of literals.
+	CNF Form = "cnf"
+	// Disjunctive normal form: a disjunction of conjunctions of literals.
+	DNF Form = "dnf"
+	// Negation normal form: negation only applies to identifiers, and the
+	// only other operators are ∧ and ∨.
+	NNF Form = "nnf"
+	// Algebraic normal form: an exclusive or of conjunctions of identifiers,
+	// which may include true.
+	ANF Form = "anf"
+)
+
+// Forms lists every valid Form.
+var Forms = []Form{CNF, DNF, NNF, ANF}
+
+const (
+	// Maximum number of clauses or products in a normal form. Converting
+	// an expression into one can make it exponentially larger.
+	maxNormalTerms = 4096
+
+	// Maximum number of distinct identifiers in an algebraic normal form.
+	maxAnfAtoms = 64
+)
+
+// How operators other than ≡ and ⊕ are written using ∧ and ∨: a single
+// operator applied to the operands, either of which may be negated.
+var junctions = map[tokenId]struct {
+	op       tokenId
+	lhs, rhs bool
+}{
+	andTok: {andTok, true, true},
+	orTok:  {orTok, true, true},
+	miTok:  {orTok, false, true},
+	leTok:  {orTok, false, true},
+	geTok:  {orTok, true, false},
+	gtTok:  {andTok, true, false},
+	ltTok:  {andTok, false, true},
+}
+
+var duals = map[tokenId]tokenId{
+	andTok: orTok,
+	orTok:  andTok,
+}
+
+// Literals in clauses and products refer to the atoms of the expression,
+// which are the identifiers and calls in it, by their position.
+type normLit struct {
+	atom int
+	neg  bool
+}
+
+// Rewrites expressions into normal forms. Identifiers and gate calls are
+// atoms that are never looked into, so the rewrite only depends on the
+// structure of the expression.
+type normalizer struct {
+	atoms []Expr
+	index map[string]int
+	memo  map[nnfKey]Expr
+}
+
+type nnfKey struct {
+	expr Expr
+	pos  bool
+}
+
+// Rewrites an expression into a normal form and returns it in Bool. → and ≡,
+// along with the comparison operators, are replaced by their definitions.
+func (rt *Runtime) Normalize(src string, form Form) (string, error) {
+	expr, errs := parseExpr(src)
+
+	if len(errs) > 0 {
+		return "", &Error{Phase: ParsePhase, Errors: errs}
+	}
+
+	n := &normalizer{index: make(map[string]int), memo: make(map[nnfKey]Expr)}
+	var res Expr
+
+	switch form {
+	case NNF:
+		res, errs = n.nnf(expr, true)
+
+	case CNF, DNF:
+		outer := andTok
+
+		if form == DNF {
+			outer = orTok
+		}
+
+		var terms [][]normLit
+
+		if res, errs = n.nnf(expr, true); len(errs) == 0 {
+			terms, errs = n.terms(res, outer)
+			res = n.join(terms, outer)
+		}
+
+	case ANF:
+		var poly map[uint64]bool
+
+		if poly, errs = n.anf(expr); len(errs) == 0 {
+			res = n.polynomial(poly)
+		}
+
+	default:
+		errs = []error{fmt.Errorf("Unknown normal form `%s`.", form)}
+	}
+
+	if len(errs) > 0 {
+		return "", &Error{Phase: EvalPhase, Errors: errs}
+	}
+
+	return pretty(res), nil
+}
+
+func normLiteral(value bool) Expr {
+	if value {
+		return &LiteralExpr{tok: token{id: trueTok, lexeme: "true"}, value: true}
+	}
+
+	return &LiteralExpr{tok: token{id: falseTok, lexeme: "false"}, value: false}
+}
+
+func normBinary(id tokenId, lhs, rhs Expr) Expr {
+	lexemes := map[tokenId]rune{andTok: andRn, orTok: orRn, xorTok: xorRn}
+	return &BinaryExpr{lhs: lhs, op: token{id: id, lexeme: string(lexemes[id])}, rhs: rhs}
+}
+
+func normNot(e Expr) Expr {
+	return &UnaryExpr{op: token{id: notTok, lexeme: string(notRn)}, rhs: e}
+}
+
+// Registers an atom and returns its position.
+func (n *normalizer) atom(e Expr) int {
+	key := pretty(e)
+
+	if i, ok := n.index[key]; ok {
+		return i
+	}
+
+	n.atoms = append(n.atoms, e)
+	n.index[key] = len(n.atoms) - 1
+	return len(n.atoms) - 1
+}
+
+func tooManyTerms() []error {
+	return []error{fmt.Errorf("The normal form has more than %d terms.", maxNormalTerms)}
+}
+
+func notBoolean(e Expr) []error {
+	return []error{errorAt(e.Span(), "Cannot rewrite `%s`, normal forms "+
+		"only apply to boolean expressions.", pretty(e))}
+}
+
+// Pushes negations down to the atoms, or negates the expression when pos is
+// false. Operands of ≡ and ⊕ are rewritten both as is and negated, so results
+// are remembered to keep nested ones from being rewritten over and over.
+func (n *normalizer) nnf(e Expr, pos bool) (Expr, []error) {
+	key := nnfKey{expr: e, pos: pos}
+
+	if res, ok := n.memo[key]; ok {
+		return res, nil
+	}
+
+	res, errs := n.rewrite(e, pos)
+
+	if len(errs) == 0 {
+		n.memo[key] = res
+	}
+
+	return res, errs
+}
+
+func (n *normalizer) rewrite(e Expr, pos bool) (Expr, []error) {
+	switch e := e.(type) {
+	case *GroupExpr:
+		return n.nnf(e.inner, pos)
+
+	case *LiteralExpr:
+		return normLiteral(e.value == pos), nil
+
+	case *IdentExpr, *CallExpr:
+		n.atom(e)
+
+		if pos {
+			return e, nil
+		}
+
+		return normNot(e), nil
+
+	case *UnaryExpr:
+		return n.nnf(e.rhs, !pos)
+
+	case *BinaryExpr:
+		lhs, errs := n.nnf(e.lhs, true)
+
+		if len(errs) > 0 {
+			return nil, errs
+		}
+
+		// Negations are only built when needed since ≡ and ⊕ use both.
+		notLhs := func() Expr { l, _ := n.nnf(e.lhs, false); return l }
+		rhs, errs := n.nnf(e.rhs, true)
+
+		if len(errs) > 0 {
+			return nil, errs
+		}
+
+		notRhs := func() Expr { r, _ := n.nnf(e.rhs, false); return r }
+
+		if j, ok := junctions[e.op.id]; ok {
+			op, l, r := j.op, j.lhs, j.rhs
+
+			if !pos {
+				op, l, r = duals[op], !l, !r
+			}
+
+			if !l {
+				lhs = notLhs()
+			}
+
+			if !r {
+				rhs = notRhs()
+			}
+
+			return normBinary(op, lhs, rhs), nil
+		}
+
+		// a ≡ b is (¬a ∨ b) ∧ (a ∨ ¬b), and a ⊕ b is (a ∨ b) ∧ (¬a ∨ ¬b).
+		// Each is the negation of the other.
+		var equal bool
+
+		switch e.op.id {
+		case eqTok:
+			equal = pos
+		case xorTok:
+			equal = !pos
+		default:
+			return nil, []error{errorAt(e.op.span, "Unknown binary operator: %s", e.op.lexeme)}
+		}
+
+		if equal {
+			return normBinary(andTok,
+				normBinary(orTok, notLhs(), rhs),
+				normBinary(orTok, lhs, notRhs())), nil
+		}
+
+		return normBinary(andTok,
+			normBinary(orTok, lhs, rhs),
+			normBinary(orTok, notLhs(), notRhs())), nil
+
+	case *BadExpr:
+		return nil, []error{errorAt(e.Span(), "Cannot evaluate expression due to error: %s", e.err)}
+	}
+
+	return nil, notBoolean(e)
+}
+
+// Turns an expression in negation normal form into a list of terms joined
+// by the outer operator, where every term is a list of literals joined by the
+// other operator: clauses for ∧, and products for ∨.
+func (n *normalizer) terms(e Expr, outer tokenId) ([][]normLit, []error) {
+	switch e := e.(type) {
+	case *LiteralExpr:
+		if e.value == (outer == andTok) {
+			return [][]normLit{}, nil
+		}
+
+		return [][]normLit{{}}, nil
+
+	case *IdentExpr, *CallExpr:
+		return [][]normLit{{{atom: n.atom(e)}}}, nil
+
+	case *UnaryExpr:
+		return [][]normLit{{{atom: n.atom(e.rhs), neg: true}}}, nil
+
+	case *BinaryExpr:
+		lhs, errs := n.terms(e.lhs, outer)
+
+		if len(errs) > 0 {
+			return nil, errs
+		}
+
+		rhs, errs := n.terms(e.rhs, outer)
+
+		if len(errs) > 0 {
+			return nil, errs
+		}
+
+		if e.op.id == outer {
+			return absorb(append(lhs, rhs...)), nil
+		}
+
+		// Distributing the inner operator pairs up every term on the left
+		// with every term on the right.
+		var terms [][]normLit
+
+		if len(lhs)*len(rhs) > maxNormalTerms {
+			return nil, tooManyTerms()
+		}
+
+		for _, l := range lhs {
+			for _, r := range rhs {
+				if t, ok := mergeTerms(l, r); ok {
+					terms = append(terms, t)
+				}
+			}
+		}
+
+		terms = absorb(terms)
+
+		if len(terms) > maxNormalTerms {
+			return nil, tooManyTerms()
+		}
+
+		return terms, nil
+	}
+
+	return nil, notBoolean(e)
+}
+
+// Combines the literals of two terms. Returns false when the result has an
+// atom and its negation, which makes clauses always true and products always
+// false.
+func mergeTerms(a, b []normLit) ([]normLit, bool) {
+	merged := append(append([]normLit{}, a...), b...)
+
+	sort.Slice(merged, func(i, j int) bool {
+		if merged[i].atom != merged[j].atom {
+			return merged[i].atom < merged[j].atom
+		}
+
+		return !merged[i].neg && merged[j].neg
+	})
+
+	var lits []normLit
+
+	for i, lit := range merged {
+		if i > 0 && lit.atom == merged[i-1].atom {
+			if lit.neg != merged[i-1].neg {
+				return nil, false
+			}
+
+			continue
+		}
+
+		lits = append(lits, lit)
+	}
+
+	return lits, true
+}
+
+// Removes terms that are the same as or contain every literal of another
+// term, which makes them redundant.
+func absorb(terms [][]normLit) [][]normLit {
+	sort.SliceStable(terms, func(i, j int) bool {
+		return len(terms[i]) < len(terms[j])
+	})
+
+	var kept [][]normLit
+
+	for _, t := range terms {
+		redundant := false
+
+		for _, k := range kept {
+			if subset(k, t) {
+				redundant = true
+				break
+			}
+		}
+
+		if !redundant {
+			kept = append(kept, t)
+		}
+	}
+
+	return kept
+}
+
+// Tells whether every literal in a is in b. Both are sorted.
+func subset(a, b []normLit) bool {
+	i := 0
+
+	for _, lit := range b {
+		if i < len(a) && a[i] == lit {
+			i++
+		}
+	}
+
+	return i == len(a)
+}
+
+func (n *normalizer) join(terms [][]normLit, outer tokenId) Expr {
+	if len(terms) == 0 {
+		return normLiteral(outer == andTok)
+	}
+
+	var e Expr
+
+	for _, t := range terms {
+		var term Expr
+
+		for _, lit := range t {
+			var l Expr = n.atoms[lit.atom]
+
+			if lit.neg {
+				l = normNot(l)
+			}
+
+			if term == nil {
+				term = l
+			} else {
+				term = normBinary(duals[outer], term, l)
+			}
+		}
+
+		if term == nil {
+			term = normLiteral(outer != andTok)
+		}
+
+		if e == nil {
+			e = term
+		} else {
+			e = normBinary(outer, e, term)
+		}
+	}
+
+	return e
+}
+
+// Returns the algebraic normal form of an expression as a set of products,
+// each of which is a set of atoms. The empty product is true.
+func (n *normalizer) anf(e Expr) (map[uint64]bool, []error) {
+	one := map[uint64]bool{0: true}
+
+	switch e := e.(type) {
+	case *GroupExpr:
+		return n.anf(e.inner)
+
+	case *LiteralExpr:
+		if e.value {
+			return one, nil
+		}
+
+		return map[uint64]bool{}, nil
+
+	case *IdentExpr, *CallExpr:
+		i := n.atom(e)
+
+		if i >= maxAnfAtoms {
+			return nil, []error{fmt.Errorf(
+				"Too many identifiers for an algebraic normal form, max is %d.", maxAnfAtoms)}
+		}
+
+		return map[uint64]bool{1 << i: true}, nil
+
+	case *UnaryExpr:
+		rhs, errs := n.anf(e.rhs)
+
+		if len(errs) > 0 {
+			return nil, errs
+		}
+
+		return anfXor(one, rhs), nil
+
+	case *BinaryExpr:
+		a, errs := n.anf(e.lhs)
+
+		if len(errs) > 0 {
+			return nil, errs
+		}
+
+		b, errs := n.anf(e.rhs)
+
+		if len(errs) > 0 {
+			return nil, errs
+		}
+
+		var res map[uint64]bool
+
+		switch e.op.id {
+		case andTok:
+			res = anfAnd(a, b)
+		case orTok:
+			res = anfXor(anfXor(a, b), anfAnd(a, b))
+		case xorTok:
+			res = anfXor(a, b)
+		case eqTok:
+			res = anfXor(one, anfXor(a, b))
+		case miTok, leTok:
+			res = anfXor(one, anfXor(a, anfAnd(a, b)))
+		case geTok:
+			res = anfXor(one, anfXor(b, anfAnd(a, b)))
+		case gtTok:
+			res = anfXor(a, anfAnd(a, b))
+		case ltTok:
+			res = anfXor(b, anfAnd(a, b))
+		default:
+			return nil, []error{errorAt(e.op.span, "Unknown binary operator: %s", e.op.lexeme)}
+		}
+
+		if len(res) > maxNormalTerms {
+			return nil, tooManyTerms()
+		}
+
+		return res, nil
+
+	case *BadExpr:
+		return nil, []error{errorAt(e.Span(), "Cannot evaluate expression due to error: %s", e.err)}
+	}
+
+	return nil, notBoolean(e)
+}
+
+func anfXor(a, b map[uint64]bool) map[uint64]bool {
+	res := make(map[uint64]bool)
+
+	for p := range a {
+		res[p] = true
+	}
+
+	for p := range b {
+		if res[p] {
+			delete(res, p)
+		} else {
+			res[p] = true
+		}
+	}
+
+	return res
+}
+
+func anfAnd(a, b map[uint64]bool) map[uint64]bool {
+	res := make(map[uint64]bool)
+
+	for p := range a {
+		for q := range b {
+			if res[p|q] {
+				delete(res, p|q)
+			} else {
+				res[p|q] = true
+			}
+		}
+	}
+
+	return res
+}
+
+// Writes the products of an algebraic normal form from the smallest to the
+// largest, with true written as 1 when there are other products.
+func (n *normalizer) polynomial(poly map[uint64]bool) Expr {
+	var prods []uint64
+
+	for p := range poly {
+		prods = append(prods, p)
+	}
+
+	sort.Slice(prods, func(i, j int) bool {
+		a, b := prods[i], prods[j]
+
+		if bits.OnesCount64(a) != bits.OnesCount64(b) {
+			return bits.OnesCount64(a) < bits.OnesCount64(b)
+		}
+
+		// Products that use earlier atoms come first.
+		return bits.Reverse64(a) > bits.Reverse64(b)
+	})
+
+	if len(prods) == 0 {
+		return normLiteral(false)
+	} else if len(prods) == 1 && prods[0] == 0 {
+		return normLiteral(true)
+	}
+
+	var e Expr
+
+	for _, p := range prods {
+		var prod Expr = &LiteralExpr{tok: token{id: trueTok, lexeme: "1"}, value: true}
+
+		for i := 0; i < len(n.atoms); i++ {
+			if p&(1<<i) == 0 {
+				continue
+			} else if p&(1<<i-1) == 0 {
+				prod = n.atoms[i]
+			} else {
+				prod = normBinary(andTok, prod, n.atoms[i])
+			}
+		}
+
+		if e == nil {
+			e = prod
+		} else {
+			e = normBinary(xorTok, e, prod)
+		}
+	}
+
+	return e
+}
@@ -0,0 +1,97 @@
+package lang
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// Returns (x(0) ∧ y(0)) ∨ (x(1) ∧ y(1)) ∨ ..., whose CNF has 2^n clauses.
+func wideDisjunction(n int) string {
+	var terms []string
+
+	for i := 0; i < n; i++ {
+		terms = append(terms, fmt.Sprintf("(x(%d) ∧ y(%d))", i, i))
+	}
+
+	return strings.Join(terms, " ∨ ")
+}
+
+func TestNormalize(t *testing.T) {
+	rt := newTestRuntime(t, "gate F (x) = x")
+
+	tests := []struct {
+		form Form
+		src  string
+		exp  string
+	}{
+		{CNF, "(a → b) ≡ c", "(a ∨ c) ∧ (¬b ∨ c) ∧ (¬a ∨ b ∨ ¬c)"},
+		{CNF, "(a ∧ b) ∨ c", "(a ∨ c) ∧ (b ∨ c)"},
+		{CNF, "a ∨ ¬a", "true"},
+		{CNF, "a ∧ ¬a", "a ∧ ¬a"},
+		{CNF, "a ∧ (a ∨ b)", "a"},
+		{DNF, "(a ∨ b) ∧ c", "(a ∧ c) ∨ (b ∧ c)"},
+		{DNF, "a ⊕ b", "(a ∧ ¬b) ∨ (¬a ∧ b)"},
+		{DNF, "a ∧ ¬a", "false"},
+		{NNF, "¬((a → b) ∧ ¬(c ∨ d))", "(a ∧ ¬b) ∨ c ∨ d"},
+		{NNF, "¬(a ≡ b)", "(a ∨ b) ∧ (¬a ∨ ¬b)"},
+		{NNF, "a > b ∨ a ≤ b", "(a ∧ ¬b) ∨ ¬a ∨ b"},
+		{NNF, "¬¬x(0) ∧ ¬Mux(a, b, c)", "x(0) ∧ ¬Mux(a, b, c)"},
+		{NNF, "¬1", "false"},
+		{NNF, "¬F((a → b) → c)", "¬F((a → b) → c)"},
+		{ANF, "a ∨ b", "a ⊕ b ⊕ (a ∧ b)"},
+		{ANF, "a → b", "1 ⊕ a ⊕ (a ∧ b)"},
+		{ANF, "¬(a ≡ b) ∧ c", "(a ∧ c) ⊕ (b ∧ c)"},
+		{ANF, "a ⊕ a", "false"},
+		{ANF, "¬a ∨ a", "true"},
+	}
+
+	for _, test := range tests {
+		got, err := rt.Normalize(test.src, test.form)
+
+		if err != nil {
+			t.Errorf("Normalize(%q, %s) error = %v", test.src, test.form, err)
+		} else if got != test.exp {
+			t.Errorf("Normalize(%q, %s) = %q, expected %q", test.src, test.form, got, test.exp)
+		}
+	}
+
+	// Every normal form has to be equivalent to the expression it came from.
+	for _, src := range []string{
+		"(a → b) → (c ≡ ¬d)",
+		"(a ⊕ b ⊕ c) ∧ (a ∨ ¬d)",
+		"a ≥ b ∧ c < d ∨ a ≤ c",
+		"¬(a ∧ (b ∨ (c → (d ⊕ a))))",
+		"¬F((a → b) → c)",
+		"F(a > b > c) ∧ ¬F(a > (b > c))",
+	} {
+		for _, form := range Forms {
+			got, err := rt.Normalize(src, form)
+
+			if err != nil {
+				t.Errorf("Normalize(%q, %s) error = %v", src, form, err)
+			} else if _, ok, _ := rt.Taut("(" + src + ") ≡ (" + got + ")"); !ok {
+				t.Errorf("Normalize(%q, %s) = %q, which is not equivalent", src, form, got)
+			}
+		}
+	}
+
+	errTests := []struct {
+		form Form
+		src  string
+		err  string
+	}{
+		{CNF, "[a] ∧ b", "Cannot rewrite `[a]`, normal forms only apply to boolean expressions."},
+		{NNF, "a is b", "Expecting an expression."},
+		{"xnf", "a", "Unknown normal form `xnf`."},
+		{CNF, wideDisjunction(13), "The normal form has more than 4096 terms."},
+	}
+
+	for _, test := range errTests {
+		_, err := rt.Normalize(test.src, test.form)
+
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("Normalize(%q, %s) error = %v, expected %q", test.src, test.form, err, test.err)
+		}
+	}
+}
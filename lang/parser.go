@@ -0,0 +1,439 @@
+package lang
+
+// Binary operator precedence levels, from loosest to tightest. Negation is a
+// unary operator so it binds tighter than all of these.
+const (
+	precEq = iota + 1
+	precMi
+	precOr
+	precXor
+	precAnd
+	precCmp
+)
+
+var (
+	precedence = map[tokenId]int{
+		eqTok:  precEq,
+		miTok:  precMi,
+		orTok:  precOr,
+		xorTok: precXor,
+		andTok: precAnd,
+		geTok:  precCmp,
+		gtTok:  precCmp,
+		leTok:  precCmp,
+		ltTok:  precCmp,
+	}
+
+	rightAssociative = map[tokenId]bool{
+		miTok: true,
+	}
+)
+
+type parser struct {
+	pos    int
+	tokens []token
+	errs   []error
+}
+
+// A single statement in a program, along with the tokens it was parsed from
+// so that errors can be traced back to a location in the source.
+type statement struct {
+	tokens []token
+	expr   evaluates
+	errs   []error
+}
+
+func parse(tokens []token) (evaluates, []error) {
+	par := parser{
+		pos:    0,
+		tokens: tokens,
+		errs:   []error{},
+	}
+
+	expr := par.main()
+	return expr, par.errs
+}
+
+// Parses a program, which is a list of statements separated by new lines.
+// Empty lines are skipped. Lines starting with `where` or `and` continue the
+// gate declaration before them, so a gate and its local bindings are parsed
+// as a single statement.
+func parseProgram(tokens []token) []statement {
+	var stmts []statement
+	var curr []token
+	newLine := false
+
+	flush := func() {
+		if len(curr) == 0 {
+			return
+		}
+
+		expr, errs := parse(curr)
+		stmts = append(stmts, statement{
+			tokens: curr,
+			expr:   expr,
+			errs:   errs,
+		})
+		curr = nil
+	}
+
+	for _, tok := range tokens {
+		if tok.id == eolTok {
+			newLine = true
+			continue
+		}
+
+		if newLine && !(tok.id == bindContTok && len(curr) > 0 && curr[0].id == gateTok) {
+			flush()
+		}
+
+		newLine = false
+		curr = append(curr, tok)
+	}
+
+	flush()
+	return stmts
+}
+
+func (p *parser) main() evaluates {
+	var ret evaluates
+
+	if p.match(gateTok) {
+		ret = p.gateDecl()
+	} else if p.match(importTok) {
+		ret = p.importDecl()
+	} else if p.curr().id == bindContTok {
+		p.errs = append(p.errs, errorAt(p.curr().span,
+			"Binding continuation used outside of gate declaration."))
+		return binding{}
+	} else if p.curr().id == identTok && p.peek().id == bindTok {
+		ret = p.binding()
+	} else {
+		ret = exprStmt{p.expression()}
+	}
+
+	if !p.done() {
+		p.errs = append(p.errs, errorAt(p.curr().span,
+			"Unexpected word `%s`", p.curr().lexeme))
+	}
+
+	return ret
+}
+
+func (p *parser) binding() binding {
+	label := p.curr()
+
+	if p.expect(identTok) != nil {
+		p.errs = append(p.errs, errorAt(p.curr().span,
+			"Expecting a binding label."))
+		return binding{}
+	}
+
+	if p.expect(bindTok) != nil {
+		p.errs = append(p.errs, errorAt(p.curr().span,
+			"Expecting `is` keyword."))
+		return binding{}
+	}
+
+	return binding{
+		label: label,
+		value: p.expression(),
+	}
+}
+
+func (p *parser) gateDecl() *gate {
+	g := &gate{}
+	g.env = nil
+
+	if p.expect(identTok) != nil {
+		p.errs = append(p.errs, errorAt(p.curr().span,
+			"Expecting a gate label."))
+		return g
+	}
+
+	g.label = p.prev()
+
+	if p.expect(oparenTok) != nil {
+		p.errs = append(p.errs, errorAt(p.curr().span,
+			"Expecting an open paren after the gate label."))
+		return g
+	}
+
+	if p.curr().id == identTok {
+		for {
+			if !p.match(identTok) {
+				p.errs = append(p.errs, errorAt(p.curr().span,
+					"Expecting an identifier but found %s instead.", p.curr()))
+			}
+
+			g.args = append(g.args, p.prev())
+
+			if p.match(identTok) {
+				p.errs = append(p.errs, errorAt(p.prev().span,
+					"Expecting a comma to separate gate arguments. Found "+
+						"identity instead."))
+				return g
+			}
+
+			if !p.match(commaTok) {
+				break
+			}
+		}
+	}
+
+	if p.expect(cparenTok) != nil {
+		p.errs = append(p.errs, errorAt(p.curr().span,
+			"Expecting a close paren after the gate arguments but found %s "+
+				"instead.", p.curr()))
+		return g
+	}
+
+	if p.expect(eqTok) != nil {
+		p.errs = append(p.errs, errorAt(p.curr().span,
+			"Expecting an equal sign after gate arguments but found %s "+
+				"instead.", p.curr()))
+		return g
+	}
+
+	g.body = p.expression()
+
+	for p.match(bindContTok) {
+		errs := len(p.errs)
+		local := p.binding()
+
+		if len(p.errs) > errs {
+			return g
+		}
+
+		g.locals = append(g.locals, local)
+	}
+
+	return g
+}
+
+func (p *parser) importDecl() importStmt {
+	if p.curr().id == errTok {
+		p.errs = append(p.errs, errorAt(p.curr().span, "%s", p.curr().err))
+		p.eat()
+		return importStmt{}
+	}
+
+	if p.expect(strTok) != nil {
+		p.errs = append(p.errs, errorAt(p.curr().span,
+			"Expecting a module path but found %s instead.", p.curr()))
+		return importStmt{}
+	}
+
+	return importStmt{path: p.prev()}
+}
+
+func (p *parser) expression() Expr {
+	return p.binary(precEq)
+}
+
+// Parses binary operators using precedence climbing. Operators with a
+// precedence lower than minPrec are left for the caller to handle, which is
+// what groups higher precedence operators deeper in the tree.
+func (p *parser) binary(minPrec int) Expr {
+	expr := p.unary()
+
+	for {
+		prec, ok := precedence[p.curr().id]
+
+		if !ok || prec < minPrec {
+			break
+		}
+
+		op := p.curr()
+		p.eat()
+
+		// Left associative operators parse their right hand side one level
+		// up so that the next operator of the same precedence is picked up
+		// by this loop instead of by the recursive call.
+		next := prec + 1
+
+		if rightAssociative[op.id] {
+			next = prec
+		}
+
+		expr = &BinaryExpr{
+			lhs: expr,
+			op:  op,
+			rhs: p.binary(next),
+		}
+	}
+
+	return expr
+}
+
+func (p *parser) unary() Expr {
+	if p.match(notTok) {
+		// unary = UNI_OPERATOR unary
+		op := p.prev()
+		return &UnaryExpr{op: op, rhs: p.unary()}
+	} else if p.match(identTok) {
+		// unary = primary = identifier
+		name := p.prev()
+
+		if !p.match(oparenTok) {
+			return &IdentExpr{name: name}
+		}
+
+		// unary = primary = gate-call
+		call := &CallExpr{callee: name, span: name.span}
+
+		// This is matching id(arg,) since getting to the command restarts
+		// and immediatelly ends because of the !p.match(cparenTok). Maybe
+		// this is ok. Maybe it's not. Just noting it here.
+		for !p.match(cparenTok) {
+			arg := p.expression()
+
+			if bad, ok := arg.(*BadExpr); ok {
+				return bad
+			}
+
+			call.args = append(call.args, arg)
+
+			if p.match(commaTok) {
+				continue
+			} else if p.match(cparenTok) {
+				break
+			} else {
+				return p.fail(errorAt(p.curr().span,
+					"Expecting a closing paren but found %s instead.", p.curr()))
+			}
+		}
+
+		call.span = joinSpans(call.span, p.prev().span)
+		return call
+	} else if p.match(trueTok, falseTok) {
+		// unary = primary = BOOLEAN
+		return &LiteralExpr{tok: p.prev(), value: p.prev().id == trueTok}
+	} else if p.match(oparenTok) {
+		// unary = "(" expression ")"
+		open := p.prev()
+		inner := p.expression()
+
+		if err := p.expect(cparenTok); err != nil {
+			return p.fail(err)
+		}
+
+		return &GroupExpr{
+			inner: inner,
+			span:  joinSpans(open.span, p.prev().span),
+		}
+	} else if p.match(obrakTok) {
+		seq := &SeqExpr{span: p.prev().span}
+
+		for !p.match(cbrakTok) {
+			item := p.expression()
+
+			if bad, ok := item.(*BadExpr); ok {
+				return bad
+			}
+
+			seq.items = append(seq.items, item)
+
+			if p.match(commaTok) {
+				continue
+			} else if p.match(cbrakTok) {
+				break
+			} else {
+				return p.fail(errorAt(p.curr().span,
+					"Expecting a closing braket but found %s instead.", p.curr()))
+			}
+		}
+
+		seq.span = joinSpans(seq.span, p.prev().span)
+		return seq
+	} else if p.curr().id == eolTok {
+		return p.fail(errorAt(p.curr().span, "Unexpected end of line."))
+	} else if p.match(numTok) {
+		return &NumberExpr{tok: p.prev()}
+	} else {
+		return p.fail(errorAt(p.curr().span,
+			"Invalid expression starting with character `%s`.",
+			p.curr().lexeme))
+	}
+}
+
+// Records a parse error and returns a placeholder expression for it.
+func (p *parser) fail(err error) Expr {
+	p.errs = append(p.errs, err)
+	return &BadExpr{err: err}
+}
+
+func (p *parser) expect(ids ...tokenId) error {
+	if !p.match(ids...) {
+		return errorAt(p.curr().span,
+			"Expecting one of the following tokens %v but found %s",
+			ids, p.curr().id)
+	}
+
+	return nil
+}
+
+func (p *parser) match(ids ...tokenId) bool {
+	for _, id := range ids {
+		if p.curr().id == id {
+			p.eat()
+			return true
+		}
+	}
+
+	return false
+}
+
+func (p *parser) eat() {
+	p.pos += 1
+}
+
+func (p parser) prev() token {
+	if p.pos-1 >= len(p.tokens) {
+		return p.eol()
+	} else {
+		return p.tokens[p.pos-1]
+	}
+}
+
+func (p parser) curr() token {
+	if p.pos >= len(p.tokens) {
+		return p.eol()
+	} else {
+		return p.tokens[p.pos]
+	}
+}
+
+func (p parser) peek() token {
+	if p.pos+1 >= len(p.tokens) {
+		return p.eol()
+	} else {
+		return p.tokens[p.pos+1]
+	}
+}
+
+// Returns an end of line token positioned right after the last token, so
+// that errors about running out of input point to the end of the line.
+func (p parser) eol() token {
+	if len(p.tokens) == 0 {
+		return token{id: eolTok}
+	}
+
+	last := p.tokens[len(p.tokens)-1].span
+	width := last.end - last.offset
+
+	return token{
+		id: eolTok,
+		span: span{
+			src:    last.src,
+			offset: last.end,
+			end:    last.end + 1,
+			line:   last.line,
+			column: last.column + width,
+		},
+	}
+}
+
+func (p parser) done() bool {
+	return p.pos >= len(p.tokens)
+}
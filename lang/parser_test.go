@@ -0,0 +1,144 @@
+package lang
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		src string
+		exp string
+	}{
+		{"a", "a"},
+		{"(a)", "a"},
+		{"¬a", "¬a"},
+		{"not a", "not a"},
+		{"¬¬a", "¬¬a"},
+		{"a ∧ b", "(a ∧ b)"},
+		{"a ∨ b ∧ c", "(a ∨ (b ∧ c))"},
+		{"a ∧ b ∨ c", "((a ∧ b) ∨ c)"},
+		{"(a ∨ b) ∧ c", "((a ∨ b) ∧ c)"},
+		{"a ∧ b ∧ c", "((a ∧ b) ∧ c)"},
+		{"a ⊕ b ∨ c ⊕ d", "((a ⊕ b) ∨ (c ⊕ d))"},
+		{"a ∧ b ⊕ c", "((a ∧ b) ⊕ c)"},
+		{"a → b → c", "(a → (b → c))"},
+		{"a ∨ b → c", "((a ∨ b) → c)"},
+		{"a → b ≡ c", "((a → b) ≡ c)"},
+		{"a = b = c", "((a = b) = c)"},
+		{"a > 1 ∧ b", "((a > 1) ∧ b)"},
+		{"¬a ∧ b", "(¬a ∧ b)"},
+		{"¬(a ∧ b)", "¬(a ∧ b)"},
+		{"F(a ∨ b, [c, 1], 12)", "F((a ∨ b), [c, 1], 12)"},
+		{"F()", "F()"},
+		{"[]", "[]"},
+		{"x is a ∧ b", "x is (a ∧ b)"},
+		{"gate F (a, b) = a ∧ b", "gate F (a, b) = (a ∧ b)"},
+		{"gate F (a) = x where x is ¬a and y is x", "gate F (a) = x where x is ¬a and y is x"},
+		{"gate F () = 1", "gate F () = 1"},
+	}
+
+	for _, test := range tests {
+		expr, errs := parse(scan(test.src))
+
+		if len(errs) > 0 {
+			t.Errorf("parse(%q) returned errors: %v", test.src, errs)
+			continue
+		}
+
+		if got := fmt.Sprint(expr); got != test.exp {
+			t.Errorf("parse(%q) = %s, expected %s", test.src, got, test.exp)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		src  string
+		errs []string
+	}{
+		{"a ∧", []string{
+			"1:4: Unexpected end of line.",
+		}},
+		{"(a", []string{
+			"1:3: Expecting one of the following tokens [cparen] but found eol",
+		}},
+		{"a b", []string{
+			"1:3: Unexpected word `b`",
+		}},
+		{"F(a b)", []string{
+			"1:5: Expecting a closing paren but found ID(b) instead.",
+			"1:5: Unexpected word `b`",
+		}},
+		{"[a b]", []string{
+			"1:4: Expecting a closing braket but found ID(b) instead.",
+			"1:4: Unexpected word `b`",
+		}},
+		{"a ∧ )", []string{
+			"1:5: Invalid expression starting with character `)`.",
+			"1:5: Unexpected word `)`",
+		}},
+		{"x is", []string{
+			"1:5: Unexpected end of line.",
+		}},
+		{"where x is a", []string{
+			"1:1: Binding continuation used outside of gate declaration.",
+		}},
+		{"gate F (a) = x where 1 is a", []string{
+			"1:22: Expecting a binding label.",
+			"1:22: Unexpected word `1`",
+		}},
+		{"gate (a) = a", []string{
+			"1:6: Expecting a gate label.",
+			"1:6: Unexpected word `(`",
+		}},
+		{"gate F a = a", []string{
+			"1:8: Expecting an open paren after the gate label.",
+			"1:8: Unexpected word `a`",
+		}},
+		{"gate F (a b) = a", []string{
+			"1:11: Expecting a comma to separate gate arguments. Found identity instead.",
+			"1:12: Unexpected word `)`",
+		}},
+		{"gate F (a, 1) = a", []string{
+			"1:12: Expecting an identifier but found TRUE instead.",
+			"1:12: Expecting a close paren after the gate arguments but found TRUE instead.",
+			"1:12: Unexpected word `1`",
+		}},
+		{"gate F (a) a", []string{
+			"1:12: Expecting an equal sign after gate arguments but found ID(a) instead.",
+			"1:12: Unexpected word `a`",
+		}},
+	}
+
+	for _, test := range tests {
+		_, errs := parse(scan(test.src))
+		var got []string
+
+		for _, err := range errs {
+			if serr, ok := err.(sourceError); ok {
+				got = append(got, fmt.Sprintf("%s: %s", serr.span, serr.msg))
+			} else {
+				got = append(got, err.Error())
+			}
+		}
+
+		if fmt.Sprint(got) != fmt.Sprint(test.errs) {
+			t.Errorf("parse(%q) errors:\n got %q\n exp %q", test.src, got, test.errs)
+		}
+	}
+}
+
+func FuzzParse(f *testing.F) {
+	f.Add("x is y ∧ ¬z")
+	f.Add("gate Xor (x, y) = (x ∨ y) ∧ ¬(x ∧ y)")
+	f.Add("Add8([0, 0, 0, 0, 0, 0, 1, 1], x)")
+	f.Add("where sum is [b00(0), b01(0)]")
+	f.Add("gate F (a b) = a\n(b")
+
+	f.Fuzz(func(t *testing.T, src string) {
+		for _, stmt := range parseProgram(scan(src)) {
+			_ = fmt.Sprint(stmt.expr)
+		}
+	})
+}
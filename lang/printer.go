@@ -0,0 +1,244 @@
+package lang
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Renders expressions back into source form. Every binary expression is
+// wrapped in parentheses so that the grouping chosen by the parser is
+// visible.
+type printer struct {
+	buff strings.Builder
+}
+
+func render(e Expr) string {
+	p := &printer{}
+	e.Accept(p)
+	return p.buff.String()
+}
+
+func (p *printer) VisitBadExpr(e *BadExpr) {
+	fmt.Fprintf(&p.buff, "ERROR(%s)", e.err)
+}
+
+func (p *printer) VisitBinaryExpr(e *BinaryExpr) {
+	p.buff.WriteString("(")
+	e.lhs.Accept(p)
+	fmt.Fprintf(&p.buff, " %s ", e.op.lexeme)
+	e.rhs.Accept(p)
+	p.buff.WriteString(")")
+}
+
+func (p *printer) VisitUnaryExpr(e *UnaryExpr) {
+	p.buff.WriteString(e.op.lexeme)
+
+	if stringIsOp(e.op.lexeme) {
+		p.buff.WriteString(" ")
+	}
+
+	e.rhs.Accept(p)
+}
+
+// Binary expressions already print their own parentheses, so groups only
+// print the expression inside of them.
+func (p *printer) VisitGroupExpr(e *GroupExpr) {
+	e.inner.Accept(p)
+}
+
+func (p *printer) VisitCallExpr(e *CallExpr) {
+	fmt.Fprintf(&p.buff, "%s(%s)", e.callee.lexeme, joinExprs(e.args))
+}
+
+func (p *printer) VisitIdentExpr(e *IdentExpr) {
+	p.buff.WriteString(e.name.lexeme)
+}
+
+func (p *printer) VisitLiteralExpr(e *LiteralExpr) {
+	if e.tok.lexeme != "" {
+		p.buff.WriteString(e.tok.lexeme)
+	} else {
+		fmt.Fprintf(&p.buff, "%t", e.value)
+	}
+}
+
+func (p *printer) VisitSeqExpr(e *SeqExpr) {
+	fmt.Fprintf(&p.buff, "[%s]", joinExprs(e.items))
+}
+
+func (p *printer) VisitNumberExpr(e *NumberExpr) {
+	p.buff.WriteString(e.tok.lexeme)
+}
+
+// Renders expressions back into source form with as few parentheses as
+// possible. Operands that use a different operator than the expression they
+// are in are still grouped since that is easier to read than relying on
+// precedence.
+type prettyPrinter struct {
+	buff strings.Builder
+}
+
+var associative = map[tokenId]bool{
+	andTok: true,
+	orTok:  true,
+	xorTok: true,
+}
+
+func pretty(e Expr) string {
+	p := &prettyPrinter{}
+	e.Accept(p)
+	return p.buff.String()
+}
+
+// Operands that use the same operator are only left ungrouped when the
+// operator is associative. `→` groups to the right and `≡` and the comparison
+// operators group to the left, so either side could be read back wrong.
+func (p *prettyPrinter) operand(e Expr, op token) {
+	if bin, ok := unwrapGroup(e).(*BinaryExpr); ok &&
+		(bin.op.id != op.id || !associative[op.id]) {
+		fmt.Fprintf(&p.buff, "(%s)", pretty(bin))
+		return
+	}
+
+	e.Accept(p)
+}
+
+func (p *prettyPrinter) VisitBadExpr(e *BadExpr) {
+	fmt.Fprintf(&p.buff, "ERROR(%s)", e.err)
+}
+
+func (p *prettyPrinter) VisitBinaryExpr(e *BinaryExpr) {
+	p.operand(e.lhs, e.op)
+	fmt.Fprintf(&p.buff, " %s ", e.op.lexeme)
+	p.operand(e.rhs, e.op)
+}
+
+func (p *prettyPrinter) VisitUnaryExpr(e *UnaryExpr) {
+	p.buff.WriteString(e.op.lexeme)
+
+	if stringIsOp(e.op.lexeme) {
+		p.buff.WriteString(" ")
+	}
+
+	if _, ok := unwrapGroup(e.rhs).(*BinaryExpr); ok {
+		fmt.Fprintf(&p.buff, "(%s)", pretty(e.rhs))
+	} else {
+		e.rhs.Accept(p)
+	}
+}
+
+func (p *prettyPrinter) VisitGroupExpr(e *GroupExpr) {
+	e.inner.Accept(p)
+}
+
+func (p *prettyPrinter) VisitCallExpr(e *CallExpr) {
+	var args []string
+
+	for _, arg := range e.args {
+		args = append(args, pretty(arg))
+	}
+
+	fmt.Fprintf(&p.buff, "%s(%s)", e.callee.lexeme, strings.Join(args, ", "))
+}
+
+func (p *prettyPrinter) VisitIdentExpr(e *IdentExpr) {
+	p.buff.WriteString(e.name.lexeme)
+}
+
+func (p *prettyPrinter) VisitLiteralExpr(e *LiteralExpr) {
+	if e.tok.lexeme != "" {
+		p.buff.WriteString(e.tok.lexeme)
+	} else {
+		fmt.Fprintf(&p.buff, "%t", e.value)
+	}
+}
+
+func (p *prettyPrinter) VisitSeqExpr(e *SeqExpr) {
+	var items []string
+
+	for _, item := range e.items {
+		items = append(items, pretty(item))
+	}
+
+	fmt.Fprintf(&p.buff, "[%s]", strings.Join(items, ", "))
+}
+
+func (p *prettyPrinter) VisitNumberExpr(e *NumberExpr) {
+	p.buff.WriteString(e.tok.lexeme)
+}
+
+func (s exprStmt) String() string {
+	return render(s.expr)
+}
+
+func (b binding) String() string {
+	return fmt.Sprintf("%s is %s", b.label.lexeme, render(b.value))
+}
+
+func (g *gate) String() string {
+	var args []string
+
+	for _, arg := range g.args {
+		args = append(args, arg.lexeme)
+	}
+
+	str := fmt.Sprintf("gate %s (%s) = %s", g.label.lexeme,
+		strings.Join(args, ", "), render(g.body))
+
+	for i, local := range g.locals {
+		if i == 0 {
+			str += " where " + local.String()
+		} else {
+			str += " and " + local.String()
+		}
+	}
+
+	return str
+}
+
+func (i importStmt) String() string {
+	return fmt.Sprintf("import %s", i.path.lexeme)
+}
+
+func joinExprs(exprs []Expr) string {
+	var strs []string
+
+	for _, expr := range exprs {
+		strs = append(strs, render(expr))
+	}
+
+	return strings.Join(strs, ", ")
+}
+
+// Formats a value the way it is displayed in the repl. Booleans inside of
+// sequences are displayed as ones and zeros.
+func print(v value, env environment) string {
+	if v.isBoolean() {
+		return fmt.Sprintf("%t", v.boolean.internal)
+	} else if v.isSequence() {
+		buff := fmt.Sprintf("Seq[%d]{", len(v.sequence.internal))
+
+		for i, expr := range v.sequence.internal {
+			if i != 0 {
+				buff += ", "
+			}
+
+			ret, _ := evaluate(expr, env)
+			out := print(ret, env)
+
+			if out == "true" {
+				out = "1"
+			} else if out == "false" {
+				out = "0"
+			}
+
+			buff += fmt.Sprintf("%s", out)
+		}
+
+		return buff + "}"
+	} else if v.isNumber() {
+		return fmt.Sprintf("%d", v.number)
+	} else {
+		return "Error"
+	}
+}
@@ -0,0 +1,425 @@
+package lang
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/davecgh/go-spew/spew"
+)
+
+// A Runtime evaluates Bool source code. Bindings and gates are kept around
+// between calls, so a Runtime behaves just like a repl session.
+type Runtime struct {
+	Settings Settings
+
+	env     environment
+	history []string
+	loading map[string]bool
+}
+
+// Settings change the way Exec handles its input.
+type Settings struct {
+	Mode Mode
+
+	// Directories searched for imported modules, after the directory of the
+	// file doing the import. Defaults to the directories in BOOL_PATH.
+	Path []string
+}
+
+// Mode is what Exec does with its input.
+type Mode string
+
+const (
+	ScanMode  Mode = "scan"
+	ParseMode Mode = "parse"
+	PrintMode Mode = "print"
+	EvalMode  Mode = "eval"
+)
+
+// Modes lists every valid Mode.
+var Modes = []Mode{ScanMode, ParseMode, PrintMode, EvalMode}
+
+// ResultKind is the kind of statement that produced a Result.
+type ResultKind string
+
+const (
+	ValueResult   ResultKind = "value"
+	BindingResult ResultKind = "binding"
+	GateResult    ResultKind = "gate"
+	ImportResult  ResultKind = "import"
+	TextResult    ResultKind = "text"
+)
+
+// A Result is the outcome of executing a single statement. Expressions have
+// a Value, bindings and gates have the Name they declared, imports have the
+// Name of the module they loaded, and the scan, parse, and print modes
+// produce Lines of text. Err is always an *Error.
+type Result struct {
+	Kind  ResultKind
+	Name  string
+	Value Value
+	Lines []string
+	Err   error
+}
+
+// Phase is the step in which an Error was found.
+type Phase string
+
+const (
+	ParsePhase Phase = "parse"
+	EvalPhase  Phase = "evaluate"
+)
+
+// Every error returned by a Runtime is an *Error, which groups together all
+// of the errors found in a single statement.
+type Error struct {
+	Phase  Phase
+	Errors []error
+}
+
+func NewRuntime() *Runtime {
+	return &Runtime{
+		Settings: Settings{
+			Mode: EvalMode,
+			Path: filepath.SplitList(os.Getenv("BOOL_PATH")),
+		},
+		env:     newEnvironment(nil),
+		loading: make(map[string]bool),
+	}
+}
+
+func (e *Error) Error() string {
+	var msgs []string
+
+	for _, err := range e.Errors {
+		msgs = append(msgs, err.Error())
+	}
+
+	return strings.Join(msgs, "\n")
+}
+
+// Checks that m is one of the known modes.
+func (m Mode) Valid() bool {
+	for _, mode := range Modes {
+		if m == mode {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Handles a line of input according to the current mode and returns the
+// result of every statement in it. The mode can be overridden for a single
+// line by starting it with the name of a mode followed by a colon, such as
+// `scan: a ∧ b`. Input is recorded in the runtime's history.
+func (rt *Runtime) Exec(src string) []Result {
+	if strings.TrimSpace(src) == "" {
+		return nil
+	}
+
+	rt.history = append(rt.history, src)
+	mode, src := rt.mode(src)
+
+	switch mode {
+	case ScanMode:
+		return []Result{{Kind: TextResult, Lines: Tokens(src)}}
+
+	case ParseMode:
+		str, err := Parse(src)
+
+		if err != nil {
+			return []Result{{Kind: TextResult, Err: err}}
+		}
+
+		return []Result{{Kind: TextResult, Lines: []string{str}}}
+
+	case PrintMode:
+		name := strings.TrimSpace(src)
+		dump, ok := rt.Dump(name)
+
+		if !ok {
+			return []Result{{Kind: TextResult, Err: &Error{
+				Phase:  EvalPhase,
+				Errors: []error{fmt.Errorf("Cannot find `%s` in current environment.", name)},
+			}}}
+		}
+
+		return []Result{{
+			Kind:  TextResult,
+			Lines: strings.Split(strings.TrimSuffix(dump, "\n"), "\n"),
+		}}
+
+	default:
+		return rt.run(newSource("", src))
+	}
+}
+
+// Checks if src ends with a statement that may continue on the next line.
+// That is a gate declaration, which can be followed by `where` and `and`
+// clauses that declare its local bindings.
+func (rt *Runtime) Incomplete(src string) bool {
+	mode, src := rt.mode(src)
+
+	if mode != EvalMode {
+		return false
+	}
+
+	stmts := parseProgram(scan(src))
+
+	if len(stmts) == 0 || len(stmts[len(stmts)-1].errs) > 0 {
+		return false
+	}
+
+	_, ok := stmts[len(stmts)-1].expr.(*gate)
+	return ok
+}
+
+// Checks if line continues the statement before it, which it does when it
+// starts with `where` or `and`.
+func (rt *Runtime) Continues(line string) bool {
+	toks := scan(line)
+	return len(toks) > 0 && toks[0].id == bindContTok
+}
+
+// Returns the mode to use for src, which is either the runtime's mode or the
+// one src starts with, along with the rest of src.
+func (rt *Runtime) mode(src string) (Mode, string) {
+	for _, m := range Modes {
+		if strings.HasPrefix(src, string(m)+":") {
+			return m, strings.TrimPrefix(src, string(m)+":")
+		}
+	}
+
+	return rt.Settings.Mode, src
+}
+
+// Returns every line of input given to Exec, oldest first.
+func (rt *Runtime) History() []string {
+	return rt.history
+}
+
+// Evaluates every statement in src and returns the value of the last one.
+// Statements that do not produce a value, like bindings, return a Value of
+// kind None. Evaluation stops at the first statement with errors.
+func (rt *Runtime) Eval(src string) (Value, error) {
+	results := rt.run(newSource("", src))
+
+	if len(results) == 0 {
+		return Value{}, nil
+	}
+
+	last := results[len(results)-1]
+	return last.Value, last.Err
+}
+
+// Runs a program, writing the value of every expression statement to out,
+// one per line. Errors point to locations in a source with the given name.
+func (rt *Runtime) Run(name, src string, out io.Writer) error {
+	for _, res := range rt.run(newSource(name, src)) {
+		if res.Err != nil {
+			return res.Err
+		} else if res.Kind == ValueResult {
+			fmt.Fprintln(out, res.Value)
+		}
+	}
+
+	return nil
+}
+
+// Binds an identifier to a value in the global scope.
+func (rt *Runtime) Bind(name string, v Value) error {
+	label, err := identifierToken(name)
+
+	if err != nil {
+		return &Error{Phase: ParsePhase, Errors: []error{err}}
+	} else if v.Kind() == None {
+		return &Error{Phase: EvalPhase, Errors: []error{fmt.Errorf(
+			"Cannot bind `%s` to a value of kind %s", name, v.Kind())}}
+	}
+
+	rt.env.setBinding(label.lexeme, v.expr())
+	return nil
+}
+
+// Declares a gate in the global scope. The body is a single expression that
+// can reference the gate's arguments by name.
+func (rt *Runtime) DefineGate(name string, args []string, body string) error {
+	var errs []error
+
+	label, err := identifierToken(name)
+
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	g := &gate{label: label}
+
+	for _, arg := range args {
+		tok, err := identifierToken(arg)
+
+		if err != nil {
+			errs = append(errs, err)
+		}
+
+		g.args = append(g.args, tok)
+	}
+
+	expr, parseErrs := parse(scan(body))
+	errs = append(errs, parseErrs...)
+
+	if stmt, ok := expr.(exprStmt); ok {
+		g.body = stmt.expr
+	} else if len(parseErrs) == 0 {
+		errs = append(errs, errors.New("Expecting the gate body to be an expression."))
+	}
+
+	if len(errs) > 0 {
+		return &Error{Phase: ParsePhase, Errors: errs}
+	}
+
+	return rt.exec(statement{expr: g}).Err
+}
+
+// Builds a truth table for an expression or a gate and returns it as aligned
+// lines of text.
+func (rt *Runtime) Table(src string) ([]string, error) {
+	t, errs := truthTable(src, rt.env)
+
+	if len(errs) > 0 {
+		return nil, &Error{Phase: EvalPhase, Errors: errs}
+	}
+
+	return t.lines(), nil
+}
+
+// Returns a dump of the internal representation of a binding or a gate.
+func (rt *Runtime) Dump(name string) (string, bool) {
+	if e, ok := rt.env.getBinding(name); ok {
+		return spew.Sdump(e), true
+	} else if e, ok := rt.env.getGate(name); ok {
+		return spew.Sdump(e), true
+	}
+
+	return "", false
+}
+
+// Executes every statement in a source, stopping after the first one with
+// errors.
+func (rt *Runtime) run(src *source) []Result {
+	var results []Result
+
+	for _, stmt := range parseProgram(scanSource(src)) {
+		res := rt.exec(stmt)
+		results = append(results, res)
+
+		if res.Err != nil {
+			break
+		}
+	}
+
+	return results
+}
+
+func (rt *Runtime) exec(stmt statement) Result {
+	if len(stmt.errs) > 0 {
+		return Result{Err: &Error{Phase: ParsePhase, Errors: stmt.errs}}
+	}
+
+	res := Result{Kind: ValueResult}
+	var ret value
+	var errs []error
+
+	switch v := stmt.expr.(type) {
+	case binding:
+		res = Result{Kind: BindingResult, Name: v.label.lexeme}
+		ret, errs = v.eval(rt.env)
+
+	case *gate:
+		res = Result{Kind: GateResult, Name: v.label.lexeme}
+		ret, errs = v.eval(rt.env)
+
+	case importStmt:
+		res = Result{Kind: ImportResult, Name: v.namespace()}
+		errs = rt.load(v)
+
+	default:
+		ret, errs = stmt.expr.eval(rt.env)
+	}
+
+	if len(errs) == 0 {
+		if res.Kind != ValueResult {
+			return res
+		}
+
+		res.Value, errs = newValue(ret, rt.env)
+
+		if len(errs) == 0 {
+			return res
+		}
+	}
+
+	if len(stmt.tokens) > 0 {
+		first := stmt.tokens[0]
+		last := stmt.tokens[len(stmt.tokens)-1]
+		errs = errorsAt(joinSpans(first.span, last.span), errs)
+	}
+
+	return Result{Kind: res.Kind, Name: res.Name, Err: &Error{Phase: EvalPhase, Errors: errs}}
+}
+
+func identifierToken(name string) (token, error) {
+	toks := scan(name)
+
+	if len(toks) != 1 || toks[0].id != identTok {
+		return token{}, fmt.Errorf("Invalid identifier `%s`", name)
+	}
+
+	return toks[0], nil
+}
+
+// Returns a description of every token in src, along with its location.
+func Tokens(src string) []string {
+	var strs []string
+
+	for _, t := range scan(src) {
+		strs = append(strs, fmt.Sprintf("%s %s", t.span, t))
+	}
+
+	return strs
+}
+
+// Parses a single statement and renders it back into source form with every
+// binary expression wrapped in parentheses.
+func Parse(src string) (string, error) {
+	expr, errs := parse(scan(src))
+
+	if len(errs) > 0 {
+		return "", &Error{Phase: ParsePhase, Errors: errs}
+	}
+
+	return fmt.Sprint(expr), nil
+}
+
+// Returns a description of every operator along with the ways to type it.
+func Keyboard() []string {
+	return []string{
+		fmt.Sprintf("conjunction: %s or %s", string(andRn), string(andAsciiRn)),
+		fmt.Sprintf("disjunction: %s or %s", string(orRn), string(orAsciiRn)),
+		fmt.Sprintf("negation: %s or %s", string(notRn), string(notAsciiRn)),
+		fmt.Sprintf("exclusive or: %s or %s", string(xorRn), string(xorAsciiRn)),
+		fmt.Sprintf("equivalence: %s or %s", string(eqRn), string(eqAsciiRn)),
+		fmt.Sprintf("material implication: %s", string(miRn)),
+	}
+}
+
+// Formats an error as a list of lines. Errors that can be traced back to a
+// location in the source start with that location, when the source has a
+// name, and end with the offending line of source code underlined.
+func FormatError(err error) []string {
+	return formatError(err)
+}
@@ -0,0 +1,220 @@
+package lang
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// Returns a runtime that has evaluated lines, failing the test when they have
+// errors. Imports are not looked up in BOOL_PATH, so tests do not depend on
+// the environment they run in.
+func newTestRuntime(t *testing.T, lines ...string) *Runtime {
+	t.Helper()
+
+	rt := NewRuntime()
+	rt.Settings.Path = nil
+
+	if _, err := rt.Eval(strings.Join(lines, "\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	return rt
+}
+
+func TestRuntimeBind(t *testing.T) {
+	rt := newTestRuntime(t, "out is [a ∧ b, ¬a]")
+
+	if err := rt.Bind("a", Bool(true)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := rt.Bind("b", Num(0)); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := rt.Eval("out"); err == nil {
+		t.Error("expected a type error when `b` is a number")
+	}
+
+	if err := rt.Bind("b", Bool(true)); err != nil {
+		t.Fatal(err)
+	}
+
+	val, err := rt.Eval("out")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	items := val.Items()
+
+	if val.Kind() != Sequence || len(items) != 2 || !items[0].Bool() || items[1].Bool() {
+		t.Errorf("unexpected value %s", val)
+	}
+
+	if err := rt.Bind("not an identifier", Bool(true)); err == nil {
+		t.Error("expected an error when binding to an invalid identifier")
+	}
+
+	if err := rt.Bind("c", Value{}); err == nil {
+		t.Error("expected an error when binding to an empty value")
+	}
+}
+
+func TestRuntimeDefineGate(t *testing.T) {
+	rt := NewRuntime()
+
+	if err := rt.DefineGate("Xor", []string{"a", "b"}, "(a ∨ b) ∧ ¬(a ∧ b)"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]bool{
+		"Xor(0, 0)": false,
+		"Xor(0, 1)": true,
+		"Xor(1, 0)": true,
+		"Xor(1, 1)": false,
+	}
+
+	for src, exp := range tests {
+		val, err := rt.Eval(src)
+
+		if err != nil {
+			t.Errorf("%s: unexpected error %s", src, err)
+		} else if val.Kind() != Boolean || val.Bool() != exp {
+			t.Errorf("%s = %s, expected %t", src, val, exp)
+		}
+	}
+
+	if err := rt.DefineGate("Bad", []string{"a"}, "a ∧"); err == nil {
+		t.Error("expected an error for an invalid gate body")
+	}
+
+	if err := rt.DefineGate("Bad", []string{"a"}, "x is a"); err == nil {
+		t.Error("expected an error for a gate body that is not an expression")
+	}
+}
+
+func TestRuntimeRun(t *testing.T) {
+	var out bytes.Buffer
+
+	src := "gate Not (a) = ¬a\nNot(1)\n\nx is [Not(0), 12]\nx\n"
+	err := NewRuntime().Run("test.bool", src, &out)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if exp := "false\nSeq[2]{1, 12}\n"; out.String() != exp {
+		t.Errorf("got %q, expected %q", out.String(), exp)
+	}
+
+	err = NewRuntime().Run("test.bool", "a is 1\nb ∧ a\n", &out)
+	lerr, ok := err.(*Error)
+
+	if !ok || lerr.Phase != EvalPhase || len(lerr.Errors) != 1 {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	lines := FormatError(lerr.Errors[0])
+	exp := []string{
+		"test.bool:2:1: Undefined identifier `b`",
+		"  b ∧ a",
+		"  ^",
+	}
+
+	if len(lines) != len(exp) {
+		t.Fatalf("got %q, expected %q", lines, exp)
+	}
+
+	for i := range exp {
+		if lines[i] != exp[i] {
+			t.Errorf("got %q, expected %q", lines[i], exp[i])
+		}
+	}
+}
+
+func TestRuntimeExec(t *testing.T) {
+	rt := NewRuntime()
+	results := rt.Exec("a is 1\ngate Not(x) = ¬x\nNot(a)")
+
+	if len(results) != 3 {
+		t.Fatalf("expected 3 results but got %d", len(results))
+	}
+
+	if res := results[0]; res.Kind != BindingResult || res.Name != "a" || res.Err != nil {
+		t.Errorf("unexpected binding result %+v", res)
+	}
+
+	if res := results[1]; res.Kind != GateResult || res.Name != "Not" || res.Err != nil {
+		t.Errorf("unexpected gate result %+v", res)
+	}
+
+	if res := results[2]; res.Kind != ValueResult || res.Value.Kind() != Boolean || res.Value.Bool() {
+		t.Errorf("unexpected value result %+v", res)
+	}
+
+	rt.Settings.Mode = ParseMode
+	results = rt.Exec("a ∧ b ∨ c")
+
+	if len(results) != 1 || results[0].Kind != TextResult || results[0].Lines[0] != "((a ∧ b) ∨ c)" {
+		t.Errorf("unexpected parse result %+v", results)
+	}
+
+	results = rt.Exec("eval: Not(0) ∧ b")
+
+	if len(results) != 1 || results[0].Err == nil {
+		t.Errorf("expected an error but got %+v", results)
+	}
+
+	if len(rt.History()) != 3 {
+		t.Errorf("expected 3 lines of history but got %v", rt.History())
+	}
+}
+
+func TestRuntimeIncomplete(t *testing.T) {
+	rt := newTestRuntime(t, "gate Not (a) = ¬a", "g is 1")
+
+	tests := []struct {
+		src        string
+		incomplete bool
+	}{
+		{"a ∧ b", false},
+		{"g is a", false},
+		{"gate F (a) = ¬a", true},
+		{"gate F (a) = Not(a) ∧ g", true},
+		{"gate F (a) = x", true},
+		{"gate F (a) = x\n  where x is y", true},
+		{"gate F (a) = x where x is ¬a", true},
+		{"gate F (a) = x\ng", false},
+		{"gate F (a b) = x", false},
+		{"parse: gate F (a) = x", false},
+	}
+
+	for _, test := range tests {
+		if got := rt.Incomplete(test.src); got != test.incomplete {
+			t.Errorf("Incomplete(%q) = %v, expected %v", test.src, got, test.incomplete)
+		}
+	}
+}
+
+func TestRuntimeContinues(t *testing.T) {
+	rt := NewRuntime()
+
+	tests := []struct {
+		line      string
+		continues bool
+	}{
+		{"where x is 0", true},
+		{"  and y is x", true},
+		{"x is 0", false},
+		{"a ∧ b", false},
+		{"", false},
+	}
+
+	for _, test := range tests {
+		if got := rt.Continues(test.line); got != test.continues {
+			t.Errorf("Continues(%q) = %v, expected %v", test.line, got, test.continues)
+		}
+	}
+}
@@ -0,0 +1,487 @@
+package lang
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Values for the free identifiers of an expression, in the order they first
+// appear in it.
+type Assignment struct {
+	Names  []string
+	Values []Value
+}
+
+// Looks for values of the identifiers in an expression that are not bound to
+// anything which make the expression true. Returns false when there are none,
+// meaning the expression is unsatisfiable.
+func (rt *Runtime) Sat(src string) (Assignment, bool, error) {
+	return rt.solve(src, true)
+}
+
+// Checks that an expression is true no matter what values its free
+// identifiers have. Returns false along with values that make it false when
+// it is not.
+func (rt *Runtime) Taut(src string) (Assignment, bool, error) {
+	a, found, err := rt.solve(src, false)
+	return a, !found && err == nil, err
+}
+
+// Checks that an expression is false no matter what values its free
+// identifiers have. Returns false along with values that make it true when
+// it is not.
+func (rt *Runtime) Contra(src string) (Assignment, bool, error) {
+	a, found, err := rt.solve(src, true)
+	return a, !found && err == nil, err
+}
+
+// Looks for values of the free identifiers in an expression that make it
+// equal to want.
+func (rt *Runtime) solve(src string, want bool) (Assignment, bool, error) {
+	expr, errs := parseExpr(src)
+
+	if len(errs) > 0 {
+		return Assignment{}, false, &Error{Phase: ParsePhase, Errors: errs}
+	}
+
+	c := newCircuit()
+	names, inputs := freeInputs(c, expr, &rt.env)
+	w, errs := blast(c, expr, &rt.env, inputs)
+
+	if len(errs) > 0 {
+		return Assignment{}, false, &Error{Phase: EvalPhase, Errors: errs}
+	} else if w.list {
+		return Assignment{}, false, &Error{Phase: EvalPhase, Errors: []error{fmt.Errorf(
+			"Expecting a single bit but the expression returns %s.", describeShape(wireShape(w)))}}
+	}
+
+	root := w.node
+
+	if !want {
+		root = c.not(root)
+	}
+
+	model, ok := c.solve(root)
+
+	if !ok {
+		return Assignment{}, false, nil
+	}
+
+	return rt.assignment(names, inputs, model), true, nil
+}
+
+func (rt *Runtime) assignment(names []string, inputs map[string]wire, model []bool) Assignment {
+	a := Assignment{Names: names}
+
+	for _, name := range names {
+		val, _ := evaluate(wireExpr(inputs[name], model), rt.env)
+		v, _ := newValue(val, rt.env)
+		a.Values = append(a.Values, v)
+	}
+
+	return a
+}
+
+// Parses a source made up of a single expression.
+func parseExpr(src string) (Expr, []error) {
+	stmt, errs := parse(scan(strings.TrimSpace(src)))
+
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	expr, ok := stmt.(exprStmt)
+
+	if !ok {
+		return nil, []error{errors.New("Expecting an expression.")}
+	}
+
+	return expr.expr, nil
+}
+
+func (a Assignment) String() string {
+	var vals []string
+
+	for i, name := range a.Names {
+		vals = append(vals, fmt.Sprintf("%s = %s", name, a.Values[i]))
+	}
+
+	return strings.Join(vals, ", ")
+}
+
+// A literal is a variable or its negation. Variable v is literal 2v and its
+// negation is 2v+1, so flipping the last bit negates a literal.
+type literal int
+
+func posLit(v int) literal { return literal(v << 1) }
+func negLit(v int) literal { return literal(v<<1 | 1) }
+
+func (l literal) variable() int { return int(l >> 1) }
+func (l literal) negated() bool { return l&1 == 1 }
+func (l literal) not() literal  { return l ^ 1 }
+
+const (
+	unassigned int8 = 0
+	assignedT  int8 = 1
+	assignedF  int8 = -1
+
+	// Conflicts between restarts are this many times the next number in the
+	// Luby sequence.
+	restartUnit = 100
+)
+
+// A conflict driven clause learning SAT solver. Clauses are watched by two
+// of their literals, and every conflict is analyzed to learn a clause that
+// prevents it from happening again, which also tells the solver how far back
+// it can jump. Variables that show up in recent conflicts are tried first.
+type satSolver struct {
+	clauses  [][]literal
+	watches  [][]int
+	assigns  []int8
+	level    []int
+	reason   []int
+	trail    []literal
+	trailLim []int
+	qhead    int
+
+	activity []float64
+	varInc   float64
+	phase    []bool
+	seen     []bool
+
+	unsat bool
+}
+
+func newSatSolver(vars int) *satSolver {
+	s := &satSolver{varInc: 1}
+	s.grow(vars)
+	return s
+}
+
+func (s *satSolver) grow(vars int) {
+	for len(s.assigns) < vars {
+		s.assigns = append(s.assigns, unassigned)
+		s.level = append(s.level, 0)
+		s.reason = append(s.reason, -1)
+		s.activity = append(s.activity, 0)
+		s.phase = append(s.phase, false)
+		s.seen = append(s.seen, false)
+		s.watches = append(s.watches, nil, nil)
+	}
+}
+
+func (s *satSolver) value(l literal) int8 {
+	val := s.assigns[l.variable()]
+
+	if l.negated() {
+		return -val
+	}
+
+	return val
+}
+
+func (s *satSolver) decisionLevel() int {
+	return len(s.trailLim)
+}
+
+// Adds a clause before solving starts. Duplicate literals are removed and
+// clauses that are always true are dropped.
+func (s *satSolver) addClause(lits ...literal) {
+	if s.unsat {
+		return
+	}
+
+	var clause []literal
+	has := make(map[literal]bool)
+
+	for _, l := range lits {
+		s.grow(l.variable() + 1)
+
+		if has[l.not()] {
+			return
+		} else if !has[l] {
+			has[l] = true
+			clause = append(clause, l)
+		}
+	}
+
+	switch len(clause) {
+	case 0:
+		s.unsat = true
+
+	case 1:
+		if s.value(clause[0]) == assignedF {
+			s.unsat = true
+		} else if s.value(clause[0]) == unassigned {
+			s.enqueue(clause[0], -1)
+		}
+
+	default:
+		s.attach(clause)
+	}
+}
+
+func (s *satSolver) attach(clause []literal) int {
+	ci := len(s.clauses)
+	s.clauses = append(s.clauses, clause)
+	s.watches[clause[0]] = append(s.watches[clause[0]], ci)
+	s.watches[clause[1]] = append(s.watches[clause[1]], ci)
+	return ci
+}
+
+func (s *satSolver) enqueue(l literal, reason int) {
+	v := l.variable()
+	s.assigns[v] = assignedT
+
+	if l.negated() {
+		s.assigns[v] = assignedF
+	}
+
+	s.level[v] = s.decisionLevel()
+	s.reason[v] = reason
+	s.trail = append(s.trail, l)
+}
+
+// Assigns every literal that is implied by the current assignment. Returns
+// the clause that is false when there is a conflict, or -1. The literal a
+// clause implies is always its first one.
+func (s *satSolver) propagate() int {
+	for s.qhead < len(s.trail) {
+		falseLit := s.trail[s.qhead].not()
+		s.qhead++
+
+		ws := s.watches[falseLit]
+		kept := ws[:0]
+
+		for i := 0; i < len(ws); i++ {
+			ci := ws[i]
+			c := s.clauses[ci]
+
+			if c[0] == falseLit {
+				c[0], c[1] = c[1], c[0]
+			}
+
+			if s.value(c[0]) == assignedT {
+				kept = append(kept, ci)
+				continue
+			}
+
+			moved := false
+
+			for k := 2; k < len(c); k++ {
+				if s.value(c[k]) != assignedF {
+					c[1], c[k] = c[k], c[1]
+					s.watches[c[1]] = append(s.watches[c[1]], ci)
+					moved = true
+					break
+				}
+			}
+
+			if moved {
+				continue
+			}
+
+			kept = append(kept, ci)
+
+			if s.value(c[0]) == assignedF {
+				kept = append(kept, ws[i+1:]...)
+				s.watches[falseLit] = kept
+				s.qhead = len(s.trail)
+				return ci
+			}
+
+			s.enqueue(c[0], ci)
+		}
+
+		s.watches[falseLit] = kept
+	}
+
+	return -1
+}
+
+// Learns a clause from a conflict by following the reasons for the literals
+// in it until there's a single literal left from the current decision level.
+// Returns the clause, with that literal first, and the level to jump back to.
+func (s *satSolver) analyze(confl int) ([]literal, int) {
+	learnt := []literal{0}
+	pending := 0
+	idx := len(s.trail) - 1
+	var p literal = -1
+
+	for {
+		c := s.clauses[confl]
+		start := 0
+
+		if p != -1 {
+			start = 1
+		}
+
+		for _, q := range c[start:] {
+			v := q.variable()
+
+			if s.seen[v] || s.level[v] == 0 {
+				continue
+			}
+
+			s.seen[v] = true
+			s.bump(v)
+
+			if s.level[v] >= s.decisionLevel() {
+				pending++
+			} else {
+				learnt = append(learnt, q)
+			}
+		}
+
+		for !s.seen[s.trail[idx].variable()] {
+			idx--
+		}
+
+		p = s.trail[idx]
+		idx--
+		confl = s.reason[p.variable()]
+		s.seen[p.variable()] = false
+		pending--
+
+		if pending == 0 {
+			break
+		}
+	}
+
+	learnt[0] = p.not()
+	back := 0
+
+	for i := 1; i < len(learnt); i++ {
+		s.seen[learnt[i].variable()] = false
+
+		if lvl := s.level[learnt[i].variable()]; lvl > back {
+			back = lvl
+			learnt[1], learnt[i] = learnt[i], learnt[1]
+		}
+	}
+
+	return learnt, back
+}
+
+func (s *satSolver) bump(v int) {
+	s.activity[v] += s.varInc
+
+	if s.activity[v] > 1e100 {
+		for i := range s.activity {
+			s.activity[i] *= 1e-100
+		}
+
+		s.varInc *= 1e-100
+	}
+}
+
+// Undoes every assignment made after the given decision level.
+func (s *satSolver) cancelUntil(level int) {
+	if s.decisionLevel() <= level {
+		return
+	}
+
+	for i := len(s.trail) - 1; i >= s.trailLim[level]; i-- {
+		v := s.trail[i].variable()
+		s.phase[v] = s.assigns[v] == assignedT
+		s.assigns[v] = unassigned
+		s.reason[v] = -1
+	}
+
+	s.trail = s.trail[:s.trailLim[level]]
+	s.trailLim = s.trailLim[:level]
+	s.qhead = len(s.trail)
+}
+
+// Picks the unassigned variable with the highest activity, or -1 when every
+// variable is assigned.
+func (s *satSolver) pick() int {
+	best := -1
+
+	for v, val := range s.assigns {
+		if val == unassigned && (best == -1 || s.activity[v] > s.activity[best]) {
+			best = v
+		}
+	}
+
+	return best
+}
+
+// Returns the nth number in the Luby sequence: 1, 1, 2, 1, 1, 2, 4, 1, ...
+func luby(n int) int {
+	size, seq := 1, 0
+
+	for size < n+1 {
+		seq++
+		size = 2*size + 1
+	}
+
+	for size-1 != n {
+		size = (size - 1) >> 1
+		seq--
+		n = n % size
+	}
+
+	return 1 << seq
+}
+
+// Searches for an assignment that makes every clause true. Returns the value
+// of every variable when there is one.
+func (s *satSolver) solve() ([]bool, bool) {
+	if s.unsat || s.propagate() != -1 {
+		return nil, false
+	}
+
+	restarts, conflicts := 0, 0
+	limit := restartUnit * luby(restarts)
+
+	for {
+		if confl := s.propagate(); confl != -1 {
+			if s.decisionLevel() == 0 {
+				return nil, false
+			}
+
+			learnt, back := s.analyze(confl)
+			s.cancelUntil(back)
+
+			if len(learnt) == 1 {
+				s.enqueue(learnt[0], -1)
+			} else {
+				s.enqueue(learnt[0], s.attach(learnt))
+			}
+
+			s.varInc /= 0.95
+			conflicts++
+
+			if conflicts >= limit {
+				s.cancelUntil(0)
+				restarts++
+				conflicts = 0
+				limit = restartUnit * luby(restarts)
+			}
+
+			continue
+		}
+
+		v := s.pick()
+
+		if v == -1 {
+			model := make([]bool, len(s.assigns))
+
+			for i, val := range s.assigns {
+				model[i] = val == assignedT
+			}
+
+			return model, true
+		}
+
+		s.trailLim = append(s.trailLim, len(s.trail))
+
+		if s.phase[v] {
+			s.enqueue(posLit(v), -1)
+		} else {
+			s.enqueue(negLit(v), -1)
+		}
+	}
+}
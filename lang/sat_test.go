@@ -0,0 +1,206 @@
+package lang
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestSatSolver(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+
+	for n := 0; n < 200; n++ {
+		vars := 3 + r.Intn(10)
+		var clauses [][]literal
+
+		for i := 0; i < vars*4; i++ {
+			var clause []literal
+
+			for j := 0; j < 3; j++ {
+				l := posLit(r.Intn(vars))
+
+				if r.Intn(2) == 0 {
+					l = l.not()
+				}
+
+				clause = append(clause, l)
+			}
+
+			clauses = append(clauses, clause)
+		}
+
+		s := newSatSolver(vars)
+
+		for _, clause := range clauses {
+			s.addClause(clause...)
+		}
+
+		model, ok := s.solve()
+		satisfies := func(model []bool) bool {
+			for _, clause := range clauses {
+				sat := false
+
+				for _, l := range clause {
+					sat = sat || model[l.variable()] != l.negated()
+				}
+
+				if !sat {
+					return false
+				}
+			}
+
+			return true
+		}
+
+		expected := false
+
+		for row := 0; row < 1<<vars && !expected; row++ {
+			try := make([]bool, vars)
+
+			for v := range try {
+				try[v] = row&(1<<v) != 0
+			}
+
+			expected = satisfies(try)
+		}
+
+		if ok != expected {
+			t.Errorf("solve() = %v, expected %v for %v", ok, expected, clauses)
+		} else if ok && !satisfies(model) {
+			t.Errorf("solve() returned %v, which does not satisfy %v", model, clauses)
+		}
+	}
+}
+
+// Five pigeons do not fit in four holes, which takes a fair amount of
+// backtracking to find out.
+func TestSatSolverPigeonhole(t *testing.T) {
+	pigeons, holes := 5, 4
+	s := newSatSolver(pigeons * holes)
+	in := func(p, h int) literal { return posLit(p*holes + h) }
+
+	for p := 0; p < pigeons; p++ {
+		var clause []literal
+
+		for h := 0; h < holes; h++ {
+			clause = append(clause, in(p, h))
+		}
+
+		s.addClause(clause...)
+	}
+
+	for h := 0; h < holes; h++ {
+		for p := 0; p < pigeons; p++ {
+			for q := p + 1; q < pigeons; q++ {
+				s.addClause(in(p, h).not(), in(q, h).not())
+			}
+		}
+	}
+
+	if _, ok := s.solve(); ok {
+		t.Error("expected pigeonhole problem to be unsatisfiable")
+	}
+}
+
+func TestSat(t *testing.T) {
+	rt := newTestRuntime(t,
+		"import \"std/adders.bool\"",
+		"t is 1",
+		"both is a ∧ b",
+	)
+
+	tests := []struct {
+		src string
+		sat bool
+		exp string
+	}{
+		{"a ∧ ¬b", true, "a = true, b = false"},
+		{"a ∧ ¬a", false, ""},
+		{"t", true, ""},
+		{"¬t", false, ""},
+		{"both ∧ ¬c", true, "a = true, b = true, c = false"},
+		{"[a, b] = [1, 0]", true, "a = true, b = false"},
+		{"a > b ∨ a < b", true, "a = false, b = true"},
+		{"(a → b) ∧ (b → c) ∧ a ∧ ¬c", false, ""},
+		{"x(2) ∧ ¬x(0) ∧ x(1)", true, "x = Seq[3]{0, 1, 1}"},
+		{"adders.Add4(x, [0, 0, 0, 1]) = [1, 1, 1, 1]", true, "x = Seq[4]{1, 1, 1, 0}"},
+		{"adders.Add4(x, x) = [0, 0, 0, 1]", false, ""},
+	}
+
+	for _, test := range tests {
+		a, ok, err := rt.Sat(test.src)
+
+		if err != nil {
+			t.Errorf("Sat(%q) error = %v", test.src, err)
+		} else if ok != test.sat {
+			t.Errorf("Sat(%q) = %v, expected %v", test.src, ok, test.sat)
+		} else if ok && a.String() != test.exp {
+			t.Errorf("Sat(%q) = %s, expected %s", test.src, a, test.exp)
+		}
+	}
+
+	errTests := []struct {
+		src string
+		err string
+	}{
+		{"[a, b]", "Expecting a single bit but the expression returns a sequence of 2 bits."},
+		{"reg(a)", "Cannot turn `reg` into a circuit, registers depend on the clock."},
+		{"x(i)", "Expecting a constant index when accessing `x`."},
+		{"[a] ∧ b", "Type error, `and` expects single bits but got a sequence instead."},
+		{"a = [b]", "Type error, `eq` expects both arguments to be of the same type."},
+		{"2", "Cannot turn `2` into a circuit, numbers are only used to access items in a sequence."},
+		{"adders.Add4(a)", "Arity error, `adders.Add4` expects 2 arguments but got 1 instead."},
+		{"a is b", "Expecting an expression."},
+	}
+
+	for _, test := range errTests {
+		_, _, err := rt.Sat(test.src)
+
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("Sat(%q) error = %v, expected %q", test.src, err, test.err)
+		}
+	}
+}
+
+func TestTautContra(t *testing.T) {
+	rt := NewRuntime()
+
+	tests := []struct {
+		src    string
+		taut   bool
+		contra bool
+		exp    string
+	}{
+		{"¬(a ∧ b) ≡ (¬a ∨ ¬b)", true, false, ""},
+		{"¬(a ∧ b) ≡ (¬a ∧ ¬b)", false, false, "a = false, b = true"},
+		{"a ∧ ¬a", false, true, ""},
+		{"a ∨ ¬a", true, false, ""},
+		{"a ≥ b ∨ a ≤ b", true, false, ""},
+		{"([a, b] = [b, a]) → (a ≡ b)", true, false, ""},
+	}
+
+	for _, test := range tests {
+		a, taut, err := rt.Taut(test.src)
+
+		if err != nil {
+			t.Errorf("Taut(%q) error = %v", test.src, err)
+			continue
+		} else if taut != test.taut {
+			t.Errorf("Taut(%q) = %v, expected %v", test.src, taut, test.taut)
+		} else if !taut && test.exp != "" && a.String() != test.exp {
+			t.Errorf("Taut(%q) counterexample = %s, expected %s", test.src, a, test.exp)
+		}
+
+		_, contra, err := rt.Contra(test.src)
+
+		if err != nil {
+			t.Errorf("Contra(%q) error = %v", test.src, err)
+		} else if contra != test.contra {
+			t.Errorf("Contra(%q) = %v, expected %v", test.src, contra, test.contra)
+		}
+	}
+
+	if _, ok, err := rt.Taut("a is b"); ok || err == nil {
+		t.Errorf("Taut(%q) = %v, %v, expected an error", "a is b", ok, err)
+	}
+}
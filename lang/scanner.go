@@ -1,6 +1,9 @@
-package main
+package lang
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+)
 
 type tokenId string
 type tokenFn func(rune) bool
@@ -8,7 +11,7 @@ type tokenFn func(rune) bool
 type token struct {
 	id     tokenId
 	lexeme string
-	pos    int
+	span   span
 	err    error
 }
 
@@ -27,6 +30,7 @@ const (
 	geTok       tokenId = "ge"
 	gtTok       tokenId = "gt"
 	identTok    tokenId = "id"
+	importTok   tokenId = "import"
 	invldTok    tokenId = "invalid"
 	leTok       tokenId = "le"
 	ltTok       tokenId = "lt"
@@ -36,6 +40,7 @@ const (
 	obrakTok    tokenId = "obrak"
 	oparenTok   tokenId = "oparen"
 	orTok       tokenId = "or"
+	strTok      tokenId = "str"
 	trueTok     tokenId = "true"
 	xorTok      tokenId = "xor"
 
@@ -58,7 +63,10 @@ const (
 	oparenRn   = rune('(')
 	orAsciiRn  = rune('v')
 	orRn       = rune('∨')
+	quoteRn    = rune('"')
 	spaceRn    = rune(' ')
+	tabRn      = rune('\t')
+	crRn       = rune('\r')
 	xorAsciiRn = rune('*')
 	xorRn      = rune('⊕')
 
@@ -90,10 +98,11 @@ var (
 	}
 
 	keywordDict = map[string]tokenId{
-		"and":   bindContTok,
-		"gate":  gateTok,
-		"is":    bindTok,
-		"where": bindContTok,
+		"and":    bindContTok,
+		"gate":   gateTok,
+		"import": importTok,
+		"is":     bindTok,
+		"where":  bindContTok,
 	}
 
 	boolDict = map[string]tokenId{
@@ -166,6 +175,12 @@ func (t token) String() string {
 	case numTok:
 		str = fmt.Sprintf("NUM(%s)", t.lexeme)
 
+	case strTok:
+		str = fmt.Sprintf("STR(%s)", t.lexeme)
+
+	case importTok:
+		str = "IMPORT"
+
 	case bindContTok:
 		str = "WHERE"
 
@@ -188,19 +203,33 @@ func (t token) String() string {
 	return fmt.Sprintf("%s", str)
 }
 
+// Scans a piece of source code that does not come from a file, like a line
+// entered in the repl.
 func scan(raw string) []token {
+	return scanSource(newSource("", raw))
+}
+
+func scanSource(src *source) []token {
 	var tokens []token
 
-	runes := []rune(raw)
+	runes := src.runes
 	max := len(runes)
 	i := 0
+	line := 1
+	lineStart := 0
 
 	add := func(id tokenId, lexeme string, err error) {
 		tokens = append(tokens, token{
 			id:     id,
 			lexeme: lexeme,
-			pos:    i,
-			err:    err,
+			span: span{
+				src:    src,
+				offset: i,
+				end:    i + len([]rune(lexeme)),
+				line:   line,
+				column: i - lineStart + 1,
+			},
+			err: err,
 		})
 	}
 
@@ -212,7 +241,11 @@ func scan(raw string) []token {
 			n = runes[i+1]
 		}
 
-		if isWhitespace(r) {
+		if r == nlRn {
+			add(eolTok, "\n", nil)
+			line++
+			lineStart = i + 1
+		} else if isWhitespace(r) {
 			continue
 		} else if isOp(r) && ((r == orAsciiRn && n == rune(' ')) || r != orAsciiRn) {
 			add(getOpToken(r), string(r), nil)
@@ -226,6 +259,19 @@ func scan(raw string) []token {
 			add(cbrakTok, "]", nil)
 		} else if r == commaRn {
 			add(commaTok, ",", nil)
+		} else if r == quoteRn {
+			// Strings can't span multiple lines, and there are no escape
+			// sequences since they are only used for import paths.
+			str := string(r) + string(readWhile(runes, i+1, not(is(quoteRn, nlRn))))
+
+			if i+len([]rune(str)) < max && runes[i+len([]rune(str))] == quoteRn {
+				str += string(quoteRn)
+				add(strTok, str, nil)
+			} else {
+				add(errTok, str, errors.New("Unterminated string."))
+			}
+
+			i += len([]rune(str)) - 1
 		} else if isDigit(r) {
 			word := readWhile(runes, i, isDigit)
 			str := string(word)
@@ -258,15 +304,6 @@ func scan(raw string) []token {
 	return tokens
 }
 
-func cloneToken(tok token) token {
-	return token{
-		id:     tok.id,
-		lexeme: tok.lexeme,
-		pos:    tok.pos,
-		err:    tok.err,
-	}
-}
-
 func getOpToken(r rune) tokenId {
 	tok, ok := tokenDict[r]
 
@@ -329,6 +366,8 @@ func isOp(r rune) bool {
 
 func isWhitespace(r rune) bool {
 	return r == spaceRn ||
+		r == tabRn ||
+		r == crRn ||
 		r == nlRn
 }
 
@@ -342,6 +381,7 @@ func isIdentLike(r rune) bool {
 		r != cparenRn &&
 		r != obrakRn &&
 		r != cbrakRn &&
+		r != quoteRn &&
 		!isWhitespace(r) &&
 		!isOp(r))
 }
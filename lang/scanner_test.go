@@ -0,0 +1,153 @@
+package lang
+
+import "testing"
+
+func tokenIds(tokens []token) []tokenId {
+	var ids []tokenId
+
+	for _, tok := range tokens {
+		ids = append(ids, tok.id)
+	}
+
+	return ids
+}
+
+func equalIds(a, b []tokenId) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func TestScanOperators(t *testing.T) {
+	for r, id := range tokenDict {
+		src := "a " + string(r) + " b"
+		got := tokenIds(scan(src))
+		exp := []tokenId{identTok, id, identTok}
+
+		if !equalIds(got, exp) {
+			t.Errorf("scan(%q) = %v, expected %v", src, got, exp)
+		}
+	}
+}
+
+func TestScanWords(t *testing.T) {
+	words := make(map[string]tokenId)
+
+	for word, id := range keywordDict {
+		words[word] = id
+	}
+
+	for word, id := range opDict {
+		words[word] = id
+	}
+
+	for word, id := range boolDict {
+		words[word] = id
+	}
+
+	for word, id := range words {
+		got := tokenIds(scan(word))
+		exp := []tokenId{id}
+
+		if !equalIds(got, exp) {
+			t.Errorf("scan(%q) = %v, expected %v", word, got, exp)
+		}
+	}
+}
+
+func TestScan(t *testing.T) {
+	tests := []struct {
+		src string
+		exp []tokenId
+	}{
+		{"", nil},
+		{"   \t ", nil},
+		{"v", []tokenId{identTok}},
+		{"vx", []tokenId{identTok}},
+		{"xv", []tokenId{identTok}},
+		{"a v b", []tokenId{identTok, orTok, identTok}},
+		{"a v\nb", []tokenId{identTok, identTok, eolTok, identTok}},
+		{"v(1)", []tokenId{identTok, oparenTok, trueTok, cparenTok}},
+		{"10", []tokenId{numTok}},
+		{"01", []tokenId{numTok}},
+		{"true", []tokenId{trueTok}},
+		{"trueish", []tokenId{identTok}},
+		{"¬a", []tokenId{notTok, identTok}},
+		{"not a", []tokenId{notTok, identTok}},
+		{"[a, b]", []tokenId{obrakTok, identTok, commaTok, identTok, cbrakTok}},
+		{"x is y", []tokenId{identTok, bindTok, identTok}},
+		{"where x is y", []tokenId{bindContTok, identTok, bindTok, identTok}},
+		{"and x is y", []tokenId{bindContTok, identTok, bindTok, identTok}},
+		{"gate F (a) = a", []tokenId{gateTok, identTok, oparenTok, identTok,
+			cparenTok, eqTok, identTok}},
+		{"a\r\nb", []tokenId{identTok, eolTok, identTok}},
+		{"import \"lib/alu.bool\"", []tokenId{importTok, strTok}},
+		{"\"a b\" c", []tokenId{strTok, identTok}},
+		{"\"a\nb\"", []tokenId{errTok, eolTok, identTok, errTok}},
+		{"alu.Add8(x)", []tokenId{identTok, oparenTok, identTok, cparenTok}},
+	}
+
+	for _, test := range tests {
+		got := tokenIds(scan(test.src))
+
+		if !equalIds(got, test.exp) {
+			t.Errorf("scan(%q) = %v, expected %v", test.src, got, test.exp)
+		}
+	}
+}
+
+func TestScanSpans(t *testing.T) {
+	tokens := scanSource(newSource("test.bool", "a ∧ bc\n  ¬d"))
+
+	exp := []span{
+		{offset: 0, end: 1, line: 1, column: 1},
+		{offset: 2, end: 3, line: 1, column: 3},
+		{offset: 4, end: 6, line: 1, column: 5},
+		{offset: 6, end: 7, line: 1, column: 7},
+		{offset: 9, end: 10, line: 2, column: 3},
+		{offset: 10, end: 11, line: 2, column: 4},
+	}
+
+	if len(tokens) != len(exp) {
+		t.Fatalf("expected %d tokens but got %d", len(exp), len(tokens))
+	}
+
+	for i, tok := range tokens {
+		got := tok.span
+
+		if got.src == nil || got.src.name != "test.bool" {
+			t.Errorf("token %d: expected span to point to test.bool", i)
+		}
+
+		got.src = nil
+
+		if got != exp[i] {
+			t.Errorf("token %d (%s): got %+v, expected %+v", i, tok, got, exp[i])
+		}
+	}
+}
+
+func FuzzScan(f *testing.F) {
+	f.Add("x is y ∧ ¬z")
+	f.Add("gate Xor (x, y) = (x ∨ y) ∧ ¬(x ∧ y)")
+	f.Add("Add8([0, 0, 0, 0, 0, 0, 1, 1], x)")
+	f.Add("a v b\nv")
+
+	f.Fuzz(func(t *testing.T, src string) {
+		max := len([]rune(src))
+
+		for _, tok := range scan(src) {
+			if tok.span.offset < 0 || tok.span.end > max || tok.span.offset >= tok.span.end {
+				t.Errorf("token %s has an invalid span %+v", tok, tok.span)
+			}
+		}
+	})
+}
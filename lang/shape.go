@@ -0,0 +1,187 @@
+package lang
+
+import "strconv"
+
+// The shape of a value in a circuit, which is either a single bit or a
+// sequence of other shapes. Sequences are flattened into bit vectors with
+// their first item in the most significant bits.
+type shape struct {
+	list  bool
+	items []shape
+}
+
+var bit = shape{}
+
+func (s shape) width() int {
+	if !s.list {
+		return 1
+	}
+
+	w := 0
+
+	for _, item := range s.items {
+		w += item.width()
+	}
+
+	return w
+}
+
+// Returns the position of the first bit of the nth item.
+func (s shape) offset(n int) int {
+	off := 0
+
+	for _, item := range s.items[:n] {
+		off += item.width()
+	}
+
+	return off
+}
+
+// Combines what is known about a value from two places it is used in.
+func mergeShapes(a, b shape) shape {
+	if !a.list {
+		return b
+	} else if !b.list {
+		return a
+	}
+
+	merged := shape{list: true}
+
+	for i := 0; i < len(a.items) || i < len(b.items); i++ {
+		switch {
+		case i >= len(a.items):
+			merged.items = append(merged.items, b.items[i])
+		case i >= len(b.items):
+			merged.items = append(merged.items, a.items[i])
+		default:
+			merged.items = append(merged.items, mergeShapes(a.items[i], b.items[i]))
+		}
+	}
+
+	return merged
+}
+
+// Works out the shape of gates' arguments from how they are used: an argument
+// that is indexed is a sequence with at least that many items, and an
+// argument passed to another gate has the shape that gate expects. Arguments
+// that are neither are single bits.
+type shapeInference struct {
+	cache    map[Expr][]shape
+	visiting map[Expr]bool
+}
+
+func newShapeInference() *shapeInference {
+	return &shapeInference{
+		cache:    make(map[Expr][]shape),
+		visiting: make(map[Expr]bool),
+	}
+}
+
+// Returns the shape of every argument of a gate. Gates that call themselves
+// only know as much about their arguments as the calls before the recursive
+// one tell them.
+func (si *shapeInference) params(g gate) []shape {
+	if params, ok := si.cache[g.body]; ok {
+		return params
+	}
+
+	params := make([]shape, len(g.args))
+
+	if si.visiting[g.body] {
+		return params
+	}
+
+	si.visiting[g.body] = true
+	defer delete(si.visiting, g.body)
+
+	args := make(map[string]int)
+
+	for i, arg := range g.args {
+		args[arg.lexeme] = i
+	}
+
+	var infer func(e Expr)
+
+	infer = func(e Expr) {
+		switch e := e.(type) {
+		case *BinaryExpr:
+			infer(e.lhs)
+			infer(e.rhs)
+
+		case *UnaryExpr:
+			infer(e.rhs)
+
+		case *GroupExpr:
+			infer(e.inner)
+
+		case *SeqExpr:
+			for _, item := range e.items {
+				infer(item)
+			}
+
+		case *CallExpr:
+			for _, arg := range e.args {
+				infer(arg)
+			}
+
+			name := e.callee.lexeme
+
+			if callee, ok := g.env.getGate(name); ok {
+				expected := si.params(callee)
+
+				for i, arg := range e.args {
+					if id, ok := unwrapGroup(arg).(*IdentExpr); ok && i < len(expected) {
+						if n, isArg := args[id.name.lexeme]; isArg {
+							params[n] = mergeShapes(params[n], expected[i])
+						}
+					}
+				}
+			} else if n, isArg := args[name]; isArg && len(e.args) == 1 {
+				if idx, ok := constantIndex(e.args[0]); ok {
+					params[n] = mergeShapes(params[n], shape{
+						list:  true,
+						items: make([]shape, idx+1),
+					})
+				}
+			}
+		}
+	}
+
+	infer(g.body)
+
+	for _, local := range g.locals {
+		infer(local.value)
+	}
+
+	si.cache[g.body] = params
+	return params
+}
+
+func unwrapGroup(e Expr) Expr {
+	for {
+		group, ok := e.(*GroupExpr)
+
+		if !ok {
+			return e
+		}
+
+		e = group.inner
+	}
+}
+
+func constantIndex(e Expr) (int, bool) {
+	switch e := unwrapGroup(e).(type) {
+	case *LiteralExpr:
+		if e.value {
+			return 1, true
+		}
+
+		return 0, true
+
+	case *NumberExpr:
+		n, err := strconv.Atoi(e.tok.lexeme)
+		return n, err == nil
+	}
+
+	return 0, false
+}
@@ -0,0 +1,503 @@
+package lang
+
+import (
+	"fmt"
+	"math/bits"
+	"sort"
+	"strings"
+)
+
+// Functions with at most this many inputs are minimized exactly with the
+// Quine-McCluskey method. Larger ones use a heuristic in the style of
+// Espresso, which is fast but not always minimal.
+const maxExactInputs = 6
+
+// A product of literals, or a cube in the space of inputs. Inputs in mask
+// appear in the product, and their bits in value tell whether they appear as
+// is or negated. The first input is the most significant bit, just like in a
+// truth table, so row r of a table is covered when r&mask == value.
+type cube struct {
+	mask, value uint32
+}
+
+// A function of a number of inputs given by the rows of its truth table that
+// are true. Rows in dc are the ones whose value does not matter.
+type truthFunc struct {
+	inputs int
+	on, dc []bool
+}
+
+// A sum of products over named inputs.
+type sop struct {
+	names []string
+	cubes []cube
+}
+
+func (c cube) covers(row uint32) bool {
+	return row&c.mask == c.value
+}
+
+func (c cube) literals() int {
+	return bits.OnesCount32(c.mask)
+}
+
+// Calls fn with every row the cube covers.
+func (c cube) rows(inputs int, fn func(row uint32)) {
+	free := (uint32(1)<<inputs - 1) &^ c.mask
+
+	for s := free; ; s = (s - 1) & free {
+		fn(c.value | s)
+
+		if s == 0 {
+			return
+		}
+	}
+}
+
+// Returns the smallest sum of products equivalent to src, which is the name
+// of a gate or an expression, written in Bool. Gates that return sequences
+// are simplified one item at a time.
+func (rt *Runtime) Simplify(src string) (string, error) {
+	c, w, errs := circuitFor(src, &rt.env)
+
+	if len(errs) > 0 {
+		return "", &Error{Phase: EvalPhase, Errors: errs}
+	} else if len(c.names) > maxTableInputs {
+		return "", &Error{Phase: EvalPhase, Errors: []error{fmt.Errorf(
+			"Too many inputs to simplify, max is %d but got %d.",
+			maxTableInputs, len(c.names))}}
+	}
+
+	return simplifyWire(c, w, c.truthFuncs(w)), nil
+}
+
+func simplifyWire(c *circuit, w wire, fns map[int]truthFunc) string {
+	if !w.list {
+		return sop{names: c.names, cubes: minimize(fns[w.node])}.String()
+	}
+
+	var items []string
+
+	for _, item := range w.items {
+		items = append(items, simplifyWire(c, item, fns))
+	}
+
+	return "[" + strings.Join(items, ", ") + "]"
+}
+
+// Builds the circuit for src, which is the name of a gate or an expression.
+// The inputs of a gate are its arguments, with an input for every bit of the
+// arguments it uses as sequences, and the inputs of an expression are its
+// free identifiers.
+func circuitFor(src string, env *environment) (*circuit, wire, []error) {
+	src = strings.TrimSpace(src)
+	toks := scan(src)
+	c := newCircuit()
+
+	if len(toks) == 1 && toks[0].id == identTok {
+		name := toks[0].lexeme
+
+		if g, ok := env.getGate(name); ok {
+			var inputs []wire
+
+			for i, param := range newShapeInference().params(g) {
+				inputs = append(inputs, shapeWire(c, param, g.args[i].lexeme))
+			}
+
+			w, errs := (&blaster{c: c}).call(name, g, inputs, 1)
+			return c, w, errs
+		}
+	}
+
+	expr, errs := parseExpr(src)
+
+	if len(errs) > 0 {
+		return nil, wire{}, errs
+	}
+
+	_, inputs := freeInputs(c, expr, env)
+	w, errs := blast(c, expr, env, inputs)
+	return c, w, errs
+}
+
+// Returns the truth table of every bit in a wire, keyed by node.
+func (c *circuit) truthFuncs(w wire) map[int]truthFunc {
+	outputs := w.bits()
+
+	n := len(c.names)
+	fns := make(map[int]truthFunc)
+
+	for _, out := range outputs {
+		fns[out] = truthFunc{inputs: n, on: make([]bool, 1<<n), dc: make([]bool, 1<<n)}
+	}
+
+	inputs := make([]bool, n)
+
+	for row := 0; row < 1<<n; row++ {
+		for i := range inputs {
+			inputs[i] = row&(1<<(n-i-1)) != 0
+		}
+
+		vals := c.eval(inputs)
+
+		for _, out := range outputs {
+			fns[out].on[row] = vals[out]
+		}
+	}
+
+	return fns
+}
+
+// Returns a smallest sum of products for a function, which is exact for
+// functions with few inputs.
+func minimize(f truthFunc) []cube {
+	var cubes []cube
+
+	if f.inputs <= maxExactInputs {
+		cubes = quineMcCluskey(f)
+	} else {
+		cubes = espresso(f)
+	}
+
+	sortCubes(cubes, f.inputs)
+	return cubes
+}
+
+// Finds every prime implicant by merging cubes that differ in a single
+// input, and picks the fewest primes that cover the function.
+func quineMcCluskey(f truthFunc) []cube {
+	full := uint32(1)<<f.inputs - 1
+	curr := make(map[cube]bool)
+
+	for row := range f.on {
+		if f.on[row] || f.dc[row] {
+			curr[cube{mask: full, value: uint32(row)}] = true
+		}
+	}
+
+	var primes []cube
+
+	for len(curr) > 0 {
+		next := make(map[cube]bool)
+		merged := make(map[cube]bool)
+
+		for a := range curr {
+			for m := a.mask; m != 0; m &= m - 1 {
+				bit := m & -m
+				b := cube{mask: a.mask, value: a.value ^ bit}
+
+				if curr[b] {
+					next[cube{mask: a.mask &^ bit, value: a.value &^ bit}] = true
+					merged[a] = true
+				}
+			}
+		}
+
+		for a := range curr {
+			if !merged[a] {
+				primes = append(primes, a)
+			}
+		}
+
+		curr = next
+	}
+
+	sortCubes(primes, f.inputs)
+
+	var on []uint32
+
+	for row, val := range f.on {
+		if val {
+			on = append(on, uint32(row))
+		}
+	}
+
+	return exactCover(primes, on)
+}
+
+// Picks the fewest cubes, and then the fewest literals, that cover every row
+// in on. Rows covered by a single cube pick that cube, and the rest are
+// covered by a branch and bound search.
+func exactCover(cubes []cube, on []uint32) []cube {
+	var best []cube
+	bestCost := [2]int{len(on) + 1, 0}
+
+	var search func(chosen []cube, uncovered []uint32, literals int)
+
+	search = func(chosen []cube, uncovered []uint32, literals int) {
+		if len(uncovered) == 0 {
+			if cost := [2]int{len(chosen), literals}; cost[0] < bestCost[0] ||
+				(cost[0] == bestCost[0] && cost[1] < bestCost[1]) {
+				best = append([]cube{}, chosen...)
+				bestCost = cost
+			}
+
+			return
+		} else if len(chosen)+1 > bestCost[0] {
+			return
+		}
+
+		// Branch on the row with the fewest cubes covering it, which is
+		// the one that leaves the fewest choices.
+		var options []cube
+
+		for _, row := range uncovered {
+			var covering []cube
+
+			for _, c := range cubes {
+				if c.covers(row) {
+					covering = append(covering, c)
+				}
+			}
+
+			if options == nil || len(covering) < len(options) {
+				options = covering
+			}
+		}
+
+		for _, c := range options {
+			var rest []uint32
+
+			for _, row := range uncovered {
+				if !c.covers(row) {
+					rest = append(rest, row)
+				}
+			}
+
+			search(append(chosen, c), rest, literals+c.literals())
+		}
+	}
+
+	search(nil, on, 0)
+	return best
+}
+
+// Improves a cover made up of one cube per row by repeatedly expanding every
+// cube as far as it can go without covering a row that is false, dropping
+// cubes that other cubes already cover, and reducing every cube to the rows
+// only it covers so that the next expansion can go in a different direction.
+func espresso(f truthFunc) []cube {
+	full := uint32(1)<<f.inputs - 1
+	var cover []cube
+
+	for row, val := range f.on {
+		if val {
+			cover = append(cover, cube{mask: full, value: uint32(row)})
+		}
+	}
+
+	var best []cube
+	bestCost := [2]int{len(cover) + 1, 0}
+
+	for {
+		cover = irredundant(expand(cover, f), f)
+		cost := [2]int{len(cover), 0}
+
+		for _, c := range cover {
+			cost[1] += c.literals()
+		}
+
+		if cost[0] > bestCost[0] || (cost[0] == bestCost[0] && cost[1] >= bestCost[1]) {
+			return best
+		}
+
+		best = append([]cube{}, cover...)
+		bestCost = cost
+		cover = reduce(cover, f)
+	}
+}
+
+// Removes literals from every cube for as long as it only covers rows that
+// are true or don't matter. Cubes that end up inside of an expanded cube are
+// dropped.
+func expand(cover []cube, f truthFunc) []cube {
+	var expanded []cube
+
+	// Larger cubes go first since they are the most likely to swallow the
+	// smaller ones.
+	sort.SliceStable(cover, func(i, j int) bool {
+		return cover[i].literals() < cover[j].literals()
+	})
+
+	for _, c := range cover {
+		inside := false
+
+		for _, e := range expanded {
+			if c.mask&e.mask == e.mask && c.value&e.mask == e.value {
+				inside = true
+				break
+			}
+		}
+
+		if inside {
+			continue
+		}
+
+		for m := c.mask; m != 0; m &= m - 1 {
+			bit := m & -m
+			try := cube{mask: c.mask &^ bit, value: c.value &^ bit}
+			valid := true
+
+			try.rows(f.inputs, func(row uint32) {
+				valid = valid && (f.on[row] || f.dc[row])
+			})
+
+			if valid {
+				c = try
+			}
+		}
+
+		expanded = append(expanded, c)
+	}
+
+	return expanded
+}
+
+// Counts how many cubes cover each row.
+func coverCounts(cover []cube, f truthFunc) []int {
+	counts := make([]int, len(f.on))
+
+	for _, c := range cover {
+		c.rows(f.inputs, func(row uint32) {
+			counts[row]++
+		})
+	}
+
+	return counts
+}
+
+// Drops cubes whose true rows are all covered by other cubes, starting with
+// the ones with the most literals.
+func irredundant(cover []cube, f truthFunc) []cube {
+	counts := coverCounts(cover, f)
+
+	sort.SliceStable(cover, func(i, j int) bool {
+		return cover[i].literals() > cover[j].literals()
+	})
+
+	var kept []cube
+
+	for _, c := range cover {
+		needed := false
+
+		c.rows(f.inputs, func(row uint32) {
+			needed = needed || (f.on[row] && counts[row] == 1)
+		})
+
+		if needed {
+			kept = append(kept, c)
+			continue
+		}
+
+		c.rows(f.inputs, func(row uint32) {
+			counts[row]--
+		})
+	}
+
+	return kept
+}
+
+// Shrinks every cube to the smallest cube around the true rows that no other
+// cube covers.
+func reduce(cover []cube, f truthFunc) []cube {
+	counts := coverCounts(cover, f)
+	full := uint32(1)<<f.inputs - 1
+	var reduced []cube
+
+	for _, c := range cover {
+		var rows []uint32
+
+		c.rows(f.inputs, func(row uint32) {
+			if f.on[row] && counts[row] == 1 {
+				rows = append(rows, row)
+			}
+		})
+
+		if len(rows) == 0 {
+			continue
+		}
+
+		// Inputs that have the same value in every row stay in the cube.
+		same := full
+
+		for _, row := range rows[1:] {
+			same &^= row ^ rows[0]
+		}
+
+		r := cube{mask: same, value: rows[0] & same}
+
+		c.rows(f.inputs, func(row uint32) {
+			if !r.covers(row) {
+				counts[row]--
+			}
+		})
+
+		reduced = append(reduced, r)
+	}
+
+	return reduced
+}
+
+// Sorts cubes by their first input, then their second one, and so on, with
+// inputs that appear as is first, then negated ones, then missing ones.
+func sortCubes(cubes []cube, inputs int) {
+	rank := func(c cube, i int) int {
+		bit := uint32(1) << (inputs - i - 1)
+
+		switch {
+		case c.mask&bit == 0:
+			return 2
+		case c.value&bit != 0:
+			return 0
+		default:
+			return 1
+		}
+	}
+
+	sort.Slice(cubes, func(a, b int) bool {
+		for i := 0; i < inputs; i++ {
+			if ra, rb := rank(cubes[a], i), rank(cubes[b], i); ra != rb {
+				return ra < rb
+			}
+		}
+
+		return false
+	})
+}
+
+// Writes the sum of products in Bool, grouping products of more than one
+// literal when there is more than one product.
+func (s sop) String() string {
+	if len(s.cubes) == 0 {
+		return "false"
+	}
+
+	var terms []string
+
+	for _, c := range s.cubes {
+		var lits []string
+
+		for i, name := range s.names {
+			bit := uint32(1) << (len(s.names) - i - 1)
+
+			if c.mask&bit == 0 {
+				continue
+			} else if c.value&bit != 0 {
+				lits = append(lits, name)
+			} else {
+				lits = append(lits, "¬"+name)
+			}
+		}
+
+		switch {
+		case len(lits) == 0:
+			return "true"
+		case len(lits) > 1 && len(s.cubes) > 1:
+			terms = append(terms, "("+strings.Join(lits, " ∧ ")+")")
+		default:
+			terms = append(terms, strings.Join(lits, " ∧ "))
+		}
+	}
+
+	return strings.Join(terms, " ∨ ")
+}
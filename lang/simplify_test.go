@@ -0,0 +1,101 @@
+package lang
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestSimplify(t *testing.T) {
+	rt := newTestRuntime(t,
+		"import \"std/adders.bool\"",
+		"import \"std/mux.bool\"",
+		"gate Pick (x) = (x(0) ∧ x(1)) ∨ (x(0) ∧ ¬x(1))",
+	)
+
+	tests := []struct {
+		src string
+		exp string
+	}{
+		{"(a ∧ b) ∨ (a ∧ ¬b)", "a"},
+		{"a ∨ ¬a", "true"},
+		{"a ∧ ¬a", "false"},
+		{"1", "true"},
+		{"¬(a ∧ b)", "¬a ∨ ¬b"},
+		{"(a → b) ∧ (b → a)", "(a ∧ b) ∨ (¬a ∧ ¬b)"},
+		{"a ∧ b ∨ a ∧ b ∧ c ∨ b ∧ c", "(a ∧ b) ∨ (b ∧ c)"},
+		{"mux.Mux", "(a ∧ ¬s) ∨ (b ∧ s)"},
+		{"adders.HalfAdder", "[(a ∧ ¬b) ∨ (¬a ∧ b), a ∧ b]"},
+		{"Pick", "x(0)"},
+	}
+
+	for _, test := range tests {
+		got, err := rt.Simplify(test.src)
+
+		if err != nil {
+			t.Errorf("Simplify(%q) error = %v", test.src, err)
+		} else if got != test.exp {
+			t.Errorf("Simplify(%q) = %q, expected %q", test.src, got, test.exp)
+		}
+	}
+
+	// Simplified expressions have to be equivalent to the original ones.
+	for _, src := range []string{
+		"(a → b) ∧ (b → c) ∧ (c → d) ∧ (d → e) ∧ (e → f) ∧ (f → g) ∧ (g → a)",
+		"a ⊕ b ⊕ c ⊕ d ⊕ e ⊕ f ⊕ g ⊕ h",
+		"(a ∨ b ∨ ¬c) ∧ (¬a ∨ d ∨ e) ∧ (f ∨ ¬g ∨ h) ∧ (¬b ∨ ¬e ∨ g)",
+	} {
+		got, err := rt.Simplify(src)
+
+		if err != nil {
+			t.Errorf("Simplify(%q) error = %v", src, err)
+		} else if _, ok, err := rt.Taut("(" + src + ") ≡ (" + got + ")"); err != nil || !ok {
+			t.Errorf("Simplify(%q) = %q, which is not equivalent", src, got)
+		}
+	}
+
+	if _, err := rt.Simplify("[a] ∧ b"); err == nil {
+		t.Error("expected Simplify to fail on a type error")
+	}
+}
+
+func TestMinimize(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+
+	for n := 0; n < 100; n++ {
+		inputs := 1 + r.Intn(9)
+		f := truthFunc{inputs: inputs, on: make([]bool, 1<<inputs), dc: make([]bool, 1<<inputs)}
+
+		for row := range f.on {
+			switch r.Intn(5) {
+			case 0, 1:
+				f.on[row] = true
+			case 2:
+				f.dc[row] = true
+			}
+		}
+
+		for _, cubes := range [][]cube{minimize(f), espresso(f)} {
+			covered := make([]bool, len(f.on))
+
+			for _, c := range cubes {
+				c.rows(inputs, func(row uint32) {
+					covered[row] = true
+				})
+			}
+
+			for row := range f.on {
+				if f.on[row] && !covered[row] {
+					t.Fatalf("row %d of %v is not covered by %v", row, f, cubes)
+				} else if covered[row] && !f.on[row] && !f.dc[row] {
+					t.Fatalf("row %d of %v is false but covered by %v", row, f, cubes)
+				}
+			}
+		}
+
+		if inputs <= maxExactInputs {
+			if exact, heuristic := len(quineMcCluskey(f)), len(espresso(f)); exact > heuristic {
+				t.Errorf("exact cover has %d cubes but the heuristic found %d", exact, heuristic)
+			}
+		}
+	}
+}
@@ -0,0 +1,347 @@
+package lang
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Stimulus lines start with the step they run in, e.g. `3: a is 1`. Lines
+// without a step run before the simulation starts.
+var stimulusStep = regexp.MustCompile(`^\s*(\d+)\s*:`)
+
+// A signal recorded during a simulation, along with its value at every step.
+// Values are already formatted for a VCD file, so a boolean is 0 or 1 and a
+// sequence is a binary string. Steps where the signal could not be evaluated
+// are x.
+type signal struct {
+	name   string
+	code   string
+	width  int
+	values []string
+}
+
+// Runs a simulation for the given number of steps and writes the result to
+// out as a Value Change Dump. At every step the statements in the stimulus
+// for that step are executed, every binding in the global scope and the gates
+// it calls are recorded, and the clock advances by one cycle.
+func (rt *Runtime) Simulate(steps int, name, stimulus string, out io.Writer) error {
+	src, lines := parseStimulus(name, stimulus)
+	stmts := parseProgram(scanSource(src))
+	byStep := make(map[int][]statement)
+
+	for _, stmt := range stmts {
+		step := lines[stmt.tokens[0].span.line]
+
+		if step >= steps {
+			return &Error{Phase: ParsePhase, Errors: []error{errorAt(stmt.tokens[0].span,
+				"Step %d is past the end of the simulation.", step)}}
+		}
+
+		byStep[step] = append(byStep[step], stmt)
+	}
+
+	signals := make(map[string]*signal)
+
+	for step := -1; step < steps; step++ {
+		for _, stmt := range byStep[step] {
+			if res := rt.exec(stmt); res.Err != nil {
+				return res.Err
+			}
+		}
+
+		if step < 0 {
+			continue
+		}
+
+		rt.sample(step, signals)
+
+		if err := rt.Step(); err != nil {
+			return err
+		}
+	}
+
+	return writeVCD(out, steps, signals)
+}
+
+// Replaces the step at the start of every line with spaces, so the rest of
+// the stimulus can be parsed as a regular program while keeping every token in
+// its original place. Returns the step of every line, indexed by line number.
+func parseStimulus(name, stimulus string) (*source, map[int]int) {
+	lines := strings.Split(stimulus, "\n")
+	steps := make(map[int]int)
+
+	for i, line := range lines {
+		steps[i+1] = -1
+		loc := stimulusStep.FindStringSubmatchIndex(line)
+
+		if loc == nil {
+			continue
+		}
+
+		steps[i+1], _ = strconv.Atoi(line[loc[2]:loc[3]])
+		lines[i] = strings.Repeat(" ", loc[1]) + line[loc[1]:]
+	}
+
+	return newSource(name, strings.Join(lines, "\n")), steps
+}
+
+// Records the value of every binding in the global scope, along with the
+// output and the locals of every gate those bindings call. A gate's signals
+// are named after the binding that calls it, like `c.lo`, and gates that are
+// only part of a binding's expression add their own name, like `c.Not`.
+func (rt *Runtime) sample(step int, signals map[string]*signal) {
+	for name := range rt.env.bindings {
+		expr, _ := rt.env.getBinding(name)
+		val, errs := evaluate(expr, rt.env)
+		record(step, signals, name, val, errs, rt.env)
+
+		seen := make(map[string]int)
+
+		for _, call := range gateCalls(expr, rt.env) {
+			g, _ := rt.env.getGate(call.callee.lexeme)
+
+			// The binding's own signal is already x when the call is wrong.
+			if len(g.args) != len(call.args) {
+				continue
+			}
+
+			scope := callScope(g, call, rt.env)
+			prefix := name
+
+			if unwrapGroup(expr) != Expr(call) {
+				prefix = name + "." + call.callee.lexeme
+
+				// Gates that are called more than once get a number.
+				if seen[prefix]++; seen[prefix] > 1 {
+					prefix = fmt.Sprintf("%s_%d", prefix, seen[prefix]-1)
+				}
+
+				val, errs := evaluate(g.body, scope)
+				record(step, signals, prefix, val, errs, scope)
+			}
+
+			for _, local := range g.locals {
+				label := local.label.lexeme
+				expr, _ := scope.getBinding(label)
+				val, errs := evaluate(expr, scope)
+				record(step, signals, prefix+"."+label, val, errs, scope)
+			}
+		}
+	}
+}
+
+// Adds the value a signal has at a step, which is x when it could not be
+// evaluated.
+func record(step int, signals map[string]*signal, name string, val value, errs []error, env environment) {
+	sig, ok := signals[name]
+
+	if !ok {
+		sig = &signal{name: name}
+		signals[name] = sig
+	}
+
+	for len(sig.values) < step {
+		sig.values = append(sig.values, "")
+	}
+
+	if len(errs) > 0 {
+		sig.values = append(sig.values, "x")
+		return
+	}
+
+	bits, errs := vcdBits(val, env)
+
+	if len(errs) > 0 {
+		sig.values = append(sig.values, "x")
+		return
+	}
+
+	if len(bits) > sig.width {
+		sig.width = len(bits)
+	}
+
+	sig.values = append(sig.values, bits)
+}
+
+// Finds the calls to gates in an expression, without looking inside of the
+// gates themselves.
+type callCollector struct {
+	env   environment
+	calls []*CallExpr
+}
+
+func gateCalls(e Expr, env environment) []*CallExpr {
+	c := &callCollector{env: env}
+	e.Accept(c)
+	return c.calls
+}
+
+func (c *callCollector) VisitBadExpr(e *BadExpr) {}
+
+func (c *callCollector) VisitBinaryExpr(e *BinaryExpr) {
+	e.lhs.Accept(c)
+	e.rhs.Accept(c)
+}
+
+func (c *callCollector) VisitUnaryExpr(e *UnaryExpr) {
+	e.rhs.Accept(c)
+}
+
+func (c *callCollector) VisitGroupExpr(e *GroupExpr) {
+	e.inner.Accept(c)
+}
+
+func (c *callCollector) VisitCallExpr(e *CallExpr) {
+	if _, ok := c.env.getGate(e.callee.lexeme); ok {
+		c.calls = append(c.calls, e)
+	}
+
+	for _, arg := range e.args {
+		arg.Accept(c)
+	}
+}
+
+func (c *callCollector) VisitIdentExpr(e *IdentExpr) {}
+
+func (c *callCollector) VisitLiteralExpr(e *LiteralExpr) {}
+
+func (c *callCollector) VisitSeqExpr(e *SeqExpr) {
+	for _, item := range e.items {
+		item.Accept(c)
+	}
+}
+
+func (c *callCollector) VisitNumberExpr(e *NumberExpr) {}
+
+// Formats a value as a string of bits. Items in nested sequences are
+// flattened, and numbers are 32 bits wide.
+func vcdBits(v value, env environment) (string, []error) {
+	switch v.getTypeId() {
+	case typeBoolean:
+		if v.boolean.internal {
+			return "1", nil
+		}
+
+		return "0", nil
+
+	case typeNumber:
+		return fmt.Sprintf("%032b", uint32(v.number)), nil
+
+	case typeSequence:
+		var bits strings.Builder
+
+		for _, item := range v.sequence.internal {
+			val, errs := evaluate(item, env)
+
+			if len(errs) > 0 {
+				return "", errs
+			}
+
+			str, errs := vcdBits(val, env)
+
+			if len(errs) > 0 {
+				return "", errs
+			}
+
+			bits.WriteString(str)
+		}
+
+		return bits.String(), nil
+	}
+
+	return "x", nil
+}
+
+// Returns a short identifier code for the nth signal, made up of the
+// printable characters VCD files allow.
+func vcdCode(n int) string {
+	const first, count = '!', '~' - '!' + 1
+	code := string(rune(first + n%count))
+
+	for n /= count; n > 0; n /= count {
+		n--
+		code = string(rune(first+n%count)) + code
+	}
+
+	return code
+}
+
+func writeVCD(out io.Writer, steps int, signals map[string]*signal) error {
+	var names []string
+
+	for name := range signals {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	var buff strings.Builder
+
+	buff.WriteString("$version bool $end\n")
+	buff.WriteString("$timescale 1 ns $end\n")
+	buff.WriteString("$scope module bool $end\n")
+
+	for i, name := range names {
+		sig := signals[name]
+		sig.code = vcdCode(i)
+
+		if sig.width == 0 {
+			sig.width = 1
+		}
+
+		fmt.Fprintf(&buff, "$var wire %d %s %s $end\n", sig.width, sig.code, name)
+	}
+
+	buff.WriteString("$upscope $end\n")
+	buff.WriteString("$enddefinitions $end\n")
+
+	prev := make(map[string]string)
+
+	for step := 0; step < steps; step++ {
+		fmt.Fprintf(&buff, "#%d\n", step)
+
+		if step == 0 {
+			buff.WriteString("$dumpvars\n")
+		}
+
+		for _, name := range names {
+			sig := signals[name]
+			val := "x"
+
+			if step < len(sig.values) && sig.values[step] != "" {
+				val = sig.values[step]
+			}
+
+			if step > 0 && prev[name] == val {
+				continue
+			}
+
+			prev[name] = val
+
+			if sig.width == 1 {
+				fmt.Fprintf(&buff, "%s%s\n", val, sig.code)
+			} else {
+				if val == "x" {
+					val = strings.Repeat("x", sig.width)
+				} else if len(val) < sig.width {
+					val = strings.Repeat("0", sig.width-len(val)) + val
+				}
+
+				fmt.Fprintf(&buff, "b%s %s\n", val, sig.code)
+			}
+		}
+
+		if step == 0 {
+			buff.WriteString("$end\n")
+		}
+	}
+
+	fmt.Fprintf(&buff, "#%d\n", steps)
+
+	_, err := io.WriteString(out, buff.String())
+	return err
+}
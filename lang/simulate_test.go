@@ -0,0 +1,110 @@
+package lang
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSimulate(t *testing.T) {
+	stimulus := strings.Join([]string{
+		"gate Counter2 (en) = [hi, lo]",
+		"  where lo is reg(lo ⊕ en)",
+		"    and hi is reg(hi ⊕ (lo ∧ en))",
+		"gate Not (a) = ¬a",
+		"c is Counter2(en)",
+		"m is Not(en) ∧ Not(Not(en))",
+		"0: en is 0",
+		"2: en is 1",
+		"3: n is 7",
+		"4: en is 0",
+	}, "\n")
+
+	exp := strings.Join([]string{
+		"$version bool $end",
+		"$timescale 1 ns $end",
+		"$scope module bool $end",
+		"$var wire 2 ! c $end",
+		"$var wire 1 \" c.hi $end",
+		"$var wire 1 # c.lo $end",
+		"$var wire 1 $ en $end",
+		"$var wire 1 % m $end",
+		"$var wire 1 & m.Not $end",
+		"$var wire 1 ' m.Not_1 $end",
+		"$var wire 1 ( m.Not_2 $end",
+		"$var wire 32 ) n $end",
+		"$upscope $end",
+		"$enddefinitions $end",
+		"#0",
+		"$dumpvars",
+		"b00 !",
+		"0\"",
+		"0#",
+		"0$",
+		"0%",
+		"1&",
+		"0'",
+		"1(",
+		"bxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxx )",
+		"$end",
+		"#1",
+		"#2",
+		"1$",
+		"0&",
+		"1'",
+		"0(",
+		"#3",
+		"b01 !",
+		"1#",
+		"b00000000000000000000000000000111 )",
+		"#4",
+		"b10 !",
+		"1\"",
+		"0#",
+		"0$",
+		"1&",
+		"0'",
+		"1(",
+		"#5",
+		"",
+	}, "\n")
+
+	var out strings.Builder
+
+	if err := NewRuntime().Simulate(5, "test.stim", stimulus, &out); err != nil {
+		t.Fatal(err)
+	}
+
+	if out.String() != exp {
+		t.Errorf("got:\n%s\nexpected:\n%s", out.String(), exp)
+	}
+}
+
+func TestSimulateErrors(t *testing.T) {
+	tests := []struct {
+		stimulus string
+		err      string
+	}{
+		{"3: a is 1", "Step 3 is past the end of the simulation."},
+		{"0: a is", "Unexpected end of line."},
+		{"x is reg(y)\n1: a is 1", "Undefined identifier `y`"},
+	}
+
+	for _, test := range tests {
+		var out strings.Builder
+		err := NewRuntime().Simulate(2, "test.stim", test.stimulus, &out)
+
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("Simulate(%q) error = %v, expected %q", test.stimulus, err, test.err)
+		}
+	}
+}
+
+func TestVCDCode(t *testing.T) {
+	tests := map[int]string{0: "!", 1: "\"", 93: "~", 94: "!!", 95: "!\"", 94 + 94: "\"!"}
+
+	for n, exp := range tests {
+		if got := vcdCode(n); got != exp {
+			t.Errorf("vcdCode(%d) = %q, expected %q", n, got, exp)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package lang
+
+import (
+	"fmt"
+	"strings"
+)
+
+// A piece of source code, either a file or a line entered in the repl. Spans
+// keep a reference to their source so that errors can show the offending
+// line long after it was scanned.
+type source struct {
+	name  string
+	runes []rune
+}
+
+// A range of runes in a source. Lines and columns are one based and point to
+// the start of the span, offsets are rune offsets from the start of the
+// source and end is just past the last rune in the span.
+type span struct {
+	src    *source
+	offset int
+	end    int
+	line   int
+	column int
+}
+
+// An error that can be traced back to a span of source code.
+type sourceError struct {
+	span span
+	msg  string
+}
+
+func newSource(name, raw string) *source {
+	return &source{name: name, runes: []rune(raw)}
+}
+
+func (e sourceError) Error() string {
+	return e.msg
+}
+
+func errorAt(sp span, format string, args ...interface{}) error {
+	return sourceError{span: sp, msg: fmt.Sprintf(format, args...)}
+}
+
+// Attaches a span to every error that does not already have one. Errors that
+// come from deeper in the tree point to a more specific location, so they are
+// left alone.
+func errorsAt(sp span, errs []error) []error {
+	located := make([]error, len(errs))
+
+	for i, err := range errs {
+		if _, ok := err.(sourceError); ok || sp.src == nil {
+			located[i] = err
+		} else {
+			located[i] = sourceError{span: sp, msg: err.Error()}
+		}
+	}
+
+	return located
+}
+
+// Returns a span that starts at the start of a and ends at the end of b.
+func joinSpans(a, b span) span {
+	if a.src == nil {
+		return b
+	} else if b.src == nil {
+		return a
+	}
+
+	a.end = b.end
+	return a
+}
+
+func (s span) String() string {
+	if s.src == nil {
+		return ""
+	} else if s.src.name == "" {
+		return fmt.Sprintf("%d:%d", s.line, s.column)
+	}
+
+	return fmt.Sprintf("%s:%d:%d", s.src.name, s.line, s.column)
+}
+
+// Returns the line the span starts in, along with a line of carets that
+// underlines the span. Spans that go past the end of the line are only
+// underlined up to the end of it.
+func (s span) underline() (string, string) {
+	if s.src == nil {
+		return "", ""
+	}
+
+	start := s.offset - (s.column - 1)
+	stop := start
+
+	for stop < len(s.src.runes) && s.src.runes[stop] != nlRn {
+		stop++
+	}
+
+	line := strings.TrimRight(string(s.src.runes[start:stop]), "\r")
+	width := s.end - s.offset
+
+	if width < 1 {
+		width = 1
+	} else if s.offset+width > stop+1 {
+		width = stop + 1 - s.offset
+	}
+
+	pad := strings.Map(func(r rune) rune {
+		if r == tabRn {
+			return tabRn
+		}
+
+		return spaceRn
+	}, string(s.src.runes[start:s.offset]))
+
+	return line, pad + strings.Repeat("^", width)
+}
+
+// Formats an error as a list of lines. Errors with a span start with their
+// location, when they come from a named source, and end with the offending
+// line of source code underlined.
+func formatError(err error) []string {
+	serr, ok := err.(sourceError)
+
+	if !ok || serr.span.src == nil {
+		return []string{err.Error()}
+	}
+
+	var lines []string
+
+	if serr.span.src.name != "" {
+		lines = append(lines, fmt.Sprintf("%s: %s", serr.span, serr.msg))
+	} else {
+		lines = append(lines, serr.msg)
+	}
+
+	line, carets := serr.span.underline()
+	return append(lines, "  "+line, "  "+carets)
+}
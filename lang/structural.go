@@ -0,0 +1,668 @@
+package lang
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Verilog's gate primitives, by the operator that joins their inputs and
+// whether the result is negated.
+var structuralPrimitives = map[string]struct {
+	op     tokenId
+	r      rune
+	negate bool
+}{
+	"and":  {andTok, andRn, false},
+	"nand": {andTok, andRn, true},
+	"or":   {orTok, orRn, false},
+	"nor":  {orTok, orRn, true},
+	"xor":  {xorTok, xorRn, false},
+	"xnor": {xorTok, xorRn, true},
+	"buf":  {"", 0, false},
+	"not":  {"", 0, true},
+}
+
+// Reads the modules in a file written in a structural subset of Verilog:
+// ports and wires that are single bits or vectors, `assign` statements with
+// &, |, ^, ~^, ~, and !, and gate primitives like `and g1 (y, a, b);`. Bits
+// of a vector have to be assigned one at a time.
+type structuralParser struct {
+	toks []netWord
+	pos  int
+	errs []error
+
+	// State of the module being read.
+	n      *netlist
+	order  []netWord
+	dirs   map[string]string
+	ranges map[string][2]int
+}
+
+func parseStructural(src *source) ([]*netlist, []error) {
+	p := &structuralParser{toks: structuralTokens(src)}
+	var nets []*netlist
+
+	for !p.done() {
+		if !p.expect("module") {
+			break
+		}
+
+		n := p.module()
+
+		if len(p.errs) > 0 {
+			break
+		}
+
+		nets = append(nets, n)
+	}
+
+	return nets, p.errs
+}
+
+// Splits Verilog source into words, skipping comments. Identifiers, numbers
+// like 1'b0, and operators are each a word of their own.
+func structuralTokens(src *source) []netWord {
+	var toks []netWord
+
+	runes := src.runes
+	line, lineStart := 1, 0
+
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		start := i
+
+		switch {
+		case r == nlRn:
+			line++
+			lineStart = i + 1
+			continue
+
+		case isWhitespace(r):
+			continue
+
+		case r == '/' && i+1 < len(runes) && runes[i+1] == '/':
+			for i+1 < len(runes) && runes[i+1] != nlRn {
+				i++
+			}
+
+			continue
+
+		case r == '/' && i+1 < len(runes) && runes[i+1] == '*':
+			startLine, startColumn := line, start-lineStart+1
+			i += 2
+
+			for i < len(runes) && !(runes[i] == '*' && i+1 < len(runes) && runes[i+1] == '/') {
+				if runes[i] == nlRn {
+					line++
+					lineStart = i + 1
+				}
+
+				i++
+			}
+
+			if i >= len(runes) {
+				toks = append(toks, netWord{text: "/*", span: span{src: src, offset: start,
+					end: start + 2, line: startLine, column: startColumn}})
+			}
+
+			i++
+			continue
+
+		case r == '\\':
+			for i+1 < len(runes) && !isWhitespace(runes[i+1]) {
+				i++
+			}
+
+		case unicode.IsLetter(r) || r == '_' || unicode.IsDigit(r) || r == '\'':
+			for i+1 < len(runes) && (unicode.IsLetter(runes[i+1]) ||
+				unicode.IsDigit(runes[i+1]) || strings.ContainsRune("_$'?", runes[i+1])) {
+				i++
+			}
+
+		case r == '~' && i+1 < len(runes) && runes[i+1] == '^',
+			r == '^' && i+1 < len(runes) && runes[i+1] == '~':
+			i++
+		}
+
+		toks = append(toks, netWord{
+			text: string(runes[start : i+1]),
+			span: span{src: src, offset: start, end: i + 1, line: line, column: start - lineStart + 1},
+		})
+	}
+
+	return toks
+}
+
+func (p *structuralParser) done() bool {
+	return p.pos >= len(p.toks)
+}
+
+func (p *structuralParser) peek() netWord {
+	if p.done() {
+		var sp span
+
+		if len(p.toks) > 0 {
+			sp = p.toks[len(p.toks)-1].span
+			sp.offset, sp.column = sp.end, sp.column+sp.end-sp.offset
+		}
+
+		return netWord{span: sp}
+	}
+
+	return p.toks[p.pos]
+}
+
+func (p *structuralParser) next() netWord {
+	w := p.peek()
+	p.pos++
+	return w
+}
+
+func (p *structuralParser) is(texts ...string) bool {
+	for _, text := range texts {
+		if p.peek().text == text {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (p *structuralParser) fail(w netWord, format string, args ...interface{}) {
+	if len(p.errs) == 0 {
+		p.errs = append(p.errs, errorAt(w.span, format, args...))
+	}
+}
+
+func (p *structuralParser) expect(text string) bool {
+	if w := p.next(); w.text != text {
+		p.fail(w, "Expecting `%s` but found %s instead.", text, describeWord(w))
+		return false
+	}
+
+	return true
+}
+
+func describeWord(w netWord) string {
+	if w.text == "" {
+		return "the end of the file"
+	}
+
+	return fmt.Sprintf("`%s`", w.text)
+}
+
+func (p *structuralParser) ident() netWord {
+	w := p.next()
+	r := []rune(w.text)
+
+	if len(r) == 0 || !(unicode.IsLetter(r[0]) || r[0] == '_' || r[0] == '\\') ||
+		verilogKeywords[w.text] {
+		p.fail(w, "Expecting a name but found %s instead.", describeWord(w))
+	}
+
+	return w
+}
+
+func (p *structuralParser) number() (int, netWord) {
+	w := p.next()
+	n, err := strconv.Atoi(w.text)
+
+	if err != nil {
+		p.fail(w, "Expecting a number but found %s instead.", describeWord(w))
+	}
+
+	return n, w
+}
+
+func (p *structuralParser) module() *netlist {
+	p.n = newNetlist(p.ident())
+	p.order = nil
+	p.dirs = make(map[string]string)
+	p.ranges = make(map[string][2]int)
+
+	if p.is("#") {
+		p.fail(p.peek(), "Module parameters are not supported.")
+		return nil
+	}
+
+	if p.is("(") {
+		p.next()
+		p.ports()
+		p.expect(")")
+	}
+
+	p.expect(";")
+
+	for len(p.errs) == 0 && !p.is("endmodule") {
+		if p.done() {
+			p.expect("endmodule")
+			break
+		}
+
+		p.item()
+	}
+
+	p.next()
+
+	for _, port := range p.order {
+		dir, ok := p.dirs[port.text]
+		np := netPort{name: port, bits: p.bits(port.text)}
+		_, np.vector = p.ranges[port.text]
+
+		switch {
+		case !ok:
+			p.fail(port, "Port `%s` is not declared as an input or an output.", port.text)
+		case dir == "input":
+			p.n.inputs = append(p.n.inputs, np)
+		case dir == "output":
+			p.n.outputs = append(p.n.outputs, np)
+		}
+	}
+
+	return p.n
+}
+
+// Ports are either a list of names that are declared as inputs and outputs
+// in the body of the module, or a list of declarations.
+func (p *structuralParser) ports() {
+	if p.is(")") {
+		return
+	}
+
+	if !p.is("input", "output", "inout") {
+		for {
+			p.order = append(p.order, p.ident())
+
+			if !p.is(",") {
+				return
+			}
+
+			p.next()
+		}
+	}
+
+	// Names without a direction of their own share the direction and range
+	// of the one before them.
+	dir := ""
+	var rng *[2]int
+
+	for len(p.errs) == 0 {
+		if p.is("input", "output", "inout") {
+			w := p.next()
+			dir = w.text
+
+			if dir == "inout" {
+				p.fail(w, "Ports with a direction of `inout` are not supported.")
+				return
+			} else if p.is("wire") {
+				p.next()
+			}
+
+			rng = p.rangeDecl()
+		}
+
+		p.declareName(dir, rng, true)
+
+		if !p.is(",") {
+			return
+		}
+
+		p.next()
+	}
+}
+
+func (p *structuralParser) declareName(dir string, rng *[2]int, ansi bool) {
+	name := p.ident()
+
+	if _, ok := p.dirs[name.text]; ok {
+		p.fail(name, "`%s` is declared more than once.", name.text)
+		return
+	}
+
+	if dir != "" {
+		p.dirs[name.text] = dir
+	}
+
+	if rng != nil {
+		p.ranges[name.text] = *rng
+	}
+
+	if ansi {
+		p.order = append(p.order, name)
+	}
+}
+
+// Reads an optional range like [7:0].
+func (p *structuralParser) rangeDecl() *[2]int {
+	if !p.is("[") {
+		return nil
+	}
+
+	p.next()
+	msb, _ := p.number()
+	p.expect(":")
+	lsb, _ := p.number()
+	p.expect("]")
+	return &[2]int{msb, lsb}
+}
+
+// Returns the nets that make up a port, from the most significant bit.
+func (p *structuralParser) bits(name string) []string {
+	rng, ok := p.ranges[name]
+
+	if !ok {
+		return []string{name}
+	}
+
+	var bits []string
+	step := 1
+
+	if rng[0] > rng[1] {
+		step = -1
+	}
+
+	for i := rng[0]; ; i += step {
+		bits = append(bits, fmt.Sprintf("%s[%d]", name, i))
+
+		if i == rng[1] {
+			return bits
+		}
+	}
+}
+
+func (p *structuralParser) item() {
+	w := p.peek()
+
+	switch {
+	case w.text == "input" || w.text == "output" || w.text == "inout":
+		dir := p.next().text
+
+		if dir == "inout" {
+			p.fail(w, "Ports with a direction of `inout` are not supported.")
+			return
+		} else if p.is("wire") {
+			p.next()
+		}
+
+		rng := p.rangeDecl()
+
+		for len(p.errs) == 0 {
+			p.declareName(dir, rng, false)
+
+			if !p.is(",") {
+				break
+			}
+
+			p.next()
+		}
+
+		p.expect(";")
+
+	case w.text == "wire":
+		p.next()
+		rng := p.rangeDecl()
+
+		for len(p.errs) == 0 {
+			name := p.ident()
+
+			if rng != nil {
+				p.ranges[name.text] = *rng
+			}
+
+			if p.is("=") {
+				p.next()
+				p.drive(p.lvalueOf(name), p.expr())
+			}
+
+			if !p.is(",") {
+				break
+			}
+
+			p.next()
+		}
+
+		p.expect(";")
+
+	case w.text == "assign":
+		p.next()
+
+		for len(p.errs) == 0 {
+			net := p.lvalue()
+			p.expect("=")
+			p.drive(net, p.expr())
+
+			if !p.is(",") {
+				break
+			}
+
+			p.next()
+		}
+
+		p.expect(";")
+
+	case isPrimitive(w.text):
+		p.next()
+
+		for len(p.errs) == 0 {
+			p.primitive(w)
+
+			if !p.is(",") {
+				break
+			}
+
+			p.next()
+		}
+
+		p.expect(";")
+
+	default:
+		p.fail(w, "Unsupported Verilog construct %s.", describeWord(w))
+	}
+}
+
+func isPrimitive(name string) bool {
+	_, ok := structuralPrimitives[name]
+	return ok
+}
+
+func (p *structuralParser) drive(net netWord, e Expr) {
+	if len(p.errs) > 0 {
+		return
+	}
+
+	if err := p.n.drive(net, e); err != nil {
+		p.errs = append(p.errs, err)
+	}
+}
+
+// `and g1 (out, in1, in2, ...)`, where the instance name is optional.
+func (p *structuralParser) primitive(kind netWord) {
+	if !p.is("(") {
+		p.ident()
+	}
+
+	p.expect("(")
+	out := p.lvalue()
+	var ins []Expr
+
+	for len(p.errs) == 0 && p.is(",") {
+		p.next()
+		ins = append(ins, p.expr())
+	}
+
+	p.expect(")")
+
+	if len(p.errs) > 0 {
+		return
+	}
+
+	prim := structuralPrimitives[kind.text]
+	var e Expr
+
+	switch {
+	case prim.r == 0 && len(ins) != 1:
+		p.fail(kind, "Expecting an output and one input for `%s`.", kind.text)
+		return
+	case prim.r == 0:
+		e = ins[0]
+	case len(ins) < 2:
+		p.fail(kind, "Expecting an output and at least two inputs for `%s`.", kind.text)
+		return
+	default:
+		e = netJoin(prim.op, prim.r, ins, kind.span)
+	}
+
+	if prim.negate {
+		e = netNot(e, kind.span)
+	}
+
+	p.drive(out, e)
+}
+
+// Reads the net on the left side of an assignment, which is a single bit.
+func (p *structuralParser) lvalue() netWord {
+	return p.lvalueOf(p.ident())
+}
+
+func (p *structuralParser) lvalueOf(name netWord) netWord {
+	net := p.bit(name)
+
+	if _, isVector := p.ranges[name.text]; isVector && net.text == name.text && len(p.errs) == 0 {
+		p.fail(name, "Cannot assign all of vector `%s` at once, assign each bit instead.", name.text)
+	}
+
+	return net
+}
+
+// Reads an optional bit select after a name and returns the net it refers
+// to.
+func (p *structuralParser) bit(name netWord) netWord {
+	rng, isVector := p.ranges[name.text]
+
+	if !p.is("[") {
+		return name
+	}
+
+	p.next()
+	i, w := p.number()
+	end := p.peek()
+	p.expect("]")
+
+	if len(p.errs) > 0 {
+		return name
+	} else if !isVector {
+		p.fail(name, "Cannot select a bit of `%s` since it is not a vector.", name.text)
+		return name
+	} else if (i < rng[0] && i < rng[1]) || (i > rng[0] && i > rng[1]) {
+		p.fail(w, "Bit %d is out of range for `%s`.", i, name.text)
+		return name
+	}
+
+	sp := name.span
+	sp.end = end.span.end
+	return netWord{text: fmt.Sprintf("%s[%d]", name.text, i), span: sp}
+}
+
+// Reads an expression. In order of precedence, from lowest to highest, the
+// operators are |, ^ and ~^, &, and then ~ and !.
+func (p *structuralParser) expr() Expr {
+	return p.binary(0)
+}
+
+var structuralLevels = []map[string]struct {
+	op     tokenId
+	r      rune
+	negate bool
+}{
+	{"|": {orTok, orRn, false}},
+	{"^": {xorTok, xorRn, false}, "~^": {xorTok, xorRn, true}, "^~": {xorTok, xorRn, true}},
+	{"&": {andTok, andRn, false}},
+}
+
+func (p *structuralParser) binary(level int) Expr {
+	if level == len(structuralLevels) {
+		return p.unary()
+	}
+
+	e := p.binary(level + 1)
+
+	for len(p.errs) == 0 {
+		op, ok := structuralLevels[level][p.peek().text]
+
+		if !ok {
+			return e
+		}
+
+		w := p.next()
+		e = netJoin(op.op, op.r, []Expr{e, p.binary(level + 1)}, w.span)
+
+		if op.negate {
+			e = netNot(e, w.span)
+		}
+	}
+
+	return e
+}
+
+func (p *structuralParser) unary() Expr {
+	w := p.peek()
+
+	switch {
+	case w.text == "~" || w.text == "!":
+		p.next()
+		return netNot(p.unary(), w.span)
+
+	case w.text == "(":
+		p.next()
+		e := p.expr()
+		p.expect(")")
+		return e
+
+	case w.text != "" && (unicode.IsDigit([]rune(w.text)[0]) || w.text[0] == '\''):
+		p.next()
+		val, ok := structuralBit(w.text)
+
+		if !ok {
+			p.fail(w, "Only single bit constants are supported but found `%s`.", w.text)
+		}
+
+		return netLiteral(val, w.span)
+	}
+
+	name := p.ident()
+	net := p.bit(name)
+
+	if _, isVector := p.ranges[name.text]; isVector && net.text == name.text {
+		p.fail(name, "Vector `%s` is used without selecting a bit.", name.text)
+	}
+
+	return p.n.ref(net)
+}
+
+// Parses constants like 0, 1, 1'b0, and 'b1.
+func structuralBit(text string) (bool, bool) {
+	digits := text
+
+	if i := strings.Index(text, "'"); i >= 0 {
+		width, base := text[:i], strings.ToLower(strings.TrimPrefix(text[i+1:], "s"))
+
+		if (width != "" && width != "1") || len(base) < 2 || !strings.ContainsRune("bodh", rune(base[0])) {
+			return false, false
+		}
+
+		digits = base[1:]
+	}
+
+	digits = strings.TrimLeft(strings.ReplaceAll(digits, "_", ""), "0")
+
+	switch digits {
+	case "":
+		return false, true
+	case "1":
+		return true, true
+	}
+
+	return false, false
+}
@@ -0,0 +1,215 @@
+package lang
+
+import (
+	"errors"
+	"fmt"
+	"math/bits"
+	"strings"
+)
+
+// Declares a gate from its truth table and returns the declaration. src is
+// the gate's name, optionally followed by its arguments in parentheses, and
+// then its table. The table is either a list of rows, such as
+// `00 -> 0, 01 -> 1, 10 -> 1, 11 -> 0`, or the output bits of every row in
+// order, such as `0110`. Arguments are named a, b, c, and so on when they are
+// not given.
+//
+// An output of `-` means the row does not matter, and an input of `-` stands
+// for both 0 and 1. Rows that are not listed are false. Rows with more than
+// one output declare a gate that returns a sequence.
+func (rt *Runtime) Synth(src string) (string, error) {
+	name, args, spec, errs := parseSynth(src)
+
+	if len(errs) > 0 {
+		return "", &Error{Phase: ParsePhase, Errors: errs}
+	}
+
+	fns, errs := parseTruthTable(spec)
+
+	if len(errs) > 0 {
+		return "", &Error{Phase: ParsePhase, Errors: errs}
+	}
+
+	inputs := fns[0].inputs
+
+	if args == nil {
+		for i := 0; i < inputs; i++ {
+			args = append(args, string(rune('a'+i)))
+		}
+	} else if len(args) != inputs {
+		return "", &Error{Phase: ParsePhase, Errors: []error{fmt.Errorf(
+			"Expecting %d arguments but got %d instead.", inputs, len(args))}}
+	}
+
+	var outputs []string
+
+	for _, f := range fns {
+		outputs = append(outputs, sop{names: args, cubes: minimize(f)}.String())
+	}
+
+	body := outputs[0]
+
+	if len(outputs) > 1 {
+		body = "[" + strings.Join(outputs, ", ") + "]"
+	}
+
+	if err := rt.DefineGate(name, args, body); err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("gate %s (%s) = %s", name, strings.Join(args, ", "), body), nil
+}
+
+// Splits the source of a .synth command into the gate's name, its
+// arguments, which are nil when they are not given, and its table.
+func parseSynth(src string) (string, []string, string, []error) {
+	src = strings.TrimSpace(src)
+	end := strings.IndexAny(src, " \t(")
+
+	if end < 0 {
+		return "", nil, "", []error{errors.New("Expecting a gate name followed by its truth table.")}
+	}
+
+	name, rest := src[:end], strings.TrimSpace(src[end:])
+	var args []string
+
+	if strings.HasPrefix(rest, "(") {
+		paren := strings.IndexByte(rest, ')')
+
+		if paren < 0 {
+			return "", nil, "", []error{fmt.Errorf("Expecting `)` after the arguments of `%s`.", name)}
+		}
+
+		for _, arg := range strings.Split(rest[1:paren], ",") {
+			args = append(args, strings.TrimSpace(arg))
+		}
+
+		rest = strings.TrimSpace(rest[paren+1:])
+	}
+
+	if rest == "" {
+		return "", nil, "", []error{errors.New("Expecting a gate name followed by its truth table.")}
+	}
+
+	return name, args, rest, nil
+}
+
+// Parses a truth table given as rows or as output bits into a function for
+// every output.
+func parseTruthTable(spec string) ([]truthFunc, []error) {
+	if strings.Contains(spec, "->") {
+		return parseTableRows(spec)
+	}
+
+	var outs []byte
+
+	for _, r := range spec {
+		switch r {
+		case '0', '1', '-':
+			outs = append(outs, byte(r))
+		case '[', ']', ',', ' ', '\t':
+		default:
+			return nil, []error{fmt.Errorf(
+				"Invalid output `%c`, expecting 0, 1, or - for rows that do not matter.", r)}
+		}
+	}
+
+	inputs := bits.TrailingZeros(uint(len(outs)))
+
+	if len(outs) < 2 || len(outs) != 1<<inputs {
+		return nil, []error{fmt.Errorf(
+			"Expecting 2, 4, 8, or another power of two output bits but got %d.", len(outs))}
+	} else if inputs > maxTableInputs {
+		return nil, []error{fmt.Errorf(
+			"Too many inputs to synthesize, max is %d but got %d.", maxTableInputs, inputs)}
+	}
+
+	f := truthFunc{inputs: inputs, on: make([]bool, len(outs)), dc: make([]bool, len(outs))}
+
+	for row, out := range outs {
+		f.on[row] = out == '1'
+		f.dc[row] = out == '-'
+	}
+
+	return []truthFunc{f}, nil
+}
+
+// Parses rows like `01 -> 1`, where either side can have any number of bits
+// as long as every row has the same number.
+func parseTableRows(spec string) ([]truthFunc, []error) {
+	var errs []error
+	var fns []truthFunc
+
+	// The output each row was given, so that rows that contradict each other
+	// can be reported.
+	var given [][]byte
+
+	for _, row := range strings.Split(spec, ",") {
+		row = strings.TrimSpace(row)
+		in, out, _ := strings.Cut(row, "->")
+		in, out = strings.TrimSpace(in), strings.TrimSpace(out)
+
+		if in == "" || out == "" || strings.Trim(in, "01-") != "" || strings.Trim(out, "01-") != "" {
+			errs = append(errs, fmt.Errorf(
+				"Invalid row `%s`, rows look like `01 -> 1` with `-` for bits that do not matter.", row))
+			continue
+		}
+
+		if fns == nil {
+			if len(in) > maxTableInputs {
+				return nil, []error{fmt.Errorf(
+					"Too many inputs to synthesize, max is %d but got %d.", maxTableInputs, len(in))}
+			}
+
+			for range out {
+				fns = append(fns, truthFunc{inputs: len(in), on: make([]bool, 1<<len(in)), dc: make([]bool, 1<<len(in))})
+				given = append(given, make([]byte, 1<<len(in)))
+			}
+		}
+
+		if len(in) != fns[0].inputs {
+			errs = append(errs, fmt.Errorf(
+				"Row `%s` has %d inputs but the first row has %d.", row, len(in), fns[0].inputs))
+			continue
+		} else if len(out) != len(fns) {
+			errs = append(errs, fmt.Errorf(
+				"Row `%s` has %d outputs but the first row has %d.", row, len(out), len(fns)))
+			continue
+		}
+
+		var c cube
+
+		for _, r := range in {
+			c.mask, c.value = c.mask<<1, c.value<<1
+
+			if r != '-' {
+				c.mask |= 1
+			}
+
+			if r == '1' {
+				c.value |= 1
+			}
+		}
+
+		conflict := false
+
+		c.rows(len(in), func(r uint32) {
+			for i := range fns {
+				if given[i][r] != 0 && given[i][r] != out[i] {
+					conflict = true
+				}
+
+				given[i][r] = out[i]
+				fns[i].on[r] = out[i] == '1'
+				fns[i].dc[r] = out[i] == '-'
+			}
+		})
+
+		if conflict {
+			errs = append(errs, fmt.Errorf(
+				"Row `%s` contradicts an earlier row with the same inputs.", row))
+		}
+	}
+
+	return fns, errs
+}
@@ -0,0 +1,81 @@
+package lang
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestSynth(t *testing.T) {
+	rt := NewRuntime()
+
+	tests := []struct {
+		src  string
+		decl string
+	}{
+		{"Xor 00 -> 0, 01 -> 1, 10 -> 1, 11 -> 0", "gate Xor (a, b) = (a ∧ ¬b) ∨ (¬a ∧ b)"},
+		{"Maj 00010111", "gate Maj (a, b, c) = (a ∧ b) ∨ (a ∧ c) ∨ (b ∧ c)"},
+		{"Maj2 [0, 0, 0, 1, 0, 1, 1, 1]", "gate Maj2 (a, b, c) = (a ∧ b) ∨ (a ∧ c) ∨ (b ∧ c)"},
+		{"Imp (p, q) 1101", "gate Imp (p, q) = ¬p ∨ q"},
+		{"Any 0-1-", "gate Any (a, b) = a"},
+		{"Upper 1- -> 1", "gate Upper (a, b) = a"},
+		{"Never 0000", "gate Never (a, b) = false"},
+		{"Always 11", "gate Always (a) = true"},
+		{"Half (x, y) 00 -> 00, 01 -> 10, 10 -> 10, 11 -> 01", "gate Half (x, y) = [(x ∧ ¬y) ∨ (¬x ∧ y), x ∧ y]"},
+	}
+
+	for _, test := range tests {
+		decl, err := rt.Synth(test.src)
+
+		if err != nil {
+			t.Errorf("Synth(%q) error = %v", test.src, err)
+		} else if decl != test.decl {
+			t.Errorf("Synth(%q) = %s, expected %s", test.src, decl, test.decl)
+		}
+	}
+
+	// The gates are declared and agree with their tables.
+	for row, exp := range []bool{false, false, false, true, false, true, true, true} {
+		val, err := rt.Eval(fmt.Sprintf("Maj(%d, %d, %d)", row>>2&1, row>>1&1, row&1))
+
+		if err != nil {
+			t.Fatal(err)
+		} else if val.Bool() != exp {
+			t.Errorf("Maj with row %d = %v, expected %v", row, val.Bool(), exp)
+		}
+	}
+
+	if val, err := rt.Eval("Half(1, 1)"); err != nil {
+		t.Error(err)
+	} else if val.String() != "Seq[2]{0, 1}" {
+		t.Errorf("Half(1, 1) = %s, expected Seq[2]{0, 1}", val)
+	}
+
+	errTests := []struct {
+		src string
+		err string
+	}{
+		{"", "Expecting a gate name followed by its truth table."},
+		{"Bad", "Expecting a gate name followed by its truth table."},
+		{"Bad (a, b 0110", "Expecting `)` after the arguments of `Bad`."},
+		{"Bad 011", "Expecting 2, 4, 8, or another power of two output bits but got 3."},
+		{"Bad 1", "Expecting 2, 4, 8, or another power of two output bits but got 1."},
+		{"Bad 01x0", "Invalid output `x`, expecting 0, 1, or - for rows that do not matter."},
+		{"Bad 0 -> 1, 00 -> 1", "Row `00 -> 1` has 2 inputs but the first row has 1."},
+		{"Bad 0 -> 1, 1 -> 10", "Row `1 -> 10` has 2 outputs but the first row has 1."},
+		{"Bad 1 -> 1, 1 -> 0", "Row `1 -> 0` contradicts an earlier row with the same inputs."},
+		{"Bad 1- -> 1, 11 -> 0", "Row `11 -> 0` contradicts an earlier row with the same inputs."},
+		{"Bad 0 -> a", "Invalid row `0 -> a`, rows look like `01 -> 1` with `-` for bits that do not matter."},
+		{"Bad (x) 0110", "Expecting 2 arguments but got 1 instead."},
+		{"2x 01", "Invalid identifier `2x`"},
+		{"Bad " + strings.Repeat("0", 17) + " -> 1", "Too many inputs to synthesize, max is 16 but got 17."},
+	}
+
+	for _, test := range errTests {
+		_, err := rt.Synth(test.src)
+
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("Synth(%q) error = %v, expected %q", test.src, err, test.err)
+		}
+	}
+}
@@ -0,0 +1,250 @@
+package lang
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Maximum number of inputs a truth table can have. Every additional input
+// doubles the number of rows, so anything larger than this is not useful to
+// look at anyway.
+const maxTableInputs = 16
+
+// A truth table is made up of a header with the name of every input followed
+// by the output, and a row for every combination of input values.
+type table struct {
+	header []string
+	rows   [][]string
+}
+
+// Builds a truth table for src, which is either the name of a gate or an
+// expression. Gate tables have a column for every gate argument, and
+// expression tables have a column for every identifier in the expression that
+// is not bound in env.
+func truthTable(src string, env environment) (table, []error) {
+	src = strings.TrimSpace(src)
+	toks := scan(src)
+
+	if len(toks) == 1 && toks[0].id == identTok {
+		if g, ok := env.getGate(toks[0].lexeme); ok {
+			return gateTable(toks[0], g, env)
+		}
+	}
+
+	expr, errs := parse(toks)
+
+	if len(errs) > 0 {
+		return table{}, errs
+	}
+
+	stmt, ok := expr.(exprStmt)
+
+	if !ok {
+		return table{}, []error{errors.New(
+			"Expecting an expression or a gate name.")}
+	}
+
+	return expressionTable(src, stmt.expr, env)
+}
+
+// Gates are called by the name they were looked up with, which is not the
+// same as their label when they come from a module. Arguments that are used
+// as sequences get a column for every bit, named like `x(0)`.
+func gateTable(name token, g gate, env environment) (table, []error) {
+	params := newShapeInference().params(g)
+	var inputs, args []string
+
+	for i, arg := range g.args {
+		inputs = append(inputs, shapeNames(params[i], arg.lexeme)...)
+		args = append(args, arg.lexeme)
+	}
+
+	output := fmt.Sprintf("%s(%s)", name.lexeme, strings.Join(args, ", "))
+
+	return buildTable(inputs, output, func(vals []bool) (value, []error) {
+		call := &CallExpr{callee: name}
+		row, pos := 0, len(vals)
+
+		for _, val := range vals {
+			row <<= 1
+
+			if val {
+				row |= 1
+			}
+		}
+
+		for _, param := range params {
+			pos -= param.width()
+			call.args = append(call.args, shapeExpr(param, row, pos))
+		}
+
+		return evaluate(call, env)
+	}, env)
+}
+
+// Returns the name of every bit of a value with the given shape, in the same
+// order shapeWire creates its inputs.
+func shapeNames(s shape, name string) []string {
+	if !s.list {
+		return []string{name}
+	}
+
+	var names []string
+
+	for i, item := range s.items {
+		names = append(names, shapeNames(item, fmt.Sprintf("%s(%d)", name, i))...)
+	}
+
+	return names
+}
+
+func expressionTable(src string, e Expr, env environment) (table, []error) {
+	inputs := freeIdentifiers(e, env)
+
+	return buildTable(inputs, src, func(vals []bool) (value, []error) {
+		scope := newEnvironment(&env)
+
+		for i, input := range inputs {
+			scope.setBinding(input, &LiteralExpr{value: vals[i]})
+		}
+
+		ret, errs := evaluate(e, scope)
+
+		// Sequence items are evaluated lazily, so they have to be evaluated
+		// while the inputs are still in scope.
+		if len(errs) == 0 && ret.isSequence() {
+			snapshot, errs := ret.sequence.freeze(scope)
+			return value{sequence: &snapshot}, errs
+		}
+
+		return ret, errs
+	}, env)
+}
+
+// Returns the name of every identifier in e that is neither a binding nor a
+// gate in env, in the order they first appear.
+func freeIdentifiers(e Expr, env environment) []string {
+	var free []string
+	seen := make(map[string]bool)
+
+	for _, id := range identifiers(e, env) {
+		if seen[id.lexeme] {
+			continue
+		}
+
+		seen[id.lexeme] = true
+
+		if _, ok := env.getBinding(id.lexeme); ok {
+			continue
+		} else if _, ok := env.getGate(id.lexeme); ok {
+			continue
+		}
+
+		free = append(free, id.lexeme)
+	}
+
+	return free
+}
+
+func buildTable(inputs []string, output string, fn func([]bool) (value, []error), env environment) (table, []error) {
+	if len(inputs) > maxTableInputs {
+		return table{}, []error{fmt.Errorf(
+			"Too many inputs for a truth table, max is %d but got %d.",
+			maxTableInputs, len(inputs))}
+	}
+
+	t := table{header: append(append([]string{}, inputs...), output)}
+
+	for n := 0; n < 1<<uint(len(inputs)); n++ {
+		var row []string
+		vals := make([]bool, len(inputs))
+
+		// The first input is the most significant bit so that rows are
+		// listed in the same order they would be written out by hand.
+		for i := range inputs {
+			vals[i] = n&(1<<uint(len(inputs)-i-1)) != 0
+			row = append(row, cell(value{boolean: &boolean{vals[i]}}, env))
+		}
+
+		ret, errs := fn(vals)
+
+		if len(errs) > 0 {
+			return table{}, errs
+		}
+
+		t.rows = append(t.rows, append(row, cell(ret, env)))
+	}
+
+	return t, nil
+}
+
+// Formats a value as it is displayed in a table cell. Booleans are displayed
+// as ones and zeros.
+func cell(v value, env environment) string {
+	if v.isBoolean() {
+		if v.boolean.internal {
+			return "1"
+		}
+
+		return "0"
+	} else if v.isSequence() {
+		var items []string
+
+		for _, expr := range v.sequence.internal {
+			ret, errs := evaluate(expr, env)
+
+			if len(errs) > 0 {
+				items = append(items, "?")
+			} else {
+				items = append(items, cell(ret, env))
+			}
+		}
+
+		return "[" + strings.Join(items, ", ") + "]"
+	} else {
+		return fmt.Sprintf("%d", v.number)
+	}
+}
+
+// Renders the table as aligned lines of text, with the inputs separated from
+// each other and from the output by a vertical bar.
+func (t table) lines() []string {
+	widths := make([]int, len(t.header))
+
+	for i, h := range t.header {
+		widths[i] = len([]rune(h))
+	}
+
+	for _, row := range t.rows {
+		for i, c := range row {
+			if l := len([]rune(c)); l > widths[i] {
+				widths[i] = l
+			}
+		}
+	}
+
+	format := func(cells []string) string {
+		padded := make([]string, len(cells))
+
+		for i, c := range cells {
+			padded[i] = c + strings.Repeat(" ", widths[i]-len([]rune(c)))
+		}
+
+		return strings.TrimRight(strings.Join(padded, " | "), " ")
+	}
+
+	rule := make([]string, len(widths))
+
+	for i, w := range widths {
+		rule[i] = strings.Repeat("-", w)
+	}
+
+	lines := []string{format(t.header), strings.Join(rule, "-+-")}
+
+	for _, row := range t.rows {
+		lines = append(lines, format(row))
+	}
+
+	return lines
+}
@@ -0,0 +1,124 @@
+package lang
+
+import "strconv"
+
+// Kind is the type of a Value.
+type Kind string
+
+const (
+	None     Kind = "none"
+	Boolean  Kind = "boolean"
+	Number   Kind = "number"
+	Sequence Kind = "sequence"
+)
+
+// A Value is the result of evaluating an expression. Unlike the values used
+// while evaluating, every item in a sequence Value has already been
+// evaluated, so it can be used without a Runtime.
+type Value struct {
+	kind Kind
+	val  value
+}
+
+// Returns a boolean Value.
+func Bool(b bool) Value {
+	return Value{kind: Boolean, val: value{boolean: &boolean{b}}}
+}
+
+// Returns a number Value.
+func Num(n int) Value {
+	return Value{kind: Number, val: value{number: n}}
+}
+
+// Returns a sequence Value made up of the given items.
+func Seq(items ...Value) Value {
+	seq := &sequence{}
+
+	for _, item := range items {
+		seq.internal = append(seq.internal, item.expr())
+	}
+
+	return Value{kind: Sequence, val: value{sequence: seq}}
+}
+
+func newValue(v value, env environment) (Value, []error) {
+	if !v.isSequence() {
+		return Value{kind: Kind(v.getTypeId()), val: v}, nil
+	}
+
+	snapshot, errs := v.sequence.freeze(env)
+
+	if len(errs) > 0 {
+		return Value{}, errs
+	}
+
+	return Value{kind: Sequence, val: value{sequence: &snapshot}}, nil
+}
+
+func (v Value) Kind() Kind {
+	if v.kind == "" {
+		return None
+	}
+
+	return v.kind
+}
+
+// Returns the value of a boolean, and false for any other kind.
+func (v Value) Bool() bool {
+	return v.Kind() == Boolean && v.val.boolean.internal
+}
+
+// Returns the value of a number. Booleans are converted into one or zero, and
+// every other kind is zero.
+func (v Value) Num() int {
+	switch v.Kind() {
+	case Boolean, Number:
+		return numberCast(v.val).number
+
+	default:
+		return 0
+	}
+}
+
+// Returns the items in a sequence, and nil for any other kind.
+func (v Value) Items() []Value {
+	if v.Kind() != Sequence {
+		return nil
+	}
+
+	var items []Value
+	env := newEnvironment(nil)
+
+	for _, expr := range v.val.sequence.internal {
+		val, _ := evaluate(expr, env)
+		item, _ := newValue(val, env)
+		items = append(items, item)
+	}
+
+	return items
+}
+
+func (v Value) String() string {
+	if v.Kind() == None {
+		return ""
+	}
+
+	return print(v.val, newEnvironment(nil))
+}
+
+// Converts the value into an expression that evaluates to it.
+func (v Value) expr() Expr {
+	switch v.Kind() {
+	case Boolean:
+		return &LiteralExpr{value: v.val.boolean.internal}
+
+	case Sequence:
+		return &SeqExpr{items: v.val.sequence.internal}
+
+	default:
+		return &NumberExpr{tok: token{
+			id:     numTok,
+			lexeme: strconv.Itoa(v.val.number),
+		}}
+	}
+}
@@ -0,0 +1,533 @@
+package lang
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var (
+	verilogOperators = map[tokenId]string{
+		andTok: "&",
+		eqTok:  "==",
+		geTok:  ">=",
+		gtTok:  ">",
+		leTok:  "<=",
+		ltTok:  "<",
+		orTok:  "|",
+		xorTok: "^",
+	}
+
+	// Operators that only work on single bits, like the builtins they map to.
+	verilogBitOperators = map[tokenId]bool{
+		andTok: true,
+		miTok:  true,
+		orTok:  true,
+		xorTok: true,
+	}
+
+	verilogKeywords = map[string]bool{
+		"always": true, "and": true, "assign": true, "begin": true,
+		"buf": true, "case": true, "default": true, "else": true,
+		"end": true, "endcase": true, "endfunction": true,
+		"endmodule": true, "for": true, "function": true, "if": true,
+		"initial": true, "inout": true, "input": true, "integer": true,
+		"logic": true, "module": true, "nand": true, "negedge": true,
+		"nor": true, "not": true, "or": true, "output": true,
+		"parameter": true, "posedge": true, "reg": true, "signed": true,
+		"task": true, "wire": true, "xnor": true, "xor": true,
+	}
+
+	verilogInvalidRunes = regexp.MustCompile(`[^A-Za-z0-9_]`)
+)
+
+// Vectors use ascending ranges so that an item's index in Bool is the same as
+// its bit in Verilog.
+func (s shape) rangeDecl() string {
+	if !s.list {
+		return ""
+	}
+
+	return fmt.Sprintf("[0:%d] ", s.width()-1)
+}
+
+// The interface of a module generated for a gate.
+type signature struct {
+	module  string
+	ports   []string
+	params  []shape
+	out     string
+	shape   shape
+	clocked bool
+}
+
+// Exports gates, and every gate they call, as Verilog modules.
+type verilogExporter struct {
+	sigs     map[Expr]*signature
+	names    map[string]bool
+	visiting map[Expr]bool
+	modules  []string
+	shapes   *shapeInference
+}
+
+// Writes a module for a gate and for every gate it depends on, along with the
+// code that connects them.
+type moduleWriter struct {
+	x      *verilogExporter
+	g      gate
+	sig    *signature
+	args   map[string]int
+	names  map[string]string
+	locals map[string]Expr
+	shapes map[string]shape
+	done   map[string]bool
+	stack  []string
+	init   map[string]shape
+	decls  []string
+	stmts  []string
+	count  int
+
+	// Result of the last expression visited.
+	code  string
+	shape shape
+	errs  []error
+}
+
+func newVerilogExporter() *verilogExporter {
+	return &verilogExporter{
+		sigs:     make(map[Expr]*signature),
+		names:    make(map[string]bool),
+		visiting: make(map[Expr]bool),
+		shapes:   newShapeInference(),
+	}
+}
+
+// Returns the signature of a gate's module, generating the module and every
+// module it depends on the first time the gate is seen.
+func (x *verilogExporter) signature(g gate) (*signature, []error) {
+	if sig, ok := x.sigs[g.body]; ok {
+		return sig, nil
+	} else if x.visiting[g.body] {
+		return nil, []error{errorAt(g.label.span,
+			"Cannot export `%s` since it calls itself.", g.label.lexeme)}
+	}
+
+	x.visiting[g.body] = true
+	defer delete(x.visiting, g.body)
+
+	m := &moduleWriter{
+		x:      x,
+		g:      g,
+		args:   make(map[string]int),
+		names:  make(map[string]string),
+		locals: make(map[string]Expr),
+		shapes: make(map[string]shape),
+		done:   make(map[string]bool),
+		init:   make(map[string]shape),
+	}
+
+	code, errs := m.write()
+
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	x.sigs[g.body] = m.sig
+	x.modules = append(x.modules, code)
+	return m.sig, nil
+}
+
+// Returns a Verilog identifier for a name, which has to be unique within
+// names.
+func verilogName(name string, taken map[string]bool) string {
+	name = verilogInvalidRunes.ReplaceAllString(name, "_")
+
+	if name == "" || name[0] == '_' || (name[0] >= '0' && name[0] <= '9') || verilogKeywords[name] {
+		name = "v" + name
+	}
+
+	unique := name
+
+	for i := 2; taken[unique]; i++ {
+		unique = fmt.Sprintf("%s_%d", name, i)
+	}
+
+	taken[unique] = true
+	return unique
+}
+
+func (m *moduleWriter) write() (string, []error) {
+	g := m.g
+	m.sig = &signature{module: verilogName(g.label.lexeme, m.x.names)}
+
+	// Generated names start with an underscore, which user names never do.
+	taken := map[string]bool{"clk": true}
+
+	for i, arg := range g.args {
+		m.args[arg.lexeme] = i
+		m.names[arg.lexeme] = verilogName(arg.lexeme, taken)
+		m.sig.ports = append(m.sig.ports, m.names[arg.lexeme])
+	}
+
+	m.sig.out = verilogName("out", taken)
+
+	for _, local := range g.locals {
+		if _, isArg := m.args[local.label.lexeme]; !isArg {
+			m.locals[local.label.lexeme] = local.value
+			m.names[local.label.lexeme] = verilogName(local.label.lexeme, taken)
+		}
+	}
+
+	m.sig.params = m.x.shapes.params(g)
+
+	for _, local := range g.locals {
+		if _, ok := m.locals[local.label.lexeme]; ok {
+			m.local(local.label.lexeme)
+		}
+	}
+
+	body, out := m.compile(g.body)
+
+	if len(m.errs) > 0 {
+		return "", m.errs
+	}
+
+	m.sig.shape = out
+	m.stmts = append(m.stmts, fmt.Sprintf("assign %s = %s;", m.sig.out, body))
+
+	var ports []string
+
+	if m.sig.clocked {
+		ports = append(ports, "input wire clk")
+	}
+
+	for i, port := range m.sig.ports {
+		ports = append(ports, fmt.Sprintf("input wire %s%s", m.sig.params[i].rangeDecl(), port))
+	}
+
+	ports = append(ports, fmt.Sprintf("output wire %s%s", out.rangeDecl(), m.sig.out))
+
+	var buff strings.Builder
+
+	fmt.Fprintf(&buff, "module %s (\n", m.sig.module)
+	fmt.Fprintf(&buff, "    %s\n", strings.Join(ports, ",\n    "))
+	buff.WriteString(");\n")
+
+	for _, line := range m.decls {
+		fmt.Fprintf(&buff, "    %s\n", line)
+	}
+
+	if len(m.decls) > 0 {
+		buff.WriteString("\n")
+	}
+
+	for _, line := range m.stmts {
+		fmt.Fprintf(&buff, "    %s\n", line)
+	}
+
+	buff.WriteString("endmodule\n")
+	return buff.String(), nil
+}
+
+// Declares and assigns a local binding, returning its shape. Locals that are
+// still being written are part of a feedback loop through a register, which
+// are assumed to be a single bit unless the register has an initial value.
+func (m *moduleWriter) local(name string) shape {
+	if s, ok := m.shapes[name]; ok {
+		return s
+	} else if s, ok := m.init[name]; ok {
+		return s
+	} else if m.done[name] {
+		return bit
+	}
+
+	m.done[name] = true
+	m.stack = append(m.stack, name)
+	code, s := m.compile(m.locals[name])
+	m.stack = m.stack[:len(m.stack)-1]
+	m.shapes[name] = s
+
+	m.decls = append(m.decls, fmt.Sprintf("wire %s%s;", s.rangeDecl(), m.names[name]))
+	m.stmts = append(m.stmts, fmt.Sprintf("assign %s = %s;", m.names[name], code))
+	return s
+}
+
+func (m *moduleWriter) compile(e Expr) (string, shape) {
+	e.Accept(m)
+	return m.code, m.shape
+}
+
+func (m *moduleWriter) ret(code string, s shape) {
+	m.code = code
+	m.shape = s
+}
+
+func (m *moduleWriter) fail(err error) {
+	m.errs = append(m.errs, err)
+	m.ret("1'bx", bit)
+}
+
+func (m *moduleWriter) next() int {
+	m.count++
+	return m.count - 1
+}
+
+func (m *moduleWriter) VisitBadExpr(e *BadExpr) {
+	m.fail(errorAt(e.Span(), "Cannot export expression due to error: %s", e.err))
+}
+
+func (m *moduleWriter) VisitBinaryExpr(e *BinaryExpr) {
+	lhs, ls := m.compile(e.lhs)
+	rhs, rs := m.compile(e.rhs)
+
+	if verilogBitOperators[e.op.id] && (ls.list || rs.list) {
+		m.fail(errorAt(e.Span(), "Cannot export `%s` on sequences, it "+
+			"expects single bits.", e.op.lexeme))
+		return
+	} else if ls.width() != rs.width() {
+		m.fail(errorAt(e.Span(), "Cannot export `%s` on values that are %d "+
+			"and %d bits wide.", e.op.lexeme, ls.width(), rs.width()))
+		return
+	}
+
+	if e.op.id == miTok {
+		m.ret(fmt.Sprintf("(~%s | %s)", lhs, rhs), bit)
+	} else {
+		m.ret(fmt.Sprintf("(%s %s %s)", lhs, verilogOperators[e.op.id], rhs), bit)
+	}
+}
+
+func (m *moduleWriter) VisitUnaryExpr(e *UnaryExpr) {
+	rhs, s := m.compile(e.rhs)
+
+	if s.list {
+		m.fail(errorAt(e.Span(), "Cannot export `%s` on a sequence, it "+
+			"expects a single bit.", e.op.lexeme))
+		return
+	}
+
+	m.ret("~"+rhs, bit)
+}
+
+func (m *moduleWriter) VisitGroupExpr(e *GroupExpr) {
+	m.compile(e.inner)
+}
+
+func (m *moduleWriter) VisitCallExpr(e *CallExpr) {
+	name := e.callee.lexeme
+
+	if g, ok := m.g.env.getGate(name); ok {
+		m.instance(g, e)
+	} else if isRegisterCall(e, *m.g.env) {
+		m.register(e)
+	} else {
+		m.index(e)
+	}
+}
+
+// Gate calls become instances of the gate's module, with a wire for the
+// output.
+func (m *moduleWriter) instance(g gate, e *CallExpr) {
+	sig, errs := m.x.signature(g)
+
+	if len(errs) > 0 {
+		m.errs = append(m.errs, errs...)
+		m.ret("1'bx", bit)
+		return
+	} else if len(e.args) != len(sig.params) {
+		m.fail(errorAt(e.span, "Arity error, `%s` expects %d arguments "+
+			"but got %d instead.", e.callee.lexeme, len(sig.params), len(e.args)))
+		return
+	}
+
+	var conns []string
+
+	if sig.clocked {
+		m.sig.clocked = true
+		conns = append(conns, ".clk(clk)")
+	}
+
+	for i, arg := range e.args {
+		code, s := m.compile(arg)
+
+		if s.width() != sig.params[i].width() {
+			m.fail(errorAt(arg.Span(), "Cannot export argument %d of `%s`, "+
+				"it is %d bits wide but expected %d.", i+1, e.callee.lexeme,
+				s.width(), sig.params[i].width()))
+			return
+		}
+
+		conns = append(conns, fmt.Sprintf(".%s(%s)", sig.ports[i], code))
+	}
+
+	n := m.next()
+	wire := fmt.Sprintf("_w%d", n)
+	conns = append(conns, fmt.Sprintf(".%s(%s)", sig.out, wire))
+
+	m.decls = append(m.decls, fmt.Sprintf("wire %s%s;", sig.shape.rangeDecl(), wire))
+	m.stmts = append(m.stmts, fmt.Sprintf("%s _u%d (%s);", sig.module, n,
+		strings.Join(conns, ", ")))
+	m.ret(wire, sig.shape)
+}
+
+// Registers latch their input on the rising edge of the module's clock.
+func (m *moduleWriter) register(e *CallExpr) {
+	if len(e.args) != 1 && len(e.args) != 2 {
+		m.fail(errorAt(e.span, "Arity error, `%s` expects 1 or 2 arguments "+
+			"but got %d instead.", e.callee.lexeme, len(e.args)))
+		return
+	}
+
+	n := m.next()
+	reg := fmt.Sprintf("_r%d", n)
+	init, s := "1'b0", bit
+
+	if len(e.args) == 2 {
+		init, s = m.compile(e.args[1])
+
+		// A local bound to this register has the shape of its initial value,
+		// even while its input, which may refer to it, is being compiled.
+		if len(m.stack) > 0 {
+			top := m.stack[len(m.stack)-1]
+
+			if unwrapGroup(m.locals[top]) == Expr(e) {
+				m.init[top] = s
+			}
+		}
+	}
+
+	// The register is declared before its input is compiled since the input
+	// can refer back to it.
+	m.sig.clocked = true
+	m.decls = append(m.decls, "")
+	decl := len(m.decls) - 1
+	input, is := m.compile(e.args[0])
+
+	if len(e.args) == 1 {
+		s = is
+
+		if s.list {
+			init = fmt.Sprintf("{%d{1'b0}}", s.width())
+		}
+	}
+
+	if is.width() != s.width() {
+		m.fail(errorAt(e.span, "Cannot export `%s`, its input is %d bits "+
+			"wide but its initial value is %d.", e.callee.lexeme, is.width(), s.width()))
+		return
+	}
+
+	m.decls[decl] = fmt.Sprintf("reg %s%s = %s;", s.rangeDecl(), reg, init)
+	m.stmts = append(m.stmts, fmt.Sprintf("always @(posedge clk) %s <= %s;", reg, input))
+	m.ret(reg, s)
+}
+
+// Accessing an item in a sequence selects its bits.
+func (m *moduleWriter) index(e *CallExpr) {
+	name := e.callee.lexeme
+	var s shape
+
+	if n, isArg := m.args[name]; isArg {
+		s = m.sig.params[n]
+	} else if _, isLocal := m.locals[name]; isLocal {
+		s = m.local(name)
+	} else {
+		m.fail(errorAt(e.callee.span, "Undefined gate `%s`", name))
+		return
+	}
+
+	if len(e.args) != 1 {
+		m.fail(errorAt(e.callee.span, "Undefined gate `%s`", name))
+		return
+	}
+
+	idx, ok := constantIndex(e.args[0])
+
+	if !ok {
+		m.fail(errorAt(e.args[0].Span(), "Cannot export `%s`, only "+
+			"constant indexes can be exported.", render(e)))
+		return
+	} else if !s.list {
+		m.fail(errorAt(e.callee.span, "Invalid operation, expecting `%s` "+
+			"to be a sequence", name))
+		return
+	} else if idx >= len(s.items) {
+		m.fail(errorAt(e.args[0].Span(), "Out of bounds error, max is %d "+
+			"and tried to access %d on `%s` sequence.", len(s.items)-1, idx, name))
+		return
+	}
+
+	item := s.items[idx]
+	off := s.offset(idx)
+
+	if item.list {
+		m.ret(fmt.Sprintf("%s[%d:%d]", m.names[name], off, off+item.width()-1), item)
+	} else {
+		m.ret(fmt.Sprintf("%s[%d]", m.names[name], off), item)
+	}
+}
+
+func (m *moduleWriter) VisitIdentExpr(e *IdentExpr) {
+	name := e.name.lexeme
+
+	if n, isArg := m.args[name]; isArg {
+		m.ret(m.names[name], m.sig.params[n])
+	} else if _, isLocal := m.locals[name]; isLocal {
+		s := m.local(name)
+		m.ret(m.names[name], s)
+	} else {
+		m.fail(errorAt(e.name.span, "Cannot export `%s` since it is not an "+
+			"argument or a local binding of `%s`.", name, m.g.label.lexeme))
+	}
+}
+
+func (m *moduleWriter) VisitLiteralExpr(e *LiteralExpr) {
+	if e.value {
+		m.ret("1'b1", bit)
+	} else {
+		m.ret("1'b0", bit)
+	}
+}
+
+func (m *moduleWriter) VisitSeqExpr(e *SeqExpr) {
+	if len(e.items) == 0 {
+		m.fail(errorAt(e.Span(), "Cannot export an empty sequence."))
+		return
+	}
+
+	var items []string
+	s := shape{list: true}
+
+	for _, item := range e.items {
+		code, is := m.compile(item)
+		items = append(items, code)
+		s.items = append(s.items, is)
+	}
+
+	m.ret(fmt.Sprintf("{%s}", strings.Join(items, ", ")), s)
+}
+
+func (m *moduleWriter) VisitNumberExpr(e *NumberExpr) {
+	m.fail(errorAt(e.Span(), "Cannot export `%s`, numbers can only be used "+
+		"as sequence indexes.", e.tok.lexeme))
+}
+
+// Exports a gate, along with every gate it calls, as Verilog modules. Gate
+// arguments that are indexed or passed to gates that expect sequences become
+// bit vectors, and registers are clocked by a `clk` input.
+func (rt *Runtime) Verilog(name string) (string, error) {
+	g, ok := rt.env.getGate(name)
+
+	if !ok {
+		return "", &Error{Phase: EvalPhase, Errors: []error{
+			fmt.Errorf("Undefined gate `%s`", name)}}
+	}
+
+	x := newVerilogExporter()
+
+	if _, errs := x.signature(g); len(errs) > 0 {
+		return "", &Error{Phase: EvalPhase, Errors: errs}
+	}
+
+	return strings.Join(x.modules, "\n"), nil
+}
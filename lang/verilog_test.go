@@ -0,0 +1,130 @@
+package lang
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestVerilog(t *testing.T) {
+	rt := newTestRuntime(t,
+		"gate Xor (a, b) = (a ∨ b) ∧ ¬(a ∧ b)",
+		"gate Half (a, b) = [Xor(a, b), a ∧ b]",
+		"gate Shift (in) = s",
+		"  where s is reg([in, s(0), s(1)], [0, 0, 0])",
+	)
+
+	tests := []struct {
+		gate string
+		exp  []string
+	}{
+		{"Xor", []string{
+			"module Xor (",
+			"    input wire a,",
+			"    input wire b,",
+			"    output wire out",
+			");",
+			"    assign out = ((a | b) & ~(a & b));",
+			"endmodule",
+		}},
+		{"Half", []string{
+			"module Xor (",
+			"    input wire a,",
+			"    input wire b,",
+			"    output wire out",
+			");",
+			"    assign out = ((a | b) & ~(a & b));",
+			"endmodule",
+			"",
+			"module Half (",
+			"    input wire a,",
+			"    input wire b,",
+			"    output wire [0:1] out",
+			");",
+			"    wire _w0;",
+			"",
+			"    Xor _u0 (.a(a), .b(b), .out(_w0));",
+			"    assign out = {_w0, (a & b)};",
+			"endmodule",
+		}},
+		{"Shift", []string{
+			"module Shift (",
+			"    input wire clk,",
+			"    input wire in,",
+			"    output wire [0:2] out",
+			");",
+			"    reg [0:2] _r0 = {1'b0, 1'b0, 1'b0};",
+			"    wire [0:2] s;",
+			"",
+			"    always @(posedge clk) _r0 <= {in, s[0], s[1]};",
+			"    assign s = _r0;",
+			"    assign out = s;",
+			"endmodule",
+		}},
+	}
+
+	for _, test := range tests {
+		out, err := rt.Verilog(test.gate)
+
+		if err != nil {
+			t.Errorf("Verilog(%q) error = %v", test.gate, err)
+			continue
+		}
+
+		exp := strings.Join(test.exp, "\n") + "\n"
+
+		if out != exp {
+			t.Errorf("Verilog(%q) got:\n%s\nexpected:\n%s", test.gate, out, exp)
+		}
+	}
+}
+
+func TestVerilogVectors(t *testing.T) {
+	rt := newTestRuntime(t, `import "std/adders.bool"`)
+
+	out, err := rt.Verilog("adders.Add8")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, line := range []string{
+		"module Add8 (",
+		"    input wire [0:7] x,",
+		"    output wire [0:7] out",
+		"    Add8c _u0 (.x(x), .y(y), .c(1'b0), .out(_w0));",
+		"module Add4c (",
+		"module FullAdder (",
+	} {
+		if !strings.Contains(out, line+"\n") {
+			t.Errorf("expected output to contain %q, got:\n%s", line, out)
+		}
+	}
+}
+
+func TestVerilogErrors(t *testing.T) {
+	tests := []struct {
+		src string
+		err string
+	}{
+		{"k is 1\ngate G (a) = a ∧ k", "Cannot export `k` since it is not an argument or a local binding of `G`."},
+		{"gate G (a, i) = a(i)", "only constant indexes can be exported."},
+		{"gate G (a) = G(a)", "Cannot export `G` since it calls itself."},
+		{"gate G (a) = a(1) ∧ a", "Cannot export `∧` on sequences, it expects single bits."},
+		{"gate G (a) = []", "Cannot export an empty sequence."},
+		{"gate F (a) = [a, a]\ngate G (a) = F(a) = a", "Cannot export `=` on values that are 2 and 1 bits wide."},
+	}
+
+	for _, test := range tests {
+		rt := newTestRuntime(t, test.src)
+
+		_, err := rt.Verilog("G")
+
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("Verilog(%q) error = %v, expected %q", test.src, err, test.err)
+		}
+	}
+
+	if _, err := NewRuntime().Verilog("G"); err == nil {
+		t.Errorf("expected an error for an undefined gate")
+	}
+}
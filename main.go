@@ -2,239 +2,850 @@ package main
 
 import (
 	"bufio"
+	"bytes"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"math/big"
 	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
 
-	"github.com/davecgh/go-spew/spew"
+	"github.com/minond/bool/lang"
 )
 
 const (
-	// For > .mode MODE
-	scanMode  = "scan"
-	parseMode = "parse"
-	printMode = "print"
-	evalMode  = "eval"
-
-	// For > MODE: expression
-	scanLine  = "scan:"
-	parseLine = "parse:"
-	evalLine  = "eval:"
-	printLine = "print:"
-
-	setMode = ".mode "
+	setMode   = ".mode "
+	setTable  = ".table "
+	setTick   = ".tick "
+	setSim    = ".simulate "
+	setDot    = ".dot "
+	setEquiv  = ".equiv "
+	setSat    = ".sat "
+	setTaut   = ".taut "
+	setContra = ".contra "
+	setSimp   = ".simplify "
+	setBdd    = ".bdd "
+	setCount  = ".count "
+	setProb   = ".prob "
+	setKmap   = ".kmap "
+	setSynth  = ".synth "
+	setMapTo  = ".mapto "
 
 	cmdHelp     = ".help"
+	cmdHistory  = ".history"
 	cmdKeyboard = ".keyboard"
 	cmdMode     = ".mode"
 	cmdQuit     = ".quit"
 	cmdReset    = ".reset"
 	cmdPaste    = ".paste"
+	cmdTable    = ".table"
+	cmdTick     = ".tick"
+	cmdSim      = ".simulate"
+	cmdDot      = ".dot"
+	cmdEquiv    = ".equiv"
+	cmdSat      = ".sat"
+	cmdTaut     = ".taut"
+	cmdContra   = ".contra"
+	cmdSimp     = ".simplify"
+	cmdCNF      = ".cnf"
+	cmdDNF      = ".dnf"
+	cmdNNF      = ".nnf"
+	cmdANF      = ".anf"
+	cmdBdd      = ".bdd"
+	cmdCount    = ".count"
+	cmdProb     = ".prob"
+	cmdKmap     = ".kmap"
+	cmdSynth    = ".synth"
+	cmdMapTo    = ".mapto"
+
+	// For $ bool SUBCOMMAND
+	subRun    = "run"
+	subStdin  = "-"
+	subTable  = "table"
+	subExport = "export"
+
+	// For $ bool export FORMAT GATE FILE
+	fmtVerilog = "--verilog"
+	fmtDot     = "--dot"
+
+	// Draws gates called by the exported gate inline instead of as boxes.
+	optInline = "--inline"
 )
 
 func main() {
-	var prevGate *gate
+	if len(os.Args) > 1 {
+		os.Exit(cli(os.Args[1:]))
+	}
 
-	reader := bufio.NewReader(os.Stdin)
-	env := newEnvironment(nil)
-	mode := evalMode
-	pasting := false
+	repl(os.Stdin, os.Stdout)
+}
 
-	for {
-		if !pasting {
-			fmt.Print("> ")
+// Runs a subcommand and returns the exit code.
+func cli(args []string) int {
+	switch args[0] {
+	case subRun:
+		if len(args) != 2 {
+			fmt.Fprintf(os.Stderr, "usage: bool %s FILE\n", subRun)
+			return 2
 		}
 
-		text, _ := reader.ReadString('\n')
-		text = strings.TrimSpace(text)
+		src, err := ioutil.ReadFile(args[1])
 
-		switch text {
-		case cmdQuit:
-			fmt.Println("< Goodbye")
-			return
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %s\n", err)
+			return 1
+		}
 
-		case cmdMode:
-			fmt.Printf("< %s mode\n\n", mode)
+		return report(lang.NewRuntime().Run(args[1], string(src), os.Stdout))
 
-		case cmdReset:
-			fmt.Println("< clearing environment\n\n")
-			env = newEnvironment(nil)
+	case subStdin:
+		src, err := ioutil.ReadAll(os.Stdin)
 
-		case cmdHelp:
-			fmt.Printf("< %s: reset current environment.\n", cmdReset)
-			fmt.Printf("< %s: display or change evaluation mode to %s, %s, %s, or %s.\n", cmdMode, scanMode, parseMode, printMode, evalMode)
-			fmt.Printf("< %s: print a keyboard with valid operations and their ascii representation.\n", cmdKeyboard)
-			fmt.Printf("< %s: toggle paste mode.\n", cmdPaste)
-			fmt.Printf("< %s: view this help text.\n", cmdHelp)
-			fmt.Printf("< %s: exit program.\n", cmdQuit)
-			fmt.Println()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %s\n", err)
+			return 1
+		}
 
-		case cmdKeyboard:
-			fmt.Printf("< conjunction: %s or %s\n", string(andRn), string(andAsciiRn))
-			fmt.Printf("< disjunction: %s or %s\n", string(orRn), string(orAsciiRn))
-			fmt.Printf("< negation: %s or %s\n", string(notRn), string(notAsciiRn))
-			fmt.Printf("< exclusive or: %s or %s\n", string(xorRn), string(xorAsciiRn))
-			fmt.Printf("< equivalence: %s or %s\n", string(eqRn), string(eqAsciiRn))
-			fmt.Printf("< material implication: %s\n", string(miRn))
-			fmt.Println()
+		return report(lang.NewRuntime().Run("<stdin>", string(src), os.Stdout))
 
-		case cmdPaste:
-			pasting = !pasting
+	case subTable:
+		if len(args) != 2 && len(args) != 3 {
+			fmt.Fprintf(os.Stderr, "usage: bool %s EXPR [FILE]\n", subTable)
+			return 2
+		}
 
-			if pasting {
-				fmt.Println("< paste mode: on")
-			} else {
-				fmt.Println("< paste mode: off")
+		rt := lang.NewRuntime()
+
+		if len(args) == 3 {
+			src, err := ioutil.ReadFile(args[2])
+
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "error: %s\n", err)
+				return 1
 			}
 
-		default:
-			if text == "" {
-				continue
-			} else if strings.HasPrefix(text, setMode) {
-				maybeMode := strings.TrimSpace(strings.TrimPrefix(text, setMode))
-				switch maybeMode {
-				case scanMode:
-					mode = scanMode
-				case parseMode:
-					mode = parseMode
-				case evalMode:
-					mode = evalMode
-				case printMode:
-					mode = printMode
-				default:
-					fmt.Printf("< error: Invalid mode `%s`\n\n", maybeMode)
-					continue
-				}
+			if code := report(rt.Run(args[2], string(src), ioutil.Discard)); code != 0 {
+				return code
+			}
+		}
 
-				fmt.Printf("< switching to %s mode\n\n", mode)
-			} else if strings.HasPrefix(text, ".") {
-				fmt.Printf("< error: Unknown command: `%s`. Enter `.help` for help.\n\n", text)
-			} else if mode == scanMode || strings.HasPrefix(text, scanLine) {
-				for _, t := range scan(strings.TrimPrefix(text, scanLine)) {
-					fmt.Printf("< %04d %s\n", t.pos, t)
-				}
+		lines, err := rt.Table(args[1])
 
-				fmt.Println()
-			} else if mode == printMode || strings.HasPrefix(text, printLine) {
-				thing := strings.TrimSpace(strings.TrimPrefix(text, printLine))
-
-				if e, ok := env.getBinding(thing); ok {
-					spew.Dump(e)
-					fmt.Println()
-				} else if e, ok := env.getGate(thing); ok {
-					spew.Dump(e)
-					fmt.Println()
-				} else {
-					fmt.Printf("< Error, cannot find `%s` in current environment.\n\n", thing)
-				}
-			} else if mode == parseMode || strings.HasPrefix(text, parseLine) {
-				spew.Dump(parse(scan(strings.TrimPrefix(text, parseLine))))
-				fmt.Println()
-			} else if mode == evalMode || strings.HasPrefix(text, evalLine) {
-				// FIXME This is really ugly. This to clean up:
-				//
-				//   1. I don't like that there is a need for an isBinding flag
-				//   that gets used at the end. This should be somehow cleaner.
-				//
-				//   2. A lot of the error checking and printing should be in a
-				//   separate function so main doesn't get messy.
-				//
-				//   3. I'm making this a little worse with the whole local to
-				//   gate only bindings here. Where should this live? I'm some
-				//   sort of "runtime" is needed for this type of thing.
-				isExpr := false
-				isLocal := false
-
-				toks := scan(strings.TrimPrefix(text, evalLine))
-				expr, parseErrors := parse(toks)
-
-				if len(parseErrors) > 0 {
-					fmt.Println("< error: Cannot parse expression due to errors:")
-
-					for _, err := range parseErrors {
-						fmt.Printf("< error: %s\n", err)
-					}
+		if code := report(err); code != 0 {
+			return code
+		}
 
-					fmt.Println()
-					continue
-				}
+		for _, line := range lines {
+			fmt.Println(line)
+		}
 
-				switch v := expr.(type) {
-				case binding:
-					isLocal = toks[0].id == bindContTok
+		return 0
 
-					if !isLocal {
-						prevGate = nil
-					}
+	case subExport:
+		return export(args[1:])
 
-				case *gate:
-					prevGate = v
-					newEnv := newEnvironment(&env)
-					v.env = &newEnv
+	default:
+		fmt.Fprintf(os.Stderr, "error: Unknown subcommand `%s`\n", args[0])
+		fmt.Fprintf(os.Stderr, "usage: bool [%s FILE | %s | %s EXPR [FILE] | %s FORMAT GATE FILE]\n",
+			subRun, subStdin, subTable, subExport)
+		return 2
+	}
+}
 
-				default:
-					prevGate = nil
-					isExpr = true
-				}
+// Prints a gate declared in a file in another format, which is either
+// Verilog, with a module for every gate the gate calls, or a Graphviz graph.
+func export(args []string) int {
+	inline := len(args) == 4 && args[0] == fmtDot && args[1] == optInline
 
-				var ret value
-				var evalErrors []error
+	if inline {
+		args = append(args[:1], args[2:]...)
+	}
 
-				if isLocal && prevGate != nil {
-					ret, evalErrors = expr.eval(*prevGate.env)
-				} else if isLocal {
-					fmt.Println("< error: Binding continuation used outside of gate scope.\n")
-					continue
-				} else {
-					ret, evalErrors = expr.eval(env)
-				}
+	if len(args) != 3 || (args[0] != fmtVerilog && args[0] != fmtDot) {
+		fmt.Fprintf(os.Stderr, "usage: bool %s [%s | %s [%s]] GATE FILE\n",
+			subExport, fmtVerilog, fmtDot, optInline)
+		return 2
+	}
 
-				if len(evalErrors) > 0 {
-					fmt.Println("< error: Cannot evaluate expression due to errors:")
+	src, err := ioutil.ReadFile(args[2])
 
-					for _, err := range evalErrors {
-						fmt.Printf("< error: %s\n", err)
-					}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %s\n", err)
+		return 1
+	}
 
-					fmt.Println()
-					continue
-				}
+	rt := lang.NewRuntime()
 
-				if isExpr {
-					fmt.Printf("= %s\n\n", print(ret, env))
-				}
+	if code := report(rt.Run(args[2], string(src), ioutil.Discard)); code != 0 {
+		return code
+	}
+
+	var out string
+
+	if args[0] == fmtVerilog {
+		out, err = rt.Verilog(args[1])
+	} else {
+		out, err = rt.Dot(args[1], inline)
+	}
+
+	if code := report(err); code != 0 {
+		return code
+	}
+
+	fmt.Print(out)
+	return 0
+}
+
+func report(err error) int {
+	if err == nil {
+		return 0
+	}
+
+	for _, err := range errorList(err) {
+		for _, line := range lang.FormatError(err) {
+			fmt.Fprintln(os.Stderr, line)
+		}
+	}
+
+	return 1
+}
+
+// Prints errors in the repl, where every error is printed along with the
+// line of source code it came from.
+func printErrors(out io.Writer, header string, err error) {
+	fmt.Fprintf(out, "< error: %s\n", header)
+
+	for _, err := range errorList(err) {
+		for i, line := range lang.FormatError(err) {
+			if i == 0 {
+				fmt.Fprintf(out, "< error: %s\n", line)
+			} else {
+				fmt.Fprintf(out, "< %s\n", line)
 			}
 		}
 	}
+
+	fmt.Fprintln(out)
+}
+
+func errorList(err error) []error {
+	if lerr, ok := err.(*lang.Error); ok {
+		return lerr.Errors
+	}
+
+	return []error{err}
 }
 
-func print(v value, env environment) string {
-	if v.isBoolean() {
-		return fmt.Sprintf("%t", v.boolean.internal)
-	} else if v.isSequence() {
-		buff := fmt.Sprintf("Seq[%d]{", len(v.sequence.internal))
+func printResults(out io.Writer, results []lang.Result) {
+	for _, res := range results {
+		if lerr, ok := res.Err.(*lang.Error); ok {
+			printErrors(out, fmt.Sprintf("Cannot %s expression due to errors:", lerr.Phase), res.Err)
+			continue
+		}
+
+		switch res.Kind {
+		case lang.ValueResult:
+			fmt.Fprintf(out, "= %s\n\n", res.Value)
 
-		for i, expr := range v.sequence.internal {
-			if i != 0 {
-				buff += ", "
+		case lang.TextResult:
+			for _, line := range res.Lines {
+				fmt.Fprintf(out, "< %s\n", line)
 			}
 
-			ret, _ := expr.eval(env)
-			out := print(ret, env)
+			fmt.Fprintln(out)
+		}
+	}
+}
+
+// Prints the truth table of the expression or gate given to the .table
+// command.
+func table(out io.Writer, rt *lang.Runtime, src string) {
+	if strings.TrimSpace(src) == "" {
+		fmt.Fprintf(out, "< error: usage: %s EXPR|GATE\n\n", cmdTable)
+		return
+	}
+
+	lines, err := rt.Table(src)
+
+	if err != nil {
+		printErrors(out, "Cannot build truth table due to errors:", err)
+		return
+	}
+
+	for _, line := range lines {
+		fmt.Fprintf(out, "< %s\n", line)
+	}
+
+	fmt.Fprintln(out)
+}
+
+// Advances the clock by the number of cycles in arg, or by one cycle when it
+// is empty.
+func tick(out io.Writer, rt *lang.Runtime, arg string) {
+	cycles := 1
+
+	if arg != "" {
+		n, err := strconv.Atoi(arg)
+
+		if err != nil || n < 1 {
+			fmt.Fprintf(out, "< error: Invalid number of cycles `%s`\n\n", arg)
+			return
+		}
+
+		cycles = n
+	}
+
+	for i := 0; i < cycles; i++ {
+		if err := rt.Step(); err != nil {
+			printErrors(out, "Cannot advance the clock due to errors:", err)
+			return
+		}
+	}
+
+	fmt.Fprintf(out, "< cycle %d\n\n", rt.Cycle())
+}
+
+// Runs a simulation with the arguments to the .simulate command, which are
+// the number of steps, the stimulus file, and the optional output file. The
+// output defaults to the stimulus file with a .vcd extension.
+func simulate(out io.Writer, rt *lang.Runtime, args []string) {
+	if len(args) != 2 && len(args) != 3 {
+		fmt.Fprintf(out, "< error: usage: %s N STIMULUS [OUT]\n\n", cmdSim)
+		return
+	}
+
+	steps, err := strconv.Atoi(args[0])
+
+	if err != nil || steps < 1 {
+		fmt.Fprintf(out, "< error: Invalid number of steps `%s`\n\n", args[0])
+		return
+	}
+
+	src, err := ioutil.ReadFile(args[1])
+
+	if err != nil {
+		fmt.Fprintf(out, "< error: %s\n\n", err)
+		return
+	}
+
+	name := strings.TrimSuffix(args[1], filepath.Ext(args[1])) + ".vcd"
+
+	if len(args) == 3 {
+		name = args[2]
+	}
+
+	var buff bytes.Buffer
+
+	if err := rt.Simulate(steps, args[1], string(src), &buff); err != nil {
+		printErrors(out, "Cannot run simulation due to errors:", err)
+		return
+	}
+
+	if err := ioutil.WriteFile(name, buff.Bytes(), 0644); err != nil {
+		fmt.Fprintf(out, "< error: %s\n\n", err)
+		return
+	}
+
+	fmt.Fprintf(out, "< wrote %d steps to %s\n\n", steps, name)
+}
+
+// Prints the graph for the gate in the arguments to the .dot command, which
+// can start with an option to draw the gates it calls inline.
+func dot(out io.Writer, rt *lang.Runtime, args []string) {
+	inline := len(args) == 2 && args[0] == optInline
+
+	if inline {
+		args = args[1:]
+	}
+
+	if len(args) != 1 {
+		fmt.Fprintf(out, "< error: usage: %s [%s] GATE\n\n", cmdDot, optInline)
+		return
+	}
+
+	graph, err := rt.Dot(args[0], inline)
+
+	if err != nil {
+		printErrors(out, "Cannot draw gate due to errors:", err)
+		return
+	}
+
+	for _, line := range strings.Split(strings.TrimSuffix(graph, "\n"), "\n") {
+		fmt.Fprintf(out, "< %s\n", line)
+	}
+
+	fmt.Fprintln(out)
+}
+
+// Compares the two gates in the arguments to the .equiv command.
+func equiv(out io.Writer, rt *lang.Runtime, args []string) {
+	if len(args) != 2 {
+		fmt.Fprintf(out, "< error: usage: %s GATE GATE\n\n", cmdEquiv)
+		return
+	}
+
+	eq, err := rt.Equiv(args[0], args[1])
+
+	if err != nil {
+		printErrors(out, "Cannot compare gates due to errors:", err)
+		return
+	}
+
+	for _, line := range eq.Lines() {
+		fmt.Fprintf(out, "< %s\n", line)
+	}
 
-			if out == "true" {
-				out = "1"
-			} else if out == "false" {
-				out = "0"
+	fmt.Fprintln(out)
+}
+
+// Looks for values that make the expression given to the .sat command true.
+func sat(out io.Writer, rt *lang.Runtime, src string) {
+	if strings.TrimSpace(src) == "" {
+		fmt.Fprintf(out, "< error: usage: %s EXPR\n\n", cmdSat)
+		return
+	}
+
+	a, ok, err := rt.Sat(src)
+
+	switch {
+	case err != nil:
+		printErrors(out, "Cannot solve expression due to errors:", err)
+		return
+	case !ok:
+		fmt.Fprintln(out, "< unsatisfiable")
+	case len(a.Names) == 0:
+		fmt.Fprintln(out, "< satisfiable")
+	default:
+		fmt.Fprintf(out, "< satisfiable when %s\n", a)
+	}
+
+	fmt.Fprintln(out)
+}
+
+// Answers whether the expression given to the .taut or .contra command is
+// always true or always false, printing values that show it is not.
+func always(out io.Writer, cmd string, check func(string) (lang.Assignment, bool, error), src string) {
+	if strings.TrimSpace(src) == "" {
+		fmt.Fprintf(out, "< error: usage: %s EXPR\n\n", cmd)
+		return
+	}
+
+	a, ok, err := check(src)
+	other := "false"
+
+	if cmd == cmdContra {
+		other = "true"
+	}
+
+	switch {
+	case err != nil:
+		printErrors(out, "Cannot check expression due to errors:", err)
+		return
+	case ok:
+		fmt.Fprintln(out, "< yes")
+	case len(a.Names) == 0:
+		fmt.Fprintf(out, "< no, it is %s\n", other)
+	default:
+		fmt.Fprintf(out, "< no, it is %s when %s\n", other, a)
+	}
+
+	fmt.Fprintln(out)
+}
+
+// Prints the smallest sum of products for the expression or gate given to the
+// .simplify command.
+func simplify(out io.Writer, rt *lang.Runtime, src string) {
+	if strings.TrimSpace(src) == "" {
+		fmt.Fprintf(out, "< error: usage: %s EXPR|GATE\n\n", cmdSimp)
+		return
+	}
+
+	sop, err := rt.Simplify(src)
+
+	if err != nil {
+		printErrors(out, "Cannot simplify expression due to errors:", err)
+		return
+	}
+
+	fmt.Fprintf(out, "< %s\n\n", sop)
+}
+
+// Splits a .cnf, .dnf, .nnf, or .anf command into the normal form it asks for
+// and the expression to rewrite.
+func normalForm(text string) (lang.Form, string, bool) {
+	for _, form := range lang.Forms {
+		if cmd := "." + string(form); text == cmd || strings.HasPrefix(text, cmd+" ") {
+			return form, strings.TrimPrefix(text, cmd), true
+		}
+	}
+
+	return "", "", false
+}
+
+// Rewrites the expression given to the .cnf, .dnf, .nnf, or .anf command in
+// the normal form it asks for.
+func normalize(out io.Writer, rt *lang.Runtime, form lang.Form, src string) {
+	if strings.TrimSpace(src) == "" {
+		fmt.Fprintf(out, "< error: usage: .%s EXPR\n\n", form)
+		return
+	}
+
+	res, err := rt.Normalize(src, form)
+
+	if err != nil {
+		printErrors(out, "Cannot rewrite expression due to errors:", err)
+		return
+	}
+
+	fmt.Fprintf(out, "< %s\n\n", res)
+}
+
+// Describes the binary decision diagram of the expression or gate given to
+// the .bdd command.
+func bdd(out io.Writer, rt *lang.Runtime, src string) {
+	if strings.TrimSpace(src) == "" {
+		fmt.Fprintf(out, "< error: usage: %s EXPR|GATE\n\n", cmdBdd)
+		return
+	}
+
+	d, err := rt.BDD(src)
+
+	if err != nil {
+		printErrors(out, "Cannot build BDD due to errors:", err)
+		return
+	}
+
+	total := new(big.Int).Lsh(big.NewInt(1), uint(len(d.Order)))
+
+	fmt.Fprintf(out, "< %d nodes, %d before sifting\n", d.Nodes, d.Unsifted)
+
+	if len(d.Order) > 0 {
+		fmt.Fprintf(out, "< variable order: %s\n", strings.Join(d.Order, ", "))
+	}
+
+	if len(d.Counts) == 1 {
+		fmt.Fprintf(out, "< satisfied by %s of %s assignments\n", d.Counts[0], total)
+	} else {
+		for i, count := range d.Counts {
+			fmt.Fprintf(out, "< item %d is satisfied by %s of %s assignments\n", i, count, total)
+		}
+	}
+
+	fmt.Fprintln(out)
+}
+
+// Prints the number of assignments that make the expression given to the
+// .count command true.
+func count(out io.Writer, rt *lang.Runtime, src string) {
+	if strings.TrimSpace(src) == "" {
+		fmt.Fprintf(out, "< error: usage: %s EXPR|GATE\n\n", cmdCount)
+		return
+	}
+
+	m, err := rt.Count(src)
+
+	if err != nil {
+		printErrors(out, "Cannot count assignments due to errors:", err)
+		return
+	}
+
+	total := new(big.Int).Lsh(big.NewInt(1), uint(len(m.Inputs)))
+
+	if len(m.Counts) == 1 {
+		fmt.Fprintf(out, "< %s of %s assignments\n", m.Counts[0], total)
+	} else {
+		for i, count := range m.Counts {
+			fmt.Fprintf(out, "< item %d: %s of %s assignments\n", i, count, total)
+		}
+	}
+
+	fmt.Fprintln(out)
+}
+
+// Prints the probability that the expression given to the .prob command is
+// true. The probabilities of its inputs follow the expression and `with`.
+func prob(out io.Writer, rt *lang.Runtime, src string) {
+	probs := make(map[string]*big.Rat)
+
+	if i := strings.LastIndex(src, " with "); i >= 0 {
+		for _, pair := range strings.Split(src[i+len(" with "):], ",") {
+			name, val, ok := strings.Cut(pair, "=")
+			r, valid := new(big.Rat).SetString(strings.TrimSpace(val))
+
+			if !ok || !valid || strings.TrimSpace(name) == "" {
+				fmt.Fprintf(out, "< error: Invalid probability `%s`\n\n", strings.TrimSpace(pair))
+				return
 			}
 
-			buff += fmt.Sprintf("%s", out)
+			probs[strings.TrimSpace(name)] = r
 		}
 
-		return buff + "}"
-	} else if v.isNumber() {
-		return fmt.Sprintf("%d", v.number)
+		src = src[:i]
+	}
+
+	if strings.TrimSpace(src) == "" {
+		fmt.Fprintf(out, "< error: usage: %s EXPR [with X=P, ...]\n\n", cmdProb)
+		return
+	}
+
+	res, err := rt.Prob(src, probs)
+
+	if err != nil {
+		printErrors(out, "Cannot compute probability due to errors:", err)
+		return
+	}
+
+	if len(res) == 1 {
+		fmt.Fprintf(out, "< %s\n", probability(res[0]))
 	} else {
-		return "Error"
+		for i, r := range res {
+			fmt.Fprintf(out, "< item %d: %s\n", i, probability(r))
+		}
+	}
+
+	fmt.Fprintln(out)
+}
+
+// Formats a probability as a decimal, along with the fraction when the
+// decimal is rounded.
+func probability(r *big.Rat) string {
+	dec := strings.TrimRight(r.FloatString(10), "0")
+	dec = strings.TrimSuffix(dec, ".")
+
+	if exact, _ := new(big.Rat).SetString(dec); exact.Cmp(r) != 0 {
+		return fmt.Sprintf("%s ≈ %s", r.RatString(), dec)
+	}
+
+	return dec
+}
+
+// Draws the Karnaugh map of the expression or gate given to the .kmap command.
+func kmap(out io.Writer, rt *lang.Runtime, src string) {
+	if strings.TrimSpace(src) == "" {
+		fmt.Fprintf(out, "< error: usage: %s EXPR|GATE\n\n", cmdKmap)
+		return
+	}
+
+	lines, err := rt.Kmap(src)
+
+	if err != nil {
+		printErrors(out, "Cannot build Karnaugh map due to errors:", err)
+		return
+	}
+
+	for _, line := range lines {
+		fmt.Fprintf(out, "< %s\n", line)
+	}
+
+	fmt.Fprintln(out)
+}
+
+// Rewrites the gate given to the .mapto command using a single kind of
+// universal gate.
+func mapTo(out io.Writer, rt *lang.Runtime, args []string) {
+	if len(args) != 2 && (len(args) != 4 || args[2] != "as") {
+		fmt.Fprintf(out, "< error: usage: %s nand|nor GATE [as NAME]\n\n", cmdMapTo)
+		return
+	}
+
+	as := ""
+
+	if len(args) == 4 {
+		as = args[3]
+	}
+
+	to := lang.Universal(strings.ToLower(args[0]))
+	m, err := rt.MapTo(args[1], to, as)
+
+	if err != nil {
+		printErrors(out, "Cannot map gate due to errors:", err)
+		return
+	}
+
+	fmt.Fprintf(out, "< %s\n", m.Decl)
+	gates := "gates"
+
+	if m.Gates == 1 {
+		gates = "gate"
+	}
+
+	fmt.Fprintf(out, "< %d %s %s, depth %d\n\n", m.Gates, strings.ToUpper(string(to)), gates, m.Depth)
+}
+
+func repl(in io.Reader, out io.Writer) {
+	reader := bufio.NewReader(in)
+	rt := lang.NewRuntime()
+	pasting := false
+
+	// Lines of a statement that continues on the next line, such as a gate
+	// followed by its `where` clauses.
+	var pending []string
+
+	for {
+		if !pasting && len(pending) > 0 {
+			fmt.Fprint(out, "... ")
+		} else if !pasting {
+			fmt.Fprint(out, "> ")
+		}
+
+		text, err := reader.ReadString('\n')
+
+		if err != nil && text == "" {
+			if len(pending) > 0 {
+				printResults(out, rt.Exec(strings.Join(pending, "\n")))
+			}
+
+			fmt.Fprintln(out)
+			return
+		}
+
+		text = strings.TrimSpace(text)
+
+		// A gate waits for the next line, which is added to it when it is a
+		// `where` or `and` clause. Any other line runs the gate first.
+		if len(pending) > 0 {
+			if rt.Continues(text) {
+				pending = append(pending, text)
+				continue
+			}
+
+			printResults(out, rt.Exec(strings.Join(pending, "\n")))
+			pending = nil
+		}
+
+		switch text {
+		case cmdQuit:
+			fmt.Fprintln(out, "< Goodbye")
+			return
+
+		case cmdMode:
+			fmt.Fprintf(out, "< %s mode\n\n", rt.Settings.Mode)
+
+		case cmdReset:
+			fmt.Fprint(out, "< clearing environment\n\n")
+			settings := rt.Settings
+			rt = lang.NewRuntime()
+			rt.Settings = settings
+
+		case cmdHistory:
+			for i, entry := range rt.History() {
+				for j, line := range strings.Split(entry, "\n") {
+					if j == 0 {
+						fmt.Fprintf(out, "< %d: %s\n", i+1, line)
+					} else {
+						fmt.Fprintf(out, "<    %s\n", line)
+					}
+				}
+			}
+
+			fmt.Fprintln(out)
+
+		case cmdHelp:
+			fmt.Fprintf(out, "< %s: reset current environment.\n", cmdReset)
+			fmt.Fprintf(out, "< %s: display or change evaluation mode to %s, %s, %s, or %s.\n", cmdMode, lang.ScanMode, lang.ParseMode, lang.PrintMode, lang.EvalMode)
+			fmt.Fprintf(out, "< %s: print a keyboard with valid operations and their ascii representation.\n", cmdKeyboard)
+			fmt.Fprintf(out, "< %s: toggle paste mode.\n", cmdPaste)
+			fmt.Fprintf(out, "< %s: print a truth table for an expression or a gate.\n", cmdTable)
+			fmt.Fprintf(out, "< %s: advance the clock by one or more cycles, latching every register.\n", cmdTick)
+			fmt.Fprintf(out, "< %s N STIMULUS [OUT]: simulate N cycles driven by a stimulus file and write a VCD file.\n", cmdSim)
+			fmt.Fprintf(out, "< %s [%s] GATE: print a Graphviz graph of a gate, optionally drawing the gates it calls inline.\n", cmdDot, optInline)
+			fmt.Fprintf(out, "< %s GATE GATE: check that two gates return the same value for every input.\n", cmdEquiv)
+			fmt.Fprintf(out, "< %s EXPR: find values for the unbound identifiers in an expression that make it true.\n", cmdSat)
+			fmt.Fprintf(out, "< %s EXPR: check that an expression is true for every value of its unbound identifiers.\n", cmdTaut)
+			fmt.Fprintf(out, "< %s EXPR: check that an expression is false for every value of its unbound identifiers.\n", cmdContra)
+			fmt.Fprintf(out, "< %s EXPR|GATE: print the smallest sum of products for an expression or a gate.\n", cmdSimp)
+			fmt.Fprintf(out, "< %s EXPR: rewrite an expression in conjunctive normal form.\n", cmdCNF)
+			fmt.Fprintf(out, "< %s EXPR: rewrite an expression in disjunctive normal form.\n", cmdDNF)
+			fmt.Fprintf(out, "< %s EXPR: rewrite an expression in negation normal form.\n", cmdNNF)
+			fmt.Fprintf(out, "< %s EXPR: rewrite an expression in algebraic normal form.\n", cmdANF)
+			fmt.Fprintf(out, "< %s EXPR|GATE: build the binary decision diagram of an expression or a gate and count its satisfying assignments.\n", cmdBdd)
+			fmt.Fprintf(out, "< %s EXPR|GATE: count the assignments of the unbound identifiers in an expression that make it true.\n", cmdCount)
+			fmt.Fprintf(out, "< %s EXPR [with X=P, ...]: compute the probability that an expression is true, where X is true with probability P or 0.5.\n", cmdProb)
+			fmt.Fprintf(out, "< %s EXPR|GATE: print a Karnaugh map for an expression or a gate with the groups chosen by %s.\n", cmdKmap, cmdSimp)
+			fmt.Fprintf(out, "< %s NAME [(ARGS)] TABLE: declare a gate from a truth table given as rows like `01 -> 1, 1- -> 0` or as output bits like `0110`.\n", cmdSynth)
+			fmt.Fprintf(out, "< %s nand|nor GATE [as NAME]: rewrite a gate using only NAND or NOR gates, optionally declaring it as a new gate.\n", cmdMapTo)
+			fmt.Fprintf(out, "< %s: list every line entered in the current environment.\n", cmdHistory)
+			fmt.Fprintf(out, "< %s: view this help text.\n", cmdHelp)
+			fmt.Fprintf(out, "< %s: exit program.\n", cmdQuit)
+			fmt.Fprintln(out)
+
+		case cmdKeyboard:
+			for _, line := range lang.Keyboard() {
+				fmt.Fprintf(out, "< %s\n", line)
+			}
+
+			fmt.Fprintln(out)
+
+		case cmdPaste:
+			pasting = !pasting
+
+			if pasting {
+				fmt.Fprintln(out, "< paste mode: on")
+			} else {
+				fmt.Fprintln(out, "< paste mode: off")
+			}
+
+		default:
+			if text == "" {
+				continue
+			} else if strings.HasPrefix(text, setMode) {
+				mode := lang.Mode(strings.TrimSpace(strings.TrimPrefix(text, setMode)))
+
+				if !mode.Valid() {
+					fmt.Fprintf(out, "< error: Invalid mode `%s`\n\n", mode)
+					continue
+				}
+
+				rt.Settings.Mode = mode
+				fmt.Fprintf(out, "< switching to %s mode\n\n", mode)
+			} else if text == cmdTable || strings.HasPrefix(text, setTable) {
+				table(out, rt, strings.TrimPrefix(text, cmdTable))
+			} else if text == cmdDot || strings.HasPrefix(text, setDot) {
+				dot(out, rt, strings.Fields(strings.TrimPrefix(text, cmdDot)))
+			} else if text == cmdEquiv || strings.HasPrefix(text, setEquiv) {
+				equiv(out, rt, strings.Fields(strings.TrimPrefix(text, cmdEquiv)))
+			} else if text == cmdSat || strings.HasPrefix(text, setSat) {
+				sat(out, rt, strings.TrimPrefix(text, cmdSat))
+			} else if text == cmdTaut || strings.HasPrefix(text, setTaut) {
+				always(out, cmdTaut, rt.Taut, strings.TrimPrefix(text, cmdTaut))
+			} else if text == cmdContra || strings.HasPrefix(text, setContra) {
+				always(out, cmdContra, rt.Contra, strings.TrimPrefix(text, cmdContra))
+			} else if text == cmdSimp || strings.HasPrefix(text, setSimp) {
+				simplify(out, rt, strings.TrimPrefix(text, cmdSimp))
+			} else if form, src, ok := normalForm(text); ok {
+				normalize(out, rt, form, src)
+			} else if text == cmdKmap || strings.HasPrefix(text, setKmap) {
+				kmap(out, rt, strings.TrimPrefix(text, cmdKmap))
+			} else if text == cmdSynth || strings.HasPrefix(text, setSynth) {
+				decl, err := rt.Synth(strings.TrimPrefix(text, cmdSynth))
+
+				if err != nil {
+					printErrors(out, "Cannot synthesize gate due to errors:", err)
+					continue
+				}
+
+				fmt.Fprintf(out, "< %s\n\n", decl)
+			} else if text == cmdMapTo || strings.HasPrefix(text, setMapTo) {
+				mapTo(out, rt, strings.Fields(strings.TrimPrefix(text, cmdMapTo)))
+			} else if text == cmdBdd || strings.HasPrefix(text, setBdd) {
+				bdd(out, rt, strings.TrimPrefix(text, cmdBdd))
+			} else if text == cmdCount || strings.HasPrefix(text, setCount) {
+				count(out, rt, strings.TrimPrefix(text, cmdCount))
+			} else if text == cmdProb || strings.HasPrefix(text, setProb) {
+				prob(out, rt, strings.TrimPrefix(text, cmdProb))
+			} else if text == cmdSim || strings.HasPrefix(text, setSim) {
+				simulate(out, rt, strings.Fields(strings.TrimPrefix(text, cmdSim)))
+			} else if text == cmdTick || strings.HasPrefix(text, setTick) {
+				tick(out, rt, strings.TrimSpace(strings.TrimPrefix(text, cmdTick)))
+			} else if strings.HasPrefix(text, ".") {
+				fmt.Fprintf(out, "< error: Unknown command: `%s`. Enter `.help` for help.\n\n", text)
+			} else if rt.Incomplete(text) {
+				pending = append(pending, text)
+			} else {
+				printResults(out, rt.Exec(text))
+			}
+		}
 	}
 }
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+// Every testdata/*.repl file is fed into the repl line by line and its output
+// is compared against the matching .golden file. Run the tests with -update to
+// regenerate the golden files after an intentional change.
+func TestTranscripts(t *testing.T) {
+	inputs, err := filepath.Glob(filepath.Join("testdata", "*.repl"))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, input := range inputs {
+		golden := strings.TrimSuffix(input, ".repl") + ".golden"
+
+		t.Run(filepath.Base(input), func(t *testing.T) {
+			src, err := ioutil.ReadFile(input)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var out bytes.Buffer
+			repl(bytes.NewReader(src), &out)
+
+			if *update {
+				if err := ioutil.WriteFile(golden, out.Bytes(), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			exp, err := ioutil.ReadFile(golden)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if out.String() != string(exp) {
+				t.Errorf("output does not match %s:\n%s", golden, out.String())
+			}
+		})
+	}
+}